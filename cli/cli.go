@@ -0,0 +1,130 @@
+// Package cli 实现命令行子命令，便于在cron和CI中自动执行同步、分析、备份等操作
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// 远程模式使用的环境变量
+const (
+	envServerURL = "GSM_SERVER_URL"
+	envAPIToken  = "GSM_API_TOKEN"
+)
+
+// command 子命令
+type command struct {
+	usage string
+	// summary 在帮助中显示的说明
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// commands 所有子命令，键为命令名。在 init 中赋值，避免子命令引用 commands 造成初始化循环
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"serve":   {"serve", "启动Web服务器（默认）", runServe},
+		"sync":    {"sync", "从GitHub同步星标仓库", runSync},
+		"analyze": {"analyze [-all | -id ID[,ID...]] [-force]", "使用AI分析仓库，结果保存为待审核的建议", runAnalyze},
+		"export":  {"export [-format json|csv|ndjson|markdown|bookmarks] [-o 文件]", "导出数据，默认输出到标准输出", runExport},
+		"import":  {"import [-format 格式] [-strategy 策略] [-bookmarks [-star]] [-dry-run] 文件", "导入数据集或浏览器书签，文件为 - 时读取标准输入", runImport},
+		"backup":  {"backup [-target webdav|s3] [-list]", "立即备份数据，或列出已有备份", runBackup},
+		"restore": {"restore [-target webdav|s3] 备份名", "从备份恢复数据", runRestore},
+		"tags":    {"tags rename 原标签 新标签", "在所有仓库中重命名标签，新标签为空字符串时删除该标签", runTags},
+		"search":  {"search [-limit N] [-json] 关键词...", "按相关度搜索星标仓库", runSearch},
+	}
+}
+
+// Run 执行命令行参数指定的子命令，没有参数时启动Web服务器
+func Run(args []string) error {
+	if len(args) == 0 {
+		return runServe(context.Background(), nil)
+	}
+	name := args[0]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		printUsage(os.Stdout)
+		return nil
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		printUsage(os.Stderr)
+		return fmt.Errorf("未知命令: %s", name)
+	}
+
+	// Ctrl+C 时取消正在进行的请求
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := cmd.run(ctx, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+// printUsage 输出所有子命令的用法
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "用法: github-stars-manager <命令> [参数]")
+	fmt.Fprintln(w)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n      %s\n", commands[name].usage, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "默认直接读写当前目录下的 data 目录。使用 -server 和 -token（或环境变量 %s、%s）时通过API令牌操作远程实例。\n", envServerURL, envAPIToken)
+}
+
+// newFlagSet 创建子命令的参数集合，包括连接远程实例的参数
+func newFlagSet(name string, opts *remoteOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&opts.server, "server", os.Getenv(envServerURL), "远程实例地址，例如 https://stars.example.com，为空时使用本地数据")
+	fs.StringVar(&opts.token, "token", os.Getenv(envAPIToken), "远程实例的API令牌")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: github-stars-manager %s\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// printJSON 以缩进格式输出JSON
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// splitList 拆分逗号分隔的参数
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// randomID 生成随机的十六进制字符串
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+
+	"github-stars-manager/config"
+	"github-stars-manager/di"
+	"github-stars-manager/repository"
+	"github-stars-manager/session"
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// localBaseURL 本地模式下请求使用的地址，请求不会离开当前进程
+const localBaseURL = "http://local"
+
+// localUserName 本地模式会话的用户名
+const localUserName = "cli"
+
+// 服务器下发CSRF令牌的cookie和请求时携带令牌的请求头
+const (
+	csrfCookie = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+// Client 调用管理器的 /api 接口
+//
+// 远程模式通过HTTP访问运行中的实例，使用API令牌认证；本地模式在当前进程中创建与服务器相同的
+// 路由，直接读写 data 目录，需要在程序所在目录运行。
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+	// Local 是否为本地模式
+	Local bool
+	// GitHubToken 本地模式访问GitHub使用的token
+	GitHubToken string
+	// csrfToken 本地模式使用会话cookie认证，修改数据的请求需要携带服务器下发的CSRF令牌
+	csrfToken string
+}
+
+// remoteOptions 连接远程实例的参数
+type remoteOptions struct {
+	server string
+	token  string
+}
+
+// newClient 根据参数创建远程或本地模式的客户端，未指定服务器地址时使用本地模式
+func newClient(opts remoteOptions) (*Client, error) {
+	if opts.server != "" {
+		if opts.token == "" {
+			return nil, fmt.Errorf("连接远程实例需要API令牌，请使用 -token 或环境变量 %s", envAPIToken)
+		}
+		return &Client{
+			baseURL: strings.TrimRight(opts.server, "/"),
+			token:   opts.token,
+			http:    &http.Client{},
+		}, nil
+	}
+	return newLocalClient()
+}
+
+// newLocalClient 从依赖注入容器中获取路由，创建本地模式的客户端
+func newLocalClient() (*Client, error) {
+	// 命令行的标准输出用于输出结果，不输出gin的请求日志；未设置日志级别时只输出错误，
+	// 接口返回的错误信息会由各命令输出
+	gin.SetMode(gin.ReleaseMode)
+	gin.DefaultWriter = io.Discard
+	if os.Getenv("LOGGER_LEVEL") == "" {
+		os.Setenv("LOGGER_LEVEL", "error")
+	}
+
+	// 服务器不会重新读取被其他进程修改的数据文件，两边同时写入会互相覆盖。锁在进程退出时释放
+	if _, err := repository.LockDataDir(); err != nil {
+		if errors.Is(err, repository.ErrDataLocked) {
+			return nil, errors.New("服务器正在使用 data 目录，本地模式会与服务器同时写入数据文件，请使用 -server 和 -token 连接运行中的实例")
+		}
+		return nil, fmt.Errorf("锁定 data 目录失败: %w", err)
+	}
+
+	var client *Client
+	err := di.NewContainer().Invoke(func(engine *gin.Engine, cfg *config.Config) {
+		client = &Client{
+			baseURL:     localBaseURL,
+			http:        &http.Client{Transport: localTransport{handler: engine}},
+			Local:       true,
+			GitHubToken: cfg.GitHubToken,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 使用只存在于当前进程的会话通过认证，访问GitHub时使用配置的 GITHUB_TOKEN。
+	// 本地模式直接读写 data 目录，以管理员身份执行，不作为用户记录活动
+	sessionID, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	session.Set(sessionID, &session.SessionData{AccessToken: client.GitHubToken, UserName: localUserName, Role: config.RoleAdmin, Local: true})
+	client.token = sessionID
+	return client, nil
+}
+
+// localTransport 把请求直接交给进程内的路由处理
+type localTransport struct {
+	handler http.Handler
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t localTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+// APIError 接口返回的错误
+type APIError struct {
+	Status  int
+	Message string
+	// Body 原始响应内容，例如导入校验失败时的报告
+	Body []byte
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	return fmt.Sprintf("请求失败（%d）: %s", e.Status, e.Message)
+}
+
+// Do 发送请求，状态码不是2xx时返回 *APIError
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body io.Reader, contentType string) ([]byte, error) {
+	// 服务器在任意请求的响应中下发CSRF令牌，还没有时先请求一次用户信息
+	if c.Local && c.csrfToken == "" && method != http.MethodGet && method != http.MethodHead {
+		if _, err := c.Do(ctx, http.MethodGet, "/api/user", nil, nil, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Local {
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: c.token})
+		if c.csrfToken != "" {
+			req.AddCookie(&http.Cookie{Name: csrfCookie, Value: c.csrfToken})
+			req.Header.Set(csrfHeader, c.csrfToken)
+		}
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == csrfCookie {
+			c.csrfToken = cookie.Value
+		}
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &APIError{Status: resp.StatusCode, Message: errorMessage(data), Body: data}
+	}
+	return data, nil
+}
+
+// GetJSON 发送GET请求并解析返回的JSON
+func (c *Client) GetJSON(ctx context.Context, path string, query url.Values, out interface{}) error {
+	data, err := c.Do(ctx, http.MethodGet, path, query, nil, "")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// PostJSON 以JSON格式发送POST请求并解析返回的JSON，in 为nil时不发送请求体
+func (c *Client) PostJSON(ctx context.Context, path string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+	data, err := c.Do(ctx, http.MethodPost, path, query, body, contentType)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// errorMessage 取出错误响应中的 error 或 msg 字段
+func errorMessage(data []byte) string {
+	var body struct {
+		Error string `json:"error"`
+		Msg   string `json:"msg"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		if body.Error != "" {
+			return body.Error
+		}
+		if body.Msg != "" {
+			return body.Msg
+		}
+	}
+	return utils.TruncateUTF8(strings.TrimSpace(string(data)), 200)
+}
@@ -0,0 +1,397 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github-stars-manager/controllers"
+	"github-stars-manager/di"
+	"github-stars-manager/repository"
+	"github-stars-manager/routes"
+	"github-stars-manager/utils"
+)
+
+// runServe 启动Web服务器和后台任务
+func runServe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	unlock, err := repository.LockDataDir()
+	if err != nil {
+		if errors.Is(err, repository.ErrDataLocked) {
+			return errors.New("data 目录正在被其他服务器或本地模式的命令使用")
+		}
+		return fmt.Errorf("锁定 data 目录失败: %w", err)
+	}
+	defer unlock()
+	return di.NewContainer().Invoke(func(server *routes.Server) error {
+		return server.Run()
+	})
+}
+
+// runSync 从GitHub同步星标仓库
+func runSync(ctx context.Context, args []string) error {
+	var opts remoteOptions
+	fs := newFlagSet("sync", &opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	if client.Local && client.GitHubToken == "" {
+		return errors.New("本地模式同步需要设置环境变量 GITHUB_TOKEN")
+	}
+
+	var result struct {
+		Count int `json:"count"`
+	}
+	if err := client.PostJSON(ctx, "/api/sync", nil, nil, &result); err != nil {
+		return err
+	}
+	fmt.Printf("同步完成，共 %d 个仓库\n", result.Count)
+	return nil
+}
+
+// runAnalyze 逐个分析仓库，出错时继续分析下一个，AI预算用完时停止
+func runAnalyze(ctx context.Context, args []string) error {
+	var opts remoteOptions
+	fs := newFlagSet("analyze", &opts)
+	all := fs.Bool("all", false, "分析所有仓库")
+	ids := fs.String("id", "", "要分析的仓库ID，多个用逗号分隔")
+	force := fs.Bool("force", false, "忽略缓存，重新调用AI分析")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *all == (*ids != "") {
+		fs.Usage()
+		return errors.New("需要指定 -all 或 -id 其中之一")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+
+	var repoIDs []int64
+	if *all {
+		var repos []utils.Repo
+		if err := client.GetJSON(ctx, "/api/repos", nil, &repos); err != nil {
+			return err
+		}
+		for _, repo := range repos {
+			repoIDs = append(repoIDs, repo.ID)
+		}
+	} else {
+		for _, s := range splitList(*ids) {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return fmt.Errorf("仓库ID格式错误: %s", s)
+			}
+			repoIDs = append(repoIDs, id)
+		}
+	}
+
+	query := url.Values{}
+	if *force {
+		query.Set("force", "true")
+	}
+	failed := 0
+	for i, id := range repoIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var view controllers.SuggestionView
+		err := client.PostJSON(ctx, fmt.Sprintf("/api/repos/%d/analyze", id), query, nil, &view)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusPaymentRequired {
+			return err
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "[%d/%d] %d: %v\n", i+1, len(repoIDs), id, err)
+			continue
+		}
+		cached := ""
+		if view.Cached {
+			cached = "（缓存）"
+		}
+		fmt.Printf("[%d/%d] %s: %s | %s%s\n", i+1, len(repoIDs), view.FullName, view.Category, strings.Join(view.Tags, ","), cached)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个仓库分析失败", failed)
+	}
+	fmt.Fprintf(os.Stderr, "分析完成，共 %d 个仓库，建议需要在页面上审核后才会生效\n", len(repoIDs))
+	return nil
+}
+
+// runExport 导出数据集、Markdown或书签文件
+func runExport(ctx context.Context, args []string) error {
+	var opts remoteOptions
+	fs := newFlagSet("export", &opts)
+	format := fs.String("format", controllers.FormatJSON, "导出格式：json、csv、ndjson、markdown、bookmarks")
+	output := fs.String("o", "", "输出文件，默认输出到标准输出")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	path := "/api/export"
+	query := url.Values{}
+	switch *format {
+	case "markdown":
+		path = "/api/export/markdown"
+	case "bookmarks":
+		path = "/api/export/bookmarks"
+	default:
+		query.Set("format", *format)
+	}
+
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	data, err := client.Do(ctx, http.MethodGet, path, query, nil, "")
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已导出到 %s\n", *output)
+	return nil
+}
+
+// runImport 导入数据集或书签文件，输出导入报告
+func runImport(ctx context.Context, args []string) error {
+	var opts remoteOptions
+	fs := newFlagSet("import", &opts)
+	format := fs.String("format", "", "数据集格式：json、csv、ndjson，默认根据文件扩展名或内容判断")
+	strategy := fs.String("strategy", controllers.StrategyKeepLocal, "与本地标注冲突时的处理：keep-local、overwrite、merge-tags")
+	bookmarks := fs.Bool("bookmarks", false, "导入浏览器导出的HTML书签文件")
+	star := fs.Bool("star", false, "导入书签时为尚未star的仓库加星")
+	dryRun := fs.Bool("dry-run", false, "只检查并输出报告，不写入数据")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("需要指定一个导入文件")
+	}
+	file := fs.Arg(0)
+
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	path := "/api/import"
+	query := url.Values{}
+	if *dryRun {
+		query.Set("dry_run", "true")
+	}
+	if *bookmarks {
+		path = "/api/import/bookmarks"
+		if *star {
+			query.Set("star", "true")
+		}
+	} else {
+		query.Set("strategy", *strategy)
+		if *format == "" {
+			*format = formatFromExtension(file)
+		}
+		if *format != "" {
+			query.Set("format", *format)
+		}
+	}
+
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	body, err := client.Do(ctx, http.MethodPost, path, query, bytes.NewReader(data), "application/octet-stream")
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnprocessableEntity {
+		// 校验失败时输出报告中的错误
+		os.Stdout.Write(apiErr.Body)
+		fmt.Println()
+		return errors.New("导入数据校验失败，没有写入任何数据")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(body, '\n'))
+	return err
+}
+
+// formatFromExtension 根据文件扩展名判断数据集格式，无法判断时返回空字符串
+func formatFromExtension(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return controllers.FormatCSV
+	case ".ndjson", ".jsonl":
+		return controllers.FormatNDJSON
+	case ".json":
+		return controllers.FormatJSON
+	}
+	return ""
+}
+
+// runBackup 立即备份，-list 时列出已有备份
+func runBackup(ctx context.Context, args []string) error {
+	var opts remoteOptions
+	fs := newFlagSet("backup", &opts)
+	target := fs.String("target", controllers.BackupTargetWebDAV, "备份目标：webdav、s3")
+	list := fs.Bool("list", false, "列出已有备份")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	query := url.Values{"target": {*target}}
+
+	if *list {
+		var backups []controllers.BackupInfo
+		if err := client.GetJSON(ctx, "/api/backups", query, &backups); err != nil {
+			return err
+		}
+		for _, b := range backups {
+			fmt.Printf("%s\t%s\t%d\n", b.Name, b.CreatedAt.Local().Format("2006-01-02 15:04:05"), b.Size)
+		}
+		return nil
+	}
+
+	var info controllers.BackupInfo
+	if err := client.PostJSON(ctx, "/api/backups", query, nil, &info); err != nil {
+		return err
+	}
+	fmt.Printf("备份完成: %s（%d 字节）\n", info.Name, info.Size)
+	return nil
+}
+
+// runRestore 从指定的备份恢复数据
+func runRestore(ctx context.Context, args []string) error {
+	var opts remoteOptions
+	fs := newFlagSet("restore", &opts)
+	target := fs.String("target", controllers.BackupTargetWebDAV, "备份目标：webdav、s3")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("需要指定备份名，可以使用 backup -list 查看")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Name      string `json:"name"`
+		CreatedAt string `json:"created_at"`
+		Files     int    `json:"files"`
+	}
+	path := "/api/backups/" + url.PathEscape(fs.Arg(0)) + "/restore"
+	if err := client.PostJSON(ctx, path, url.Values{"target": {*target}}, nil, &result); err != nil {
+		return err
+	}
+	fmt.Printf("已从 %s 恢复 %d 个文件（备份时间 %s）\n", result.Name, result.Files, result.CreatedAt)
+	return nil
+}
+
+// runTags 管理标签，目前支持 rename
+func runTags(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "rename" {
+		fmt.Fprintf(os.Stderr, "用法: github-stars-manager %s\n", commands["tags"].usage)
+		return errors.New("未知的标签命令")
+	}
+	var opts remoteOptions
+	fs := newFlagSet("tags", &opts)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("需要指定原标签和新标签")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Updated int `json:"updated"`
+	}
+	body := map[string]string{"from": fs.Arg(0), "to": fs.Arg(1)}
+	if err := client.PostJSON(ctx, "/api/tags/rename", nil, body, &result); err != nil {
+		return err
+	}
+	fmt.Printf("已更新 %d 个仓库\n", result.Updated)
+	return nil
+}
+
+// runSearch 按相关度搜索星标仓库
+func runSearch(ctx context.Context, args []string) error {
+	var opts remoteOptions
+	fs := newFlagSet("search", &opts)
+	limit := fs.Int("limit", 0, "最多返回的仓库数量，默认20")
+	asJSON := fs.Bool("json", false, "以JSON格式输出")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("需要指定搜索关键词")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"q": {strings.Join(fs.Args(), " ")}}
+	if *limit > 0 {
+		query.Set("limit", strconv.Itoa(*limit))
+	}
+	var results []controllers.AskSource
+	if err := client.GetJSON(ctx, "/api/search", query, &results); err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(os.Stdout, results)
+	}
+	for _, r := range results {
+		fmt.Printf("%s  %s\n", r.FullName, r.URL)
+		if len(r.Tags) > 0 {
+			fmt.Printf("    标签: %s\n", strings.Join(r.Tags, ", "))
+		}
+		if r.Description != "" {
+			fmt.Printf("    %s\n", r.Description)
+		}
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "没有找到相关仓库")
+	}
+	return nil
+}
@@ -1,16 +1,160 @@
 package config
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/spf13/viper"
 )
 
+// GitHub登录方式
+const (
+	// GitHubAuthOAuth 使用OAuth App登录，access token不过期
+	GitHubAuthOAuth = "oauth"
+	// GitHubAuthApp 使用GitHub App的用户令牌登录，令牌会过期，通过刷新令牌续期
+	GitHubAuthApp = "app"
+)
+
+// 用户角色
+const (
+	// RoleAdmin 管理员，可以修改全局设置和AI密钥、同步、导入、备份和管理用户
+	RoleAdmin = "admin"
+	// RoleUser 普通用户，对仓库数据只读，可以浏览、搜索、导出和管理自己的令牌
+	RoleUser = "user"
+)
+
 // Config 应用配置结构体
 type Config struct {
+	// GitHubAuthMode GitHub登录方式：oauth 或 app，两种方式都使用下面的Client ID和Client Secret
+	GitHubAuthMode     string
 	GitHubClientID     string
 	GitHubClientSecret string
-	RedirectURL        string
-	ServerPort         string
-	LoggerLevel        string
+	// GitHubAppID GitHub App的ID，与私钥一起用于查询App的安装
+	GitHubAppID string
+	// GitHubAppPrivateKey GitHub App的PEM格式私钥
+	GitHubAppPrivateKey string
+	// GitHubAppPrivateKeyFile GitHub App私钥文件的路径，私钥包含换行、不方便放在环境变量中时使用
+	GitHubAppPrivateKeyFile string
+	RedirectURL             string
+	ServerPort              string
+	LoggerLevel             string
+	// GitHubToken 后台任务（如发布跟踪）使用的GitHub token，可选
+	GitHubToken string
+	// SessionSecret 签名登录state等数据使用的密钥，为空时每次启动随机生成
+	SessionSecret string
+	// GitHubOAuthPKCE GitHub登录时是否使用PKCE
+	GitHubOAuthPKCE bool
+	// GitHubOAuthScopes GitHub登录时申请的权限，逗号分隔，为空时按启用的功能申请最少的权限
+	GitHubOAuthScopes string
+	// GitHubStarEnabled 是否启用star功能（导入书签时加星），启用后登录时申请 public_repo 权限
+	GitHubStarEnabled bool
+	// AllowedOrigins 允许跨域访问API的来源，逗号分隔，例如 https://dash.example.com，为空时只允许本站访问
+	AllowedOrigins string
+	// AllowedUsers 允许登录的GitHub用户名，逗号分隔
+	AllowedUsers string
+	// AllowedOrgs 允许其成员登录的GitHub组织，逗号分隔，登录时检查成员身份
+	AllowedOrgs string
+	// AdminUsers 管理员的GitHub用户名，逗号分隔，为空时所有用户都是管理员
+	AdminUsers string
+}
+
+// UseGitHubApp 是否使用GitHub App登录
+func (c *Config) UseGitHubApp() bool {
+	return c.GitHubAuthMode == GitHubAuthApp
+}
+
+// ValidateGitHubAuthMode 检查GitHub登录方式的取值
+func (c *Config) ValidateGitHubAuthMode() error {
+	if c.GitHubAuthMode != GitHubAuthOAuth && c.GitHubAuthMode != GitHubAuthApp {
+		return fmt.Errorf("GITHUB_AUTH_MODE 只能是 %s 或 %s，当前为 %q", GitHubAuthOAuth, GitHubAuthApp, c.GitHubAuthMode)
+	}
+	return nil
+}
+
+// AppPrivateKey 返回GitHub App的私钥，优先使用 GitHubAppPrivateKey，都未配置时返回nil
+func (c *Config) AppPrivateKey() ([]byte, error) {
+	if c.GitHubAppPrivateKey != "" {
+		return []byte(c.GitHubAppPrivateKey), nil
+	}
+	if c.GitHubAppPrivateKeyFile == "" {
+		return nil, nil
+	}
+	return os.ReadFile(c.GitHubAppPrivateKeyFile)
+}
+
+// OriginList 返回允许跨域访问的来源列表，去掉末尾的斜杠
+func (c *Config) OriginList() []string {
+	var origins []string
+	for _, origin := range splitList(c.AllowedOrigins) {
+		if origin = strings.TrimRight(origin, "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// RestrictLogin 是否配置了登录白名单，未配置时任何GitHub账号都可以登录
+func (c *Config) RestrictLogin() bool {
+	return c.AllowedUsers != "" || c.AllowedOrgs != ""
+}
+
+// AllowedOrgList 返回允许其成员登录的组织
+func (c *Config) AllowedOrgList() []string {
+	return splitList(c.AllowedOrgs)
+}
+
+// LoginAllowed 判断用户名是否可以直接登录：未配置白名单、在 AllowedUsers 中或是管理员。
+// 返回false时还需要检查组织成员身份
+func (c *Config) LoginAllowed(login string) bool {
+	return !c.RestrictLogin() || containsLogin(splitList(c.AllowedUsers), login) || containsLogin(splitList(c.AdminUsers), login)
+}
+
+// RoleFor 返回用户的角色，未配置 AdminUsers 时所有用户都是管理员
+func (c *Config) RoleFor(login string) string {
+	if c.AdminUsers == "" || containsLogin(splitList(c.AdminUsers), login) {
+		return RoleAdmin
+	}
+	return RoleUser
+}
+
+// splitList 拆分逗号分隔的配置
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// containsLogin 判断列表中是否包含指定的GitHub用户名，GitHub用户名不区分大小写
+func containsLogin(logins []string, login string) bool {
+	for _, l := range logins {
+		if strings.EqualFold(l, login) {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthScopes 返回GitHub登录时申请的权限
+//
+// 默认只申请 read:user，读取公开的star不需要额外权限；启用star功能时加上 public_repo，
+// 配置了组织白名单时加上 read:org 以检查成员身份。
+func (c *Config) OAuthScopes() []string {
+	if c.GitHubOAuthScopes != "" {
+		return strings.FieldsFunc(c.GitHubOAuthScopes, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	scopes := []string{"read:user"}
+	if c.GitHubStarEnabled {
+		scopes = append(scopes, "public_repo")
+	}
+	if c.AllowedOrgs != "" {
+		scopes = append(scopes, "read:org")
+	}
+	return scopes
 }
 
 // NewConfig 从环境变量创建配置实例
@@ -21,16 +165,31 @@ func NewConfig() *Config {
 	viper.SetDefault("GITHUB_CLIENT_SECRET", "你的ClientSecret")
 	viper.SetDefault("GITHUB_REDIRECT_URL", "http://localhost:8181/auth/github/callback")
 	viper.SetDefault("SERVER_PORT", ":8181")
+	viper.SetDefault("GITHUB_OAUTH_PKCE", true)
+	viper.SetDefault("GITHUB_AUTH_MODE", GitHubAuthOAuth)
 
 	// 从环境变量中读取配置
 	viper.AutomaticEnv()
 
 	// 注意：launch.json中的环境变量名称需要与viper配置的名称一致
 	return &Config{
-		GitHubClientID:     viper.GetString("GITHUB_CLIENT_ID"),
-		GitHubClientSecret: viper.GetString("GITHUB_CLIENT_SECRET"),
-		RedirectURL:        viper.GetString("GITHUB_REDIRECT_URL"),
-		ServerPort:         viper.GetString("SERVER_PORT"),
-		LoggerLevel:        viper.GetString("LOGGER_LEVEL"),
+		GitHubAuthMode:          strings.ToLower(viper.GetString("GITHUB_AUTH_MODE")),
+		GitHubClientID:          viper.GetString("GITHUB_CLIENT_ID"),
+		GitHubClientSecret:      viper.GetString("GITHUB_CLIENT_SECRET"),
+		GitHubAppID:             viper.GetString("GITHUB_APP_ID"),
+		GitHubAppPrivateKey:     viper.GetString("GITHUB_APP_PRIVATE_KEY"),
+		GitHubAppPrivateKeyFile: viper.GetString("GITHUB_APP_PRIVATE_KEY_FILE"),
+		RedirectURL:             viper.GetString("GITHUB_REDIRECT_URL"),
+		ServerPort:              viper.GetString("SERVER_PORT"),
+		LoggerLevel:             viper.GetString("LOGGER_LEVEL"),
+		GitHubToken:             viper.GetString("GITHUB_TOKEN"),
+		SessionSecret:           viper.GetString("SESSION_SECRET"),
+		GitHubOAuthPKCE:         viper.GetBool("GITHUB_OAUTH_PKCE"),
+		GitHubOAuthScopes:       viper.GetString("GITHUB_OAUTH_SCOPES"),
+		GitHubStarEnabled:       viper.GetBool("GITHUB_STAR_ENABLED"),
+		AllowedOrigins:          viper.GetString("ALLOWED_ORIGINS"),
+		AllowedUsers:            viper.GetString("ALLOWED_USERS"),
+		AllowedOrgs:             viper.GetString("ALLOWED_ORGS"),
+		AdminUsers:              viper.GetString("ADMIN_USERS"),
 	}
-}
\ No newline at end of file
+}
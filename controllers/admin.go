@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github-stars-manager/config"
+	"github-stars-manager/repository"
+	"github-stars-manager/session"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminHandler 处理用户管理相关的请求，只有管理员可以访问
+type AdminHandler struct {
+	config *config.Config
+	logger *zap.Logger
+	repo   repository.Repository
+}
+
+// NewAdminHandler 创建一个新的AdminHandler实例
+func NewAdminHandler(config *config.Config, logger *zap.Logger, repo repository.Repository) *AdminHandler {
+	if config.RestrictLogin() && config.AdminUsers == "" {
+		logger.Warn("配置了登录白名单但没有配置 ADMIN_USERS，所有允许登录的用户都是管理员")
+	}
+	return &AdminHandler{
+		config: config,
+		logger: logger,
+		repo:   repo,
+	}
+}
+
+// UserView 返回给管理员的用户信息
+type UserView struct {
+	Login        string `json:"login"`
+	AvatarURL    string `json:"avatar_url"`
+	Role         string `json:"role"`
+	FirstLoginAt string `json:"first_login_at"`
+	LastLoginAt  string `json:"last_login_at"`
+	LastActiveAt string `json:"last_active_at"`
+	// Sessions 当前已登录的会话数量
+	Sessions int `json:"sessions"`
+	// APITokens 未过期的API令牌数量
+	APITokens int `json:"api_tokens"`
+	// FeedToken 是否生成了订阅源令牌
+	FeedToken bool `json:"feed_token"`
+}
+
+// ListUsers 列出登录过或持有API令牌的用户，按最后活动时间从新到旧
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	users, err := h.repo.GetUsers()
+	if err != nil {
+		h.logger.Error("加载用户失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载用户失败"})
+		return
+	}
+	tokens, err := h.repo.GetAPITokens()
+	if err != nil {
+		h.logger.Error("加载API令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载API令牌失败"})
+		return
+	}
+	feedTokens, err := h.repo.GetFeedTokens()
+	if err != nil {
+		h.logger.Error("加载订阅源令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载订阅源令牌失败"})
+		return
+	}
+
+	views := make(map[string]*UserView)
+	view := func(login string) *UserView {
+		v, ok := views[login]
+		if !ok {
+			v = &UserView{Login: login, Role: h.config.RoleFor(login)}
+			views[login] = v
+		}
+		return v
+	}
+	for login, u := range users {
+		v := view(login)
+		v.AvatarURL = u.AvatarURL
+		v.FirstLoginAt = u.FirstLoginAt
+		v.LastLoginAt = u.LastLoginAt
+		v.LastActiveAt = u.LastActiveAt
+	}
+	for login, count := range session.CountByUser() {
+		view(login).Sessions = count
+	}
+	now := time.Now()
+	for _, t := range tokens {
+		if !t.Expired(now) {
+			view(t.UserName).APITokens++
+		}
+	}
+	for login := range feedTokens {
+		view(login).FeedToken = true
+	}
+
+	result := make([]UserView, 0, len(views))
+	for _, v := range views {
+		result = append(result, *v)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LastActiveAt != result[j].LastActiveAt {
+			return result[i].LastActiveAt > result[j].LastActiveAt
+		}
+		return result[i].Login < result[j].Login
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+// RevokeSessions 注销指定用户的所有会话，用户需要重新登录
+func (h *AdminHandler) RevokeSessions(c *gin.Context) {
+	login := c.Param("login")
+	revoked := session.DeleteUser(login)
+	h.logger.Info("管理员注销用户会话", zap.String("user", login), zap.Int("revoked", revoked))
+	c.JSON(http.StatusOK, gin.H{"msg": "已注销用户的会话", "revoked": revoked})
+}
+
+// RevokeAPITokens 撤销指定用户的所有API令牌和订阅源令牌
+func (h *AdminHandler) RevokeAPITokens(c *gin.Context) {
+	login := c.Param("login")
+	tokens, err := h.repo.GetAPITokens()
+	if err != nil {
+		h.logger.Error("加载API令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载API令牌失败"})
+		return
+	}
+	revoked := 0
+	for id, t := range tokens {
+		if t.UserName != login {
+			continue
+		}
+		if err := h.repo.DeleteAPIToken(id); err != nil {
+			h.logger.Error("删除API令牌失败", zap.String("id", id), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除API令牌失败"})
+			return
+		}
+		revoked++
+	}
+
+	feedTokens, err := h.repo.GetFeedTokens()
+	if err != nil {
+		h.logger.Error("加载订阅源令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载订阅源令牌失败"})
+		return
+	}
+	if _, ok := feedTokens[login]; ok {
+		if err := h.repo.DeleteFeedToken(login); err != nil {
+			h.logger.Error("删除订阅源令牌失败", zap.String("user", login), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除订阅源令牌失败"})
+			return
+		}
+		revoked++
+	}
+	h.logger.Info("管理员撤销用户的令牌", zap.String("user", login), zap.Int("revoked", revoked))
+	c.JSON(http.StatusOK, gin.H{"msg": "已撤销用户的API令牌和订阅源令牌", "revoked": revoked})
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github-stars-manager/config"
+	"github-stars-manager/repository"
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// defaultUsageDays 用量统计默认的天数
+const defaultUsageDays = 30
+
+// UsageSummary AI调用用量汇总
+type UsageSummary struct {
+	// Calls 实际调用AI服务的次数，包括修正回复的调用
+	Calls int `json:"calls"`
+	// CacheHits 命中缓存没有调用AI服务的次数
+	CacheHits    int     `json:"cache_hits"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// add 把一条调用记录计入汇总
+func (s *UsageSummary) add(record repository.AIUsageRecord, cost float64) {
+	if record.Cached {
+		s.CacheHits++
+		return
+	}
+	s.Calls++
+	s.InputTokens += record.InputTokens
+	s.OutputTokens += record.OutputTokens
+	s.Cost += cost
+}
+
+// UsageDay 某一天的用量
+type UsageDay struct {
+	Date string `json:"date"`
+	UsageSummary
+}
+
+// UsageModel 某个模型的用量
+type UsageModel struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	UsageSummary
+}
+
+// UsageBudget 本月预算使用情况
+type UsageBudget struct {
+	// Budget 每月预算，为0时不限制
+	Budget    float64 `json:"budget"`
+	Cost      float64 `json:"cost"`
+	Remaining float64 `json:"remaining"`
+	Exceeded  bool    `json:"exceeded"`
+}
+
+// AIHandler 处理AI用量等相关请求
+type AIHandler struct {
+	config      *config.Config
+	logger      *zap.Logger
+	repo        repository.Repository
+	settingsCli *utils.SettingsUtil
+	llmCli      *utils.LLMUtil
+}
+
+// NewAIHandler 创建AI处理器实例
+func NewAIHandler(config *config.Config, logger *zap.Logger, repo repository.Repository, settingsCli *utils.SettingsUtil, llmCli *utils.LLMUtil) *AIHandler {
+	return &AIHandler{
+		config:      config,
+		logger:      logger,
+		repo:        repo,
+		settingsCli: settingsCli,
+		llmCli:      llmCli,
+	}
+}
+
+// GetUsage 获取AI调用用量，按天和按模型汇总，费用按当前配置的价格估算
+//
+// days 参数指定统计最近多少天，默认30；本月预算的使用情况始终按整个自然月计算。
+func (h *AIHandler) GetUsage(c *gin.Context) {
+	days, err := parseOptionalInt(c.Query("days"))
+	if err != nil || days < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days参数错误"})
+		return
+	}
+	if days == 0 {
+		days = defaultUsageDays
+	}
+
+	settings, err := h.settingsCli.LoadSettings()
+	if err != nil {
+		h.logger.Error("加载设置失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载设置失败"})
+		return
+	}
+
+	// 同时加载统计窗口和本月的记录
+	now := time.Now()
+	windowStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -(days - 1))
+	since := windowStart
+	if start := monthStart(now); start.Before(since) {
+		since = start
+	}
+	records, err := h.repo.GetAIUsage(since)
+	if err != nil {
+		h.logger.Error("加载AI用量失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载AI用量失败"})
+		return
+	}
+
+	var total UsageSummary
+	budget := UsageBudget{Budget: settings.AI.MonthlyBudget}
+	dayIndex := make(map[string]*UsageDay)
+	modelIndex := make(map[string]*UsageModel)
+	for _, record := range records {
+		t := record.ParsedTime().In(now.Location())
+		cost := settings.AI.Cost(record.Model, utils.TokenUsage{InputTokens: record.InputTokens, OutputTokens: record.OutputTokens})
+		if !t.Before(monthStart(now)) && !record.Cached {
+			budget.Cost += cost
+		}
+		if t.Before(windowStart) {
+			continue
+		}
+
+		total.add(record, cost)
+		date := t.Format("2006-01-02")
+		if dayIndex[date] == nil {
+			dayIndex[date] = &UsageDay{Date: date}
+		}
+		dayIndex[date].add(record, cost)
+		key := record.Provider + "\x00" + record.Model
+		if modelIndex[key] == nil {
+			modelIndex[key] = &UsageModel{Provider: record.Provider, Model: record.Model}
+		}
+		modelIndex[key].add(record, cost)
+	}
+
+	if budget.Budget > 0 {
+		budget.Remaining = budget.Budget - budget.Cost
+		if budget.Remaining < 0 {
+			budget.Remaining = 0
+		}
+		budget.Exceeded = budget.Cost >= budget.Budget
+	}
+
+	dayList := make([]UsageDay, 0, len(dayIndex))
+	for _, d := range dayIndex {
+		dayList = append(dayList, *d)
+	}
+	sort.Slice(dayList, func(i, j int) bool { return dayList[i].Date < dayList[j].Date })
+
+	modelList := make([]UsageModel, 0, len(modelIndex))
+	for _, m := range modelIndex {
+		modelList = append(modelList, *m)
+	}
+	sort.Slice(modelList, func(i, j int) bool {
+		if modelList[i].Cost != modelList[j].Cost {
+			return modelList[i].Cost > modelList[j].Cost
+		}
+		if modelList[i].Calls != modelList[j].Calls {
+			return modelList[i].Calls > modelList[j].Calls
+		}
+		return modelList[i].Model < modelList[j].Model
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"currency": settings.AI.CurrencyName(),
+		"days":     dayList,
+		"models":   modelList,
+		"total":    total,
+		"month":    budget,
+	})
+}
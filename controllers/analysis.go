@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github-stars-manager/repository"
+	"github-stars-manager/utils"
+
+	"go.uber.org/zap"
+)
+
+// analysisSpec AI分析结果需要满足的要求
+type analysisSpec struct {
+	// Categories 可选的分类，回复的分类必须是其中之一
+	Categories []string
+	// TagCount 要求的标签数量，多余的标签会被丢弃
+	TagCount int
+	// Structured 是否通过JSON Schema要求AI服务结构化输出
+	Structured bool
+	// RepairAttempts 回复不符合要求时让AI修正的最多次数
+	RepairAttempts int
+}
+
+// errAIBudgetExceeded 本月估算费用已达到预算
+var errAIBudgetExceeded = errors.New("本月AI预算已用完，请在设置中调整预算")
+
+// analysisInput 一次仓库分析的输入
+type analysisInput struct {
+	RepoID int64
+	Prompt string
+	// Hash 分析输入的哈希，用于查找缓存
+	Hash string
+	// Force 忽略缓存重新分析
+	Force bool
+	Spec  analysisSpec
+	// OnDelta 不为空时以流式方式调用AI，每收到一段回复调用一次，attempt 为修正的次数
+	OnDelta func(attempt int, delta string)
+}
+
+// analyzeWithCache 分析仓库，输入未变化时直接返回缓存的结果；实际调用AI前检查本月预算
+func (h *StarHandler) analyzeWithCache(ctx context.Context, provider utils.LLMProvider, settings utils.AISettings, input analysisInput) (*AIAnalysisResult, bool, error) {
+	if !input.Force {
+		cached, err := h.repo.GetCachedAnalysis(input.Hash)
+		if err != nil {
+			h.logger.Warn("读取AI分析缓存失败", zap.Error(err))
+		} else if cached != nil {
+			h.logger.Info("使用缓存的AI分析结果", zap.Int64("repo_id", input.RepoID), zap.String("hash", input.Hash))
+			record := &repository.AIUsageRecord{
+				Time:     time.Now().Format(time.RFC3339),
+				Provider: provider.Name(),
+				Model:    provider.Model(),
+				Purpose:  UsagePurposeAnalyze,
+				RepoID:   input.RepoID,
+				Cached:   true,
+			}
+			if err := h.repo.AppendAIUsage(record); err != nil {
+				h.logger.Warn("记录AI用量失败", zap.Error(err))
+			}
+			return &AIAnalysisResult{
+				Category:    cached.Category,
+				Tags:        cached.Tags,
+				Description: cached.Description,
+			}, true, nil
+		}
+	}
+
+	exceeded, err := aiBudgetExceeded(h.repo, settings)
+	if err != nil {
+		h.logger.Warn("计算本月AI费用失败", zap.Error(err))
+	}
+	if exceeded {
+		return nil, false, errAIBudgetExceeded
+	}
+
+	metered := newMeteredProvider(provider, h.repo, h.logger, UsagePurposeAnalyze, input.RepoID)
+	result, err := h.callAIAnalysis(ctx, metered, input.Prompt, input.Spec, input.OnDelta)
+	if err != nil {
+		return nil, false, err
+	}
+
+	entry := &repository.CachedAnalysis{
+		Hash:        input.Hash,
+		RepoID:      input.RepoID,
+		Category:    result.Category,
+		Tags:        result.Tags,
+		Description: result.Description,
+		Provider:    provider.Name(),
+		Model:       provider.Model(),
+		CreatedAt:   time.Now().Format(time.RFC3339),
+	}
+	if err := h.repo.SaveCachedAnalysis(entry); err != nil {
+		h.logger.Warn("缓存AI分析结果失败", zap.Error(err))
+	}
+	return result, false, nil
+}
+
+// analysisSchema 返回分析结果的JSON Schema
+func analysisSchema(spec analysisSpec) *utils.JSONSchema {
+	categories := make([]interface{}, len(spec.Categories))
+	for i, category := range spec.Categories {
+		categories[i] = category
+	}
+	return &utils.JSONSchema{
+		Name:        "repo_analysis",
+		Description: "GitHub仓库的分类、标签和描述",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"category": map[string]interface{}{
+					"type":        "string",
+					"enum":        categories,
+					"description": "仓库的分类，必须是给定分类之一",
+				},
+				"tags": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": fmt.Sprintf("%d个最能代表仓库的标签", spec.TagCount),
+				},
+				"description": map[string]interface{}{
+					"type":        "string",
+					"description": "仓库的简洁描述",
+				},
+			},
+			"required":             []string{"category", "tags", "description"},
+			"additionalProperties": false,
+		},
+	}
+}
+
+// callAIAnalysis 调用AI进行分析，回复不符合要求时把错误反馈给AI并要求修正
+func (h *StarHandler) callAIAnalysis(ctx context.Context, provider utils.LLMProvider, prompt string, spec analysisSpec, onDelta func(attempt int, delta string)) (*AIAnalysisResult, error) {
+	req := utils.CompletionRequest{Prompt: prompt}
+	if spec.Structured {
+		req.Schema = analysisSchema(spec)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= spec.RepairAttempts; attempt++ {
+		if onDelta != nil {
+			req.OnDelta = func(delta string) { onDelta(attempt, delta) }
+		}
+		resp, err := provider.Complete(ctx, req)
+		if err != nil {
+			h.logger.Error("调用AI服务失败", zap.String("provider", provider.Name()), zap.Error(err))
+			return nil, fmt.Errorf("调用AI服务失败: %w", err)
+		}
+
+		result, err := parseAnalysisResult(resp.Content, spec)
+		if err == nil {
+			h.logger.Debug("AI分析成功",
+				zap.Int("repairs", attempt),
+				zap.String("category", result.Category),
+				zap.Strings("tags", result.Tags),
+				zap.String("description", result.Description))
+			return result, nil
+		}
+
+		lastErr = err
+		h.logger.Warn("AI回复不符合要求",
+			zap.Int("attempt", attempt+1),
+			zap.String("content", resp.Content),
+			zap.Error(err))
+
+		// 把上一次的回复和错误原因加入对话，让AI修正
+		req.History = append(req.History,
+			utils.Message{Role: "user", Content: req.Prompt},
+			utils.Message{Role: "assistant", Content: resp.Content},
+		)
+		req.Prompt = repairPrompt(err, spec)
+	}
+
+	return nil, fmt.Errorf("AI回复不符合要求: %w", lastErr)
+}
+
+// repairPrompt 构造要求AI修正回复的提示
+func repairPrompt(err error, spec analysisSpec) string {
+	return fmt.Sprintf(`你的回复不符合要求：%s
+请修正后重新回复，只返回JSON对象，不要包含其他内容：
+- category 必须是以下分类之一：%s
+- tags 是包含%d个字符串的数组
+- description 是非空字符串`, err.Error(), strings.Join(spec.Categories, "、"), spec.TagCount)
+}
+
+// parseAnalysisResult 解析并校验AI的回复
+func parseAnalysisResult(content string, spec analysisSpec) (*AIAnalysisResult, error) {
+	data, err := extractJSONObject(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Category    *string         `json:"category"`
+		Tags        json.RawMessage `json:"tags"`
+		Description *string         `json:"description"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("回复不是有效的JSON对象: %v", err)
+	}
+
+	var result AIAnalysisResult
+	if raw.Category == nil || strings.TrimSpace(*raw.Category) == "" {
+		return nil, fmt.Errorf("缺少 category 字段")
+	}
+	result.Category = strings.TrimSpace(*raw.Category)
+	if !containsString(spec.Categories, result.Category) {
+		return nil, fmt.Errorf("分类 %q 不在可选分类中", result.Category)
+	}
+
+	if len(raw.Tags) == 0 {
+		return nil, fmt.Errorf("缺少 tags 字段")
+	}
+	var tags []string
+	if err := json.Unmarshal(raw.Tags, &tags); err != nil {
+		return nil, fmt.Errorf("tags 必须是字符串数组")
+	}
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !containsString(result.Tags, tag) {
+			result.Tags = append(result.Tags, tag)
+		}
+	}
+	if len(result.Tags) == 0 {
+		return nil, fmt.Errorf("tags 不能为空")
+	}
+	// 限制标签数量为模板要求的数量
+	if spec.TagCount > 0 && len(result.Tags) > spec.TagCount {
+		result.Tags = result.Tags[:spec.TagCount]
+	}
+
+	if raw.Description == nil || strings.TrimSpace(*raw.Description) == "" {
+		return nil, fmt.Errorf("缺少 description 字段")
+	}
+	result.Description = strings.TrimSpace(*raw.Description)
+
+	return &result, nil
+}
+
+// extractJSONObject 从回复中取出JSON对象，兼容代码块和前后的说明文字，不修改JSON内容
+func extractJSONObject(content string) ([]byte, error) {
+	content = strings.TrimSpace(content)
+	if json.Valid([]byte(content)) {
+		return []byte(content), nil
+	}
+
+	// 从第一个 { 开始解码一个完整的JSON值，忽略其后的内容
+	start := strings.Index(content, "{")
+	if start < 0 {
+		return nil, fmt.Errorf("回复中没有JSON对象")
+	}
+	var value json.RawMessage
+	dec := json.NewDecoder(strings.NewReader(content[start:]))
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("回复不是有效的JSON: %v", err)
+	}
+	return bytes.TrimSpace(value), nil
+}
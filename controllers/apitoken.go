@@ -0,0 +1,323 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github-stars-manager/repository"
+	"github-stars-manager/session"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// API令牌的权限
+const (
+	// ScopeRead 读取仓库、统计等数据
+	ScopeRead = "read"
+	// ScopeWrite 修改标签、设置、导入等
+	ScopeWrite = "write"
+	// ScopeAI 调用AI分析和问答
+	ScopeAI = "ai"
+	// ScopeSync 同步star、检查发布、备份和标签同步
+	ScopeSync = "sync"
+)
+
+const (
+	// apiTokenPrefix API令牌的前缀，便于识别泄露的令牌
+	apiTokenPrefix = "gsm_"
+	// maxAPITokenName 令牌名称的最大长度
+	maxAPITokenName = 100
+	// apiTokenTouchInterval 最后使用时间的更新间隔，避免每个请求都写文件
+	apiTokenTouchInterval = time.Minute
+)
+
+// APITokenScopes 所有可选的权限
+var APITokenScopes = []string{ScopeRead, ScopeWrite, ScopeAI, ScopeSync}
+
+// apiTokenRouteScopes 不按请求方法推断权限的接口，键为“方法 路由”
+//
+// 其余接口GET请求需要 read 权限，其他请求需要 write 权限。值为空表示只能使用登录会话访问。
+var apiTokenRouteScopes = map[string]string{
+	// 设置中包含密钥
+	"GET /api/settings":                  ScopeWrite,
+	"POST /api/repos/:id/prompt-preview": ScopeRead,
+	"POST /api/repos/:id/analyze":        ScopeAI,
+	"POST /api/ask":                      ScopeAI,
+	"POST /api/test-openai":              ScopeAI,
+	"POST /api/sync":                     ScopeSync,
+	"GET /api/sync-progress":             ScopeSync,
+	"POST /api/releases/check":           ScopeSync,
+	"GET /api/backups":                   ScopeSync,
+	"POST /api/backups":                  ScopeSync,
+	"POST /api/backups/:name/restore":    ScopeSync,
+	"GET /api/tag-sync":                  ScopeSync,
+	"POST /api/tag-sync":                 ScopeSync,
+	"DELETE /api/tag-sync/conflicts":     ScopeSync,
+	// 令牌不能用来管理令牌
+	"GET /api/tokens":        "",
+	"POST /api/tokens":       "",
+	"DELETE /api/tokens/:id": "",
+}
+
+// APITokenView 返回给前端的令牌信息，不包括哈希值
+type APITokenView struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	ExpiresAt  string   `json:"expires_at"`
+	LastUsedAt string   `json:"last_used_at"`
+	Expired    bool     `json:"expired"`
+}
+
+// newAPITokenView 生成令牌信息
+func newAPITokenView(t repository.APIToken, now time.Time) APITokenView {
+	return APITokenView{
+		ID:         t.ID,
+		Name:       t.Name,
+		Scopes:     t.Scopes,
+		CreatedAt:  t.CreatedAt,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		Expired:    t.Expired(now),
+	}
+}
+
+// requiredScope 返回接口需要的权限，第二个返回值为false表示只能使用登录会话访问
+func requiredScope(method, route string) (string, bool) {
+	if scope, ok := apiTokenRouteScopes[method+" "+route]; ok {
+		return scope, scope != ""
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return ScopeRead, true
+	}
+	return ScopeWrite, true
+}
+
+// bearerToken 返回Authorization请求头中的Bearer令牌
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[7:]), true
+}
+
+// authenticateAPIToken 使用Bearer令牌认证，成功时把令牌对应的会话信息存入context
+//
+// 令牌本身不包含GitHub access token，需要访问GitHub的接口使用该用户已登录会话的token，
+// 没有已登录的会话时这些接口返回409，不会使用服务器配置的 GITHUB_TOKEN。
+func (h *AuthHandler) authenticateAPIToken(c *gin.Context, token string) {
+	tokens, err := h.repo.GetAPITokens()
+	if err != nil {
+		h.logger.Error("加载API令牌失败", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "加载API令牌失败"})
+		return
+	}
+
+	hash := hashToken(token)
+	var matched *repository.APIToken
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t.TokenHash), []byte(hash)) == 1 {
+			t := t
+			matched = &t
+			break
+		}
+	}
+	now := time.Now()
+	if matched == nil || matched.Expired(now) {
+		h.logger.Warn("API令牌无效或已过期", zap.String("path", c.Request.URL.Path))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API令牌无效或已过期"})
+		return
+	}
+
+	// 从用户白名单中移除或离开允许的组织后令牌失效
+	if allowed, verified := h.tokenUserAllowed(matched.UserName); !verified {
+		h.logger.Warn("无法确认API令牌用户的组织成员身份", zap.String("user", matched.UserName))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "无法确认API令牌用户的组织成员身份，请先在浏览器中登录"})
+		return
+	} else if !allowed {
+		h.logger.Warn("API令牌的用户不在登录白名单中", zap.String("user", matched.UserName))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API令牌的用户没有访问权限"})
+		return
+	}
+
+	scope, allowed := requiredScope(c.Request.Method, c.FullPath())
+	if !allowed {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "该接口只能在登录后访问，不能使用API令牌"})
+		return
+	}
+	if !matched.HasScope(scope) {
+		h.logger.Warn("API令牌权限不足", zap.String("id", matched.ID), zap.String("scope", scope), zap.String("path", c.Request.URL.Path))
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API令牌缺少 " + scope + " 权限"})
+		return
+	}
+
+	if lastUsed, err := time.Parse(time.RFC3339, matched.LastUsedAt); err != nil || now.Sub(lastUsed) >= apiTokenTouchInterval {
+		if err := h.repo.TouchAPIToken(matched.ID, now.Format(time.RFC3339)); err != nil {
+			h.logger.Warn("更新API令牌使用时间失败", zap.Error(err))
+		}
+	}
+
+	sess := &session.SessionData{}
+	if id, active := session.ForUser(matched.UserName); active != nil {
+		// 会话的token可能即将过期，刷新失败时不使用该会话
+		if fresh, err := h.app.Fresh(id); err == nil {
+			sess.AccessToken = fresh.AccessToken
+			sess.Scopes = fresh.Scopes
+		}
+	}
+	sess.UserName = matched.UserName
+	sess.AvatarURL = matched.AvatarURL
+	sess.Role = h.config.RoleFor(matched.UserName)
+	h.touchUser(matched.UserName)
+	c.Set("session", sess)
+	c.Set("api_token", matched)
+	c.Next()
+}
+
+// ListAPITokens 列出当前用户的API令牌，按创建时间从新到旧
+func (h *AuthHandler) ListAPITokens(c *gin.Context) {
+	s, _ := c.Get("session")
+	sess := s.(*session.SessionData)
+
+	tokens, err := h.repo.GetAPITokens()
+	if err != nil {
+		h.logger.Error("加载API令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载API令牌失败"})
+		return
+	}
+	now := time.Now()
+	views := []APITokenView{}
+	for _, t := range tokens {
+		if t.UserName == sess.UserName {
+			views = append(views, newAPITokenView(t, now))
+		}
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].CreatedAt > views[j].CreatedAt })
+	c.JSON(http.StatusOK, gin.H{"tokens": views, "scopes": APITokenScopes})
+}
+
+// CreateAPIToken 创建API令牌，令牌明文只在此时返回一次
+func (h *AuthHandler) CreateAPIToken(c *gin.Context) {
+	s, _ := c.Get("session")
+	sess := s.(*session.SessionData)
+
+	var body struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+		// ExpiresInDays 有效天数，0表示不过期
+		ExpiresInDays int `json:"expires_in_days"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据格式错误"})
+		return
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" || len(name) > maxAPITokenName {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "令牌名称不能为空且不能超过100个字符"})
+		return
+	}
+	if body.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "有效天数不能为负数"})
+		return
+	}
+	scopes, ok := normalizeScopes(body.Scopes)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "权限只能是 read、write、ai、sync，且至少选择一个"})
+		return
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		h.logger.Error("生成API令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成API令牌失败"})
+		return
+	}
+	id, err := generateToken()
+	if err != nil {
+		h.logger.Error("生成API令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成API令牌失败"})
+		return
+	}
+	token := apiTokenPrefix + secret
+	now := time.Now()
+	record := &repository.APIToken{
+		ID:        id[:16],
+		Name:      name,
+		UserName:  sess.UserName,
+		AvatarURL: sess.AvatarURL,
+		TokenHash: hashToken(token),
+		Scopes:    scopes,
+		CreatedAt: now.Format(time.RFC3339),
+	}
+	if body.ExpiresInDays > 0 {
+		record.ExpiresAt = now.AddDate(0, 0, body.ExpiresInDays).Format(time.RFC3339)
+	}
+	if err := h.repo.SaveAPIToken(record); err != nil {
+		h.logger.Error("保存API令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存API令牌失败"})
+		return
+	}
+
+	h.logger.Info("创建API令牌", zap.String("user", sess.UserName), zap.String("id", record.ID), zap.Strings("scopes", scopes))
+	c.JSON(http.StatusOK, gin.H{
+		"token":   token,
+		"details": newAPITokenView(*record, now),
+	})
+}
+
+// DeleteAPIToken 撤销当前用户的API令牌
+func (h *AuthHandler) DeleteAPIToken(c *gin.Context) {
+	s, _ := c.Get("session")
+	sess := s.(*session.SessionData)
+	id := c.Param("id")
+
+	tokens, err := h.repo.GetAPITokens()
+	if err != nil {
+		h.logger.Error("加载API令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载API令牌失败"})
+		return
+	}
+	token, ok := tokens[id]
+	if !ok || token.UserName != sess.UserName {
+		c.JSON(http.StatusNotFound, gin.H{"error": "令牌不存在"})
+		return
+	}
+	if err := h.repo.DeleteAPIToken(id); err != nil {
+		h.logger.Error("删除API令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除API令牌失败"})
+		return
+	}
+	h.logger.Info("撤销API令牌", zap.String("user", sess.UserName), zap.String("id", id))
+	c.JSON(http.StatusOK, gin.H{"msg": "令牌已撤销"})
+}
+
+// normalizeScopes 检查并去重权限，按 APITokenScopes 的顺序返回
+func normalizeScopes(scopes []string) ([]string, bool) {
+	selected := make(map[string]bool)
+	for _, s := range scopes {
+		known := false
+		for _, scope := range APITokenScopes {
+			if s == scope {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, false
+		}
+		selected[s] = true
+	}
+	var result []string
+	for _, scope := range APITokenScopes {
+		if selected[scope] {
+			result = append(result, scope)
+		}
+	}
+	return result, len(result) > 0
+}
@@ -0,0 +1,305 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxCachedReadmeBytes 缓存的README最多保留的字节数
+	maxCachedReadmeBytes = 16000
+	// defaultAskSources 问答默认检索的仓库数量
+	defaultAskSources = 8
+	// maxAskSources 问答最多检索的仓库数量
+	maxAskSources = 20
+	// maxAskContextBytes 问答提示中仓库资料的最大字节数
+	maxAskContextBytes = 12000
+	// maxAskReadmeBytes 每个仓库的README片段的最大字节数
+	maxAskReadmeBytes = 1200
+	// maxAskQuestionBytes 问题的最大字节数
+	maxAskQuestionBytes = 2000
+	// defaultSearchResults 搜索默认返回的仓库数量
+	defaultSearchResults = 20
+	// maxSearchResults 搜索最多返回的仓库数量
+	maxSearchResults = 100
+)
+
+// askSystemPrompt 问答的系统提示
+const askSystemPrompt = `你是用户的GitHub星标仓库助手。只根据提供的仓库资料回答问题，不要编造资料中没有的仓库或功能。
+引用仓库时使用资料中的编号，例如 [1]、[2]。如果资料中没有能回答问题的仓库，请直接说明。
+使用与问题相同的语言回答。`
+
+// citationPattern 匹配回答中的引用编号
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// AskSource 问答检索到的仓库
+type AskSource struct {
+	// Index 在提示中的编号，从1开始
+	Index       int      `json:"index"`
+	ID          int64    `json:"id"`
+	FullName    string   `json:"full_name"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Score       float64  `json:"score"`
+}
+
+// Ask 根据星标仓库的本地资料回答问题，通过SSE流式返回
+//
+// 事件依次为 sources（检索到的仓库）、answer（回答内容片段）、citations（回答引用的仓库），
+// 最后是 done；出错时发送 error 事件。stream 为true时逐段转发AI的回复，否则收到完整回复后一次发送。
+func (h *AIHandler) Ask(c *gin.Context) {
+	var body struct {
+		Question string `json:"question"`
+		// Limit 检索的仓库数量，默认8
+		Limit int `json:"limit"`
+		// Stream 逐段返回AI的回复
+		Stream bool `json:"stream"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据格式错误"})
+		return
+	}
+	question := strings.TrimSpace(body.Question)
+	if question == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "问题不能为空"})
+		return
+	}
+	if len(question) > maxAskQuestionBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "问题过长"})
+		return
+	}
+	limit := body.Limit
+	if limit <= 0 {
+		limit = defaultAskSources
+	}
+	if limit > maxAskSources {
+		limit = maxAskSources
+	}
+
+	settings, err := h.settingsCli.LoadSettings()
+	if err != nil {
+		h.logger.Error("加载设置失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载设置失败"})
+		return
+	}
+	provider, err := h.llmCli.Provider(settings)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "AI配置不完整，请先在设置中配置AI参数: " + err.Error()})
+		return
+	}
+
+	sources, err := h.retrieveSources(question, limit)
+	if err != nil {
+		h.logger.Error("检索仓库失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检索仓库失败"})
+		return
+	}
+	prompt, included := buildAskPrompt(question, sources)
+	sources = sources[:included]
+	h.logger.Info("问答检索完成", zap.String("question", question), zap.Int("sources", len(sources)))
+
+	// 开始SSE响应
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	send := func(event string, data interface{}) {
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+	}
+
+	views := make([]AskSource, len(sources))
+	for i, s := range sources {
+		views[i] = newAskSource(i+1, s)
+	}
+	send("sources", views)
+
+	if len(sources) == 0 {
+		send("answer", gin.H{"content": "没有在星标仓库中找到与问题相关的仓库。"})
+		send("done", gin.H{})
+		return
+	}
+
+	exceeded, err := aiBudgetExceeded(h.repo, settings.AI)
+	if err != nil {
+		h.logger.Warn("计算本月AI费用失败", zap.Error(err))
+	}
+	if exceeded {
+		send("error", gin.H{"error": errAIBudgetExceeded.Error()})
+		return
+	}
+
+	req := utils.CompletionRequest{
+		System: askSystemPrompt,
+		Prompt: prompt,
+	}
+	if body.Stream {
+		req.OnDelta = func(delta string) {
+			send("answer", gin.H{"content": delta})
+		}
+	}
+	metered := newMeteredProvider(provider, h.repo, h.logger, UsagePurposeAsk, 0)
+	resp, err := metered.Complete(c.Request.Context(), req)
+	if errors.Is(err, context.Canceled) {
+		h.logger.Info("客户端已断开，取消问答")
+		return
+	}
+	if err != nil {
+		h.logger.Error("问答调用AI失败", zap.Error(err))
+		send("error", gin.H{"error": "调用AI服务失败: " + err.Error()})
+		return
+	}
+	if !body.Stream {
+		send("answer", gin.H{"content": resp.Content})
+	}
+
+	// 在回答末尾附上引用仓库的链接
+	cited := citedSources(resp.Content, views)
+	send("answer", gin.H{"content": formatCitations(cited)})
+	send("citations", cited)
+	send("done", gin.H{})
+}
+
+// Search 按相关度搜索星标仓库，使用与问答相同的检索方式，不调用AI
+func (h *AIHandler) Search(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "搜索内容不能为空"})
+		return
+	}
+	if len(query) > maxAskQuestionBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "搜索内容过长"})
+		return
+	}
+	limit := defaultSearchResults
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit参数错误"})
+			return
+		}
+		limit = n
+	}
+	if limit > maxSearchResults {
+		limit = maxSearchResults
+	}
+
+	sources, err := h.retrieveSources(query, limit)
+	if err != nil {
+		h.logger.Error("检索仓库失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检索仓库失败"})
+		return
+	}
+	results := make([]AskSource, len(sources))
+	for i, s := range sources {
+		results[i] = newAskSource(i+1, s)
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// retrieveSources 从本地数据中检索与问题相关的仓库
+func (h *AIHandler) retrieveSources(question string, limit int) ([]RetrievedRepo, error) {
+	repos, err := h.repo.GetReposWithTag()
+	if err != nil {
+		return nil, err
+	}
+	tags, err := h.repo.GetRepoTags()
+	if err != nil {
+		return nil, err
+	}
+	readmes, err := h.repo.GetReadmes()
+	if err != nil {
+		return nil, err
+	}
+	return retrieveRepos(question, repos, tags, readmes, limit), nil
+}
+
+// newAskSource 生成返回给前端的仓库信息
+func newAskSource(index int, s RetrievedRepo) AskSource {
+	tags := utils.SplitTags(s.Repo.Tag)
+	if tags == nil {
+		tags = []string{}
+	}
+	return AskSource{
+		Index:       index,
+		ID:          s.Repo.ID,
+		FullName:    s.Repo.FullName(),
+		URL:         s.Repo.HTMLURL,
+		Description: s.Repo.Description,
+		Tags:        tags,
+		Score:       s.Score,
+	}
+}
+
+// buildAskPrompt 构造问答提示，仓库资料按相关度排列，总长度不超过 maxAskContextBytes，同时返回写入提示的仓库数量
+func buildAskPrompt(question string, sources []RetrievedRepo) (string, int) {
+	terms := uniqueStrings(tokenize(question))
+	var context strings.Builder
+	included := 0
+	for i, s := range sources {
+		var entry strings.Builder
+		fmt.Fprintf(&entry, "[%d] %s (%s)\n", i+1, s.Repo.FullName(), s.Repo.HTMLURL)
+		if s.Repo.Description != "" {
+			fmt.Fprintf(&entry, "描述：%s\n", s.Repo.Description)
+		}
+		if s.Repo.Language != "" {
+			fmt.Fprintf(&entry, "语言：%s\n", s.Repo.Language)
+		}
+		if len(s.Repo.Topics) > 0 {
+			fmt.Fprintf(&entry, "主题：%s\n", strings.Join(s.Repo.Topics, ", "))
+		}
+		if tags := utils.SplitTags(s.Repo.Tag); len(tags) > 0 || s.Repo.Category != "" {
+			fmt.Fprintf(&entry, "分类：%s 标签：%s\n", s.Repo.Category, strings.Join(tags, ", "))
+		}
+		if s.Readme != "" {
+			fmt.Fprintf(&entry, "README片段：%s\n", readmeSnippet(s.Readme, terms, maxAskReadmeBytes))
+		}
+		entry.WriteString("\n")
+
+		// 第一个仓库总是保留，之后超过长度限制的仓库不再加入
+		if i > 0 && context.Len()+entry.Len() > maxAskContextBytes {
+			break
+		}
+		context.WriteString(entry.String())
+		included++
+	}
+
+	return fmt.Sprintf("仓库资料：\n\n%s问题：%s", utils.TruncateUTF8(context.String(), maxAskContextBytes), question), included
+}
+
+// citedSources 返回回答中引用的仓库，回答没有引用编号时返回所有检索到的仓库
+func citedSources(answer string, sources []AskSource) []AskSource {
+	seen := make(map[int]bool)
+	var cited []AskSource
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n < 1 || n > len(sources) || seen[n] {
+			continue
+		}
+		seen[n] = true
+		cited = append(cited, sources[n-1])
+	}
+	if len(cited) == 0 {
+		return sources
+	}
+	return cited
+}
+
+// formatCitations 把引用的仓库格式化为Markdown链接列表
+func formatCitations(cited []AskSource) string {
+	var b strings.Builder
+	b.WriteString("\n\n参考：\n")
+	for _, s := range cited {
+		fmt.Fprintf(&b, "- [%d] [%s](%s)\n", s.Index, s.FullName, s.URL)
+	}
+	return b.String()
+}
@@ -1,10 +1,15 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github-stars-manager/config"
+	"github-stars-manager/repository"
 	"github-stars-manager/session"
 	"github-stars-manager/utils"
 	"go.uber.org/zap"
@@ -16,14 +21,30 @@ type AuthHandler struct {
 	config *config.Config
 	logger *zap.Logger
 	githubCli *utils.GithubUtil
+	repo repository.Repository
+	// app 使用GitHub App登录时刷新用户令牌
+	app *GitHubAppAuth
+	// stateKey 签名登录state的密钥
+	stateKey []byte
+	touchMu sync.Mutex
+	// touched 各用户上次记录活动的时间
+	touched map[string]time.Time
+	orgMu sync.Mutex
+	// orgChecks 各用户组织成员身份的最近一次检查结果
+	orgChecks map[string]orgCheck
 }
 
 // NewAuthHandler 创建一个新的AuthHandler实例
-func NewAuthHandler(config *config.Config, logger *zap.Logger, githubCli *utils.GithubUtil) *AuthHandler {
+func NewAuthHandler(config *config.Config, logger *zap.Logger, githubCli *utils.GithubUtil, repo repository.Repository, app *GitHubAppAuth) *AuthHandler {
 	return &AuthHandler{
 		config: config,
 		logger: logger,
 		githubCli: githubCli,
+		repo: repo,
+		app: app,
+		stateKey: newStateKey(config.SessionSecret),
+		touched: make(map[string]time.Time),
+		orgChecks: make(map[string]orgCheck),
 	}
 }
 
@@ -36,6 +57,12 @@ func (h *AuthHandler) LoginPage(c *gin.Context) {
 // TokenLogin 使用token登录
 func (h *AuthHandler) TokenLogin(c *gin.Context) {
 	h.logger.Info("使用token登录")
+	// 防止其他站点让浏览器用攻击者的token登录
+	if !originAllowed(c.Request, h.config.OriginList()) {
+		h.logger.Warn("拒绝来源不允许的token登录请求", zap.String("origin", c.GetHeader("Origin")))
+		c.JSON(http.StatusForbidden, gin.H{"msg": "请求来源不允许"})
+		return
+	}
 	var body struct {
 		Token string `json:"token"`
 	}
@@ -59,86 +86,218 @@ func (h *AuthHandler) TokenLogin(c *gin.Context) {
 		c.JSON(401, gin.H{"msg": "token无效"})
 		return
 	}
+	if !h.authorizeLogin(user.Login, body.Token) {
+		h.logger.Warn("用户不在登录白名单中", zap.String("user", user.Login))
+		c.JSON(http.StatusForbidden, gin.H{"msg": "该GitHub账号没有登录权限，请联系管理员"})
+		return
+	}
 
 	// 创建session
 	sess := session.NewSessionData()
 	sess.AccessToken = body.Token
 	sess.UserName = user.Login
 	sess.AvatarURL = user.AvatarURL
+	sess.Scopes = user.Scopes
+	h.recordLogin(sess)
 	session.SetSession(c, sess)
 
-	h.logger.Info("token登录成功", zap.String("user", user.Login))
+	h.logger.Info("token登录成功", zap.String("user", user.Login), zap.Strings("scopes", user.Scopes.Scopes))
 	c.JSON(http.StatusOK, gin.H{"msg": "登录成功"})
 }
 
 // GitHubLogin GitHub登录
+//
+// 生成绑定到登录cookie的签名state，启用PKCE时同时发送 code_challenge。return_to 参数为登录后跳转的站内地址。
+// 使用GitHub App登录时权限由App的设置决定，不发送 scope 参数。
 func (h *AuthHandler) GitHubLogin(c *gin.Context) {
 	h.logger.Info("GitHub登录")
-	redirectURL := fmt.Sprintf(
-		"https://github.com/login/oauth/authorize?client_id=%s&redirect_uri=%s&scope=read:user,user:email,repo",
-		h.config.GitHubClientID,
-		h.config.RedirectURL,
-	)
-	c.Redirect(http.StatusFound, redirectURL)
+	returnTo := safeReturnTo(c.Query("return_to"))
+
+	verifier, err := newPKCEVerifier()
+	if err != nil {
+		h.logger.Error("生成PKCE验证码失败", zap.Error(err))
+		c.JSON(500, gin.H{"msg": "生成登录参数失败"})
+		return
+	}
+	state, err := signOAuthState(h.stateKey, verifier, returnTo, time.Now())
+	if err != nil {
+		h.logger.Error("生成登录state失败", zap.Error(err))
+		c.JSON(500, gin.H{"msg": "生成登录参数失败"})
+		return
+	}
+	setLoginCookie(c, verifier)
+
+	query := url.Values{
+		"client_id":    {h.config.GitHubClientID},
+		"redirect_uri": {h.config.RedirectURL},
+		"state":        {state},
+	}
+	if !h.app.Enabled() {
+		query.Set("scope", strings.Join(h.config.OAuthScopes(), ","))
+	}
+	if h.config.GitHubOAuthPKCE {
+		query.Set("code_challenge", pkceChallenge(verifier))
+		query.Set("code_challenge_method", "S256")
+	}
+	c.Redirect(http.StatusFound, "https://github.com/login/oauth/authorize?"+query.Encode())
 }
 
 // GitHubCallback GitHub登录回调
+//
+// state 必须由本实例签发、未过期且与浏览器的登录cookie匹配。失败时跳转回登录页并通过 error 参数说明原因。
 func (h *AuthHandler) GitHubCallback(c *gin.Context) {
 	h.logger.Info("GitHub登录回调")
+	verifier, _ := c.Cookie(oauthLoginCookie)
+	// 登录cookie只能使用一次
+	setLoginCookie(c, "")
+
+	state, stateErr := verifyOAuthState(h.stateKey, c.Query("state"), verifier, time.Now())
+	returnTo := ""
+	if stateErr == nil {
+		returnTo = state.ReturnTo
+	}
+
+	// 用户在GitHub上拒绝授权，或者GitHub返回了其他错误
+	if oauthErr := c.Query("error"); oauthErr != "" {
+		h.logger.Warn("GitHub授权失败", zap.String("error", oauthErr), zap.String("description", c.Query("error_description")))
+		code := loginErrorAccessDenied
+		if oauthErr != "access_denied" {
+			code = loginErrorTokenExchange
+		}
+		c.Redirect(http.StatusFound, loginErrorURL(code, returnTo))
+		return
+	}
+	if stateErr != nil {
+		h.logger.Warn("登录state校验失败", zap.Bool("has_cookie", verifier != ""), zap.Error(stateErr))
+		c.Redirect(http.StatusFound, loginErrorURL(loginErrorInvalidState, ""))
+		return
+	}
 	code := c.Query("code")
 	if code == "" {
 		h.logger.Error("缺少code参数")
-		c.JSON(400, gin.H{"msg": "缺少code参数"})
+		c.Redirect(http.StatusFound, loginErrorURL(loginErrorTokenExchange, returnTo))
 		return
 	}
 
 	// 获取access token
-	token, err := h.githubCli.GetAccessToken(h.config.GitHubClientID, h.config.GitHubClientSecret, code)
+	codeVerifier := ""
+	if h.config.GitHubOAuthPKCE {
+		codeVerifier = verifier
+	}
+	token, err := h.githubCli.ExchangeCode(h.config.GitHubClientID, h.config.GitHubClientSecret, code, h.config.RedirectURL, codeVerifier)
 	if err != nil {
-		h.logger.Error("获取access token失败", zap.Error(err))
-		c.JSON(500, gin.H{"msg": "获取access token失败"})
+		var oauthErr *utils.OAuthError
+		if errors.As(err, &oauthErr) {
+			h.logger.Error("GitHub拒绝了授权码", zap.String("error", oauthErr.Code), zap.String("description", oauthErr.Description))
+		} else {
+			h.logger.Error("获取access token失败", zap.Error(err))
+		}
+		c.Redirect(http.StatusFound, loginErrorURL(loginErrorTokenExchange, returnTo))
 		return
 	}
 
 	// 获取用户信息
-	user, err := h.githubCli.GetUserInfo(token)
+	user, err := h.githubCli.GetUserInfo(token.AccessToken)
 	if err != nil {
 		h.logger.Error("获取用户信息失败", zap.Error(err))
-		c.JSON(500, gin.H{"msg": "获取用户信息失败"})
+		c.Redirect(http.StatusFound, loginErrorURL(loginErrorUserInfo, returnTo))
+		return
+	}
+	if !h.authorizeLogin(user.Login, token.AccessToken) {
+		h.logger.Warn("用户不在登录白名单中", zap.String("user", user.Login))
+		c.Redirect(http.StatusFound, loginErrorURL(loginErrorNotAllowed, ""))
 		return
 	}
 
-	// 创建session
+	// 用户可能修改过OAuth App的授权，以实际获得的权限为准
+	for _, scope := range h.config.OAuthScopes() {
+		if !user.Scopes.Has(scope) {
+			h.logger.Warn("GitHub授权缺少申请的权限", zap.String("scope", scope), zap.Strings("granted", user.Scopes.Scopes))
+		}
+	}
+
+	// 创建session，GitHub App的用户令牌会过期，同时保存刷新令牌
 	sess := session.NewSessionData()
-	sess.AccessToken = token
+	sess.ApplyToken(token)
 	sess.UserName = user.Login
 	sess.AvatarURL = user.AvatarURL
+	sess.Scopes = user.Scopes
+	if h.app.Enabled() {
+		installation, err := h.app.Installation(user.Login)
+		if err != nil {
+			h.logger.Warn("查询GitHub App安装失败", zap.String("user", user.Login), zap.Error(err))
+		} else if installation != nil {
+			sess.InstallationID = installation.ID
+		} else {
+			h.logger.Info("GitHub App尚未安装到用户账号", zap.String("user", user.Login))
+		}
+	}
+	h.recordLogin(sess)
 	session.SetSession(c, sess)
 
-	h.logger.Info("GitHub登录成功", zap.String("user", user.Login))
-	c.Redirect(http.StatusFound, "/")
+	h.logger.Info("GitHub登录成功", zap.String("user", user.Login), zap.Strings("scopes", user.Scopes.Scopes))
+	if returnTo == "" {
+		returnTo = "/"
+	}
+	c.Redirect(http.StatusFound, returnTo)
 }
 
-// AuthMiddleware 认证中间件
+// AuthMiddleware 认证中间件，支持会话cookie和 Authorization: Bearer 形式的API令牌
+//
+// 未登录时页面重定向到登录页，/api 下的接口返回JSON格式的401。使用会话cookie修改数据时需要CSRF令牌。
 func (h *AuthHandler) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		sess, err := session.GetSession(c)
-		if err != nil || sess == nil {
+		if token, ok := bearerToken(c); ok {
+			h.authenticateAPIToken(c, token)
+			return
+		}
+
+		// 使用GitHub App登录时，在调用GitHub接口之前刷新即将过期的token
+		sessionID := session.GetSessionID(c)
+		sess, err := h.app.Fresh(sessionID)
+		if err != nil && !errors.Is(err, errSessionExpired) {
+			c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "刷新GitHub登录失败: " + err.Error()})
+			return
+		}
+		if sess == nil {
 			h.logger.Warn("未登录访问受保护资源", zap.String("path", c.Request.URL.Path))
-			c.Redirect(http.StatusFound, "/login")
+			if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未登录或登录已过期"})
+				return
+			}
+			// 登录后回到当前页面
+			target := "/login"
+			if c.Request.Method == http.MethodGet && c.Request.URL.Path != "/" {
+				target += "?" + url.Values{"return_to": {c.Request.URL.RequestURI()}}.Encode()
+			}
+			c.Redirect(http.StatusFound, target)
 			c.Abort()
 			return
 		}
 
+		// 使用cookie认证的请求需要校验CSRF令牌，API令牌不会被浏览器自动携带，不需要校验
+		if !h.checkCSRF(c, sessionID) {
+			return
+		}
+
+		if !sess.Local {
+			h.touchUser(sess.UserName)
+		}
+
 		// 将session信息存储到context中
 		c.Set("session", sess)
 		c.Next()
 	}
 }
 
-// Logout 登出
+// Logout 登出，只接受携带CSRF令牌的POST请求，防止其他站点让用户登出
 func (h *AuthHandler) Logout(c *gin.Context) {
 	h.logger.Info("用户登出")
+	if sessionID := session.GetSessionID(c); sessionID != "" {
+		if _, ok := session.Get(sessionID); ok && !h.checkCSRF(c, sessionID) {
+			return
+		}
+	}
 	session.ClearSession(c)
-	c.Redirect(http.StatusFound, "/login")
+	c.JSON(http.StatusOK, gin.H{"msg": "已登出"})
 }
\ No newline at end of file
@@ -0,0 +1,561 @@
+package controllers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github-stars-manager/config"
+	"github-stars-manager/repository"
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	// backupFormatVersion 备份包的格式版本，格式不兼容时递增
+	backupFormatVersion = 1
+	// backupTimeLayout 备份文件名中的时间格式
+	backupTimeLayout = "20060102-150405"
+	// backupManifestName 备份包中清单文件的名称
+	backupManifestName = "manifest.json"
+	// backupSettingsName 备份包中设置文件的名称
+	backupSettingsName = "settings.yaml"
+	// maxBackupSize 备份包的最大字节数
+	maxBackupSize = 200 << 20
+	// backupCheckInterval 后台检查是否需要定时备份的间隔
+	backupCheckInterval = 15 * time.Minute
+	// backupTimeout 定时备份的超时时间
+	backupTimeout = 10 * time.Minute
+	// preRestoreBackupFile 恢复前保存当前数据的本地文件
+	preRestoreBackupFile = "pre_restore.tar.gz"
+)
+
+// backupNamePattern 备份文件名，只处理符合该格式的文件
+var backupNamePattern = regexp.MustCompile(`^github-stars-manager-(\d{8}-\d{6})\.tar\.gz$`)
+
+// 备份目标
+const (
+	BackupTargetWebDAV = "webdav"
+	BackupTargetS3     = "s3"
+)
+
+var (
+	errWebDAVNotConfigured = errors.New("请先在设置中填写WebDAV服务器地址")
+	errS3NotConfigured     = errors.New("请先在设置中填写S3服务地址和存储桶")
+	errBackupRunning       = errors.New("备份或恢复正在进行，请稍后再试")
+	errUnknownBackupTarget = errors.New("未知的备份目标，可选 webdav 或 s3")
+)
+
+// BackupManifest 备份包中的清单
+type BackupManifest struct {
+	Version   int              `json:"version"`
+	CreatedAt string           `json:"created_at"`
+	Files     []BackupFileInfo `json:"files"`
+}
+
+// BackupFileInfo 备份包中的数据文件
+type BackupFileInfo struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// BackupInfo WebDAV上的备份
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+}
+
+// backupStore 保存备份文件的远程存储
+type backupStore interface {
+	// List 列出符合命名格式的备份，按时间从新到旧
+	List(ctx context.Context) ([]BackupInfo, error)
+	Put(ctx context.Context, name string, data []byte) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// webdavBackupStore 把备份保存在WebDAV目录中
+type webdavBackupStore struct {
+	client *utils.WebDAVClient
+	dir    string
+}
+
+func (s *webdavBackupStore) List(ctx context.Context) ([]BackupInfo, error) {
+	files, err := s.client.List(ctx, s.dir)
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]BackupInfo, 0, len(files))
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+		if info, ok := parseBackupName(f.Name, f.Size); ok {
+			backups = append(backups, info)
+		}
+	}
+	return sortBackups(backups), nil
+}
+
+func (s *webdavBackupStore) Put(ctx context.Context, name string, data []byte) error {
+	if err := s.client.MkdirAll(ctx, s.dir); err != nil {
+		return fmt.Errorf("创建备份目录失败: %w", err)
+	}
+	return s.client.Put(ctx, s.dir+"/"+name, data, "application/gzip")
+}
+
+func (s *webdavBackupStore) Get(ctx context.Context, name string) ([]byte, error) {
+	data, _, err := s.client.Get(ctx, s.dir+"/"+name, maxBackupSize)
+	return data, err
+}
+
+func (s *webdavBackupStore) Delete(ctx context.Context, name string) error {
+	return s.client.Delete(ctx, s.dir+"/"+name)
+}
+
+// s3BackupStore 把备份保存在S3兼容对象存储中，对象键为前缀加备份文件名
+type s3BackupStore struct {
+	client *utils.S3Client
+	prefix string
+}
+
+func (s *s3BackupStore) List(ctx context.Context) ([]BackupInfo, error) {
+	objects, err := s.client.ListObjects(ctx, s.prefix)
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]BackupInfo, 0, len(objects))
+	for _, o := range objects {
+		name := strings.TrimPrefix(o.Key, s.prefix)
+		if info, ok := parseBackupName(name, o.Size); ok {
+			backups = append(backups, info)
+		}
+	}
+	return sortBackups(backups), nil
+}
+
+func (s *s3BackupStore) Put(ctx context.Context, name string, data []byte) error {
+	return s.client.PutObject(ctx, s.prefix+name, data, "application/gzip")
+}
+
+func (s *s3BackupStore) Get(ctx context.Context, name string) ([]byte, error) {
+	return s.client.GetObject(ctx, s.prefix+name, maxBackupSize)
+}
+
+func (s *s3BackupStore) Delete(ctx context.Context, name string) error {
+	return s.client.DeleteObject(ctx, s.prefix+name)
+}
+
+// BackupHandler 处理WebDAV和S3备份和恢复
+type BackupHandler struct {
+	config      *config.Config
+	logger      *zap.Logger
+	repo        repository.Repository
+	settingsCli *utils.SettingsUtil
+	// running 同一时间只允许一个备份或恢复
+	running sync.Mutex
+}
+
+// NewBackupHandler 创建备份处理器实例
+func NewBackupHandler(config *config.Config, logger *zap.Logger, repo repository.Repository, settingsCli *utils.SettingsUtil) *BackupHandler {
+	return &BackupHandler{
+		config:      config,
+		logger:      logger,
+		repo:        repo,
+		settingsCli: settingsCli,
+	}
+}
+
+// ListBackups 列出备份目标上的备份，按时间从新到旧，target 参数默认为 webdav
+func (h *BackupHandler) ListBackups(c *gin.Context) {
+	backups, err := h.Backups(c.Request.Context(), c.DefaultQuery("target", BackupTargetWebDAV))
+	if err != nil {
+		h.respondError(c, "获取备份列表失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, backups)
+}
+
+// CreateBackup 立即备份数据到备份目标
+func (h *BackupHandler) CreateBackup(c *gin.Context) {
+	info, err := h.Backup(c.Request.Context(), c.DefaultQuery("target", BackupTargetWebDAV))
+	if err != nil {
+		h.respondError(c, "备份失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// RestoreBackup 从备份目标上指定的备份恢复数据
+func (h *BackupHandler) RestoreBackup(c *gin.Context) {
+	name := c.Param("name")
+	manifest, err := h.Restore(c.Request.Context(), c.DefaultQuery("target", BackupTargetWebDAV), name)
+	if err != nil {
+		h.respondError(c, "恢复备份失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"name":       name,
+		"created_at": manifest.CreatedAt,
+		"files":      len(manifest.Files),
+	})
+}
+
+// respondError 按错误类型返回对应的状态码
+func (h *BackupHandler) respondError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, errWebDAVNotConfigured), errors.Is(err, errS3NotConfigured), errors.Is(err, errUnknownBackupTarget):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errBackupRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error(message, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message + ": " + err.Error()})
+	}
+}
+
+// RunScheduler 后台定时备份，各备份目标距离最近一次备份超过设置的间隔时备份
+func (h *BackupHandler) RunScheduler() {
+	for {
+		settings, err := h.settingsCli.LoadSettings()
+		if err != nil {
+			h.logger.Warn("加载备份设置失败", zap.Error(err))
+		} else {
+			if settings.WebDAV.BackupEnabled && settings.WebDAV.Url != "" {
+				h.runScheduledBackup(BackupTargetWebDAV, settings.WebDAV.BackupInterval())
+			}
+			if settings.S3.BackupEnabled && settings.S3.Configured() {
+				h.runScheduledBackup(BackupTargetS3, settings.S3.BackupInterval())
+			}
+		}
+		time.Sleep(backupCheckInterval)
+	}
+}
+
+// runScheduledBackup 检查备份目标上最近一次备份的时间，需要时执行一次备份
+func (h *BackupHandler) runScheduledBackup(target string, interval time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), backupTimeout)
+	defer cancel()
+	backups, err := h.Backups(ctx, target)
+	if err != nil {
+		h.logger.Warn("获取备份列表失败，跳过本轮定时备份", zap.String("target", target), zap.Error(err))
+		return
+	}
+	if len(backups) > 0 && time.Since(backups[0].CreatedAt) < interval {
+		return
+	}
+
+	info, err := h.Backup(ctx, target)
+	if err != nil {
+		h.logger.Error("定时备份失败", zap.String("target", target), zap.Error(err))
+		return
+	}
+	h.logger.Info("定时备份完成", zap.String("target", target), zap.String("name", info.Name), zap.Int64("size", info.Size))
+}
+
+// store 加载设置并返回备份目标对应的存储和保留数量
+func (h *BackupHandler) store(target string) (*utils.Settings, backupStore, int, error) {
+	settings, err := h.settingsCli.LoadSettings()
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("加载设置失败: %w", err)
+	}
+	switch target {
+	case BackupTargetWebDAV:
+		if settings.WebDAV.Url == "" {
+			return nil, nil, 0, errWebDAVNotConfigured
+		}
+		store := &webdavBackupStore{client: utils.NewWebDAVClient(settings.WebDAV), dir: settings.WebDAV.BackupDirectory()}
+		return settings, store, settings.WebDAV.BackupKeepCount(), nil
+	case BackupTargetS3:
+		if !settings.S3.Configured() {
+			return nil, nil, 0, errS3NotConfigured
+		}
+		store := &s3BackupStore{client: utils.NewS3Client(settings.S3), prefix: settings.S3.BackupPrefix()}
+		return settings, store, settings.S3.BackupKeepCount(), nil
+	}
+	return nil, nil, 0, errUnknownBackupTarget
+}
+
+// Backups 列出备份目标上的备份，按时间从新到旧
+func (h *BackupHandler) Backups(ctx context.Context, target string) ([]BackupInfo, error) {
+	_, store, _, err := h.store(target)
+	if err != nil {
+		return nil, err
+	}
+	return store.List(ctx)
+}
+
+// Backup 打包数据并上传到备份目标，然后删除超过保留数量的旧备份
+func (h *BackupHandler) Backup(ctx context.Context, target string) (*BackupInfo, error) {
+	if !h.running.TryLock() {
+		return nil, errBackupRunning
+	}
+	defer h.running.Unlock()
+
+	settings, store, keep, err := h.store(target)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	archive, err := h.buildArchive(settings, now)
+	if err != nil {
+		return nil, err
+	}
+
+	name := "github-stars-manager-" + now.Format(backupTimeLayout) + ".tar.gz"
+	if err := store.Put(ctx, name, archive); err != nil {
+		return nil, fmt.Errorf("上传备份失败: %w", err)
+	}
+	h.logger.Info("备份已上传", zap.String("target", target), zap.String("name", name), zap.Int("size", len(archive)))
+
+	// 清理失败不影响本次备份
+	if err := h.pruneBackups(ctx, store, keep); err != nil {
+		h.logger.Warn("清理旧备份失败", zap.String("target", target), zap.Error(err))
+	}
+	return &BackupInfo{Name: name, CreatedAt: now, Size: int64(len(archive))}, nil
+}
+
+// Restore 从备份目标下载并校验指定的备份，把当前数据保存到本地后用备份替换
+//
+// 设置中的密钥、密码、自定义请求头、WebDAV和S3配置保持不变。
+func (h *BackupHandler) Restore(ctx context.Context, target, name string) (*BackupManifest, error) {
+	if !backupNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("备份文件名格式错误: %s", name)
+	}
+	if !h.running.TryLock() {
+		return nil, errBackupRunning
+	}
+	defer h.running.Unlock()
+
+	settings, store, _, err := h.store(target)
+	if err != nil {
+		return nil, err
+	}
+	data, err := store.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("下载备份失败: %w", err)
+	}
+	manifest, files, err := readBackupArchive(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// 恢复前在本地保存当前数据，恢复错了可以手动找回
+	current, err := h.buildArchive(settings, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join("data", preRestoreBackupFile), current, 0600); err != nil {
+		return nil, fmt.Errorf("保存当前数据失败: %w", err)
+	}
+
+	if raw, ok := files[backupSettingsName]; ok {
+		var restored utils.Settings
+		if err := yaml.Unmarshal(raw, &restored); err != nil {
+			return nil, fmt.Errorf("解析备份中的设置失败: %w", err)
+		}
+		err := h.settingsCli.UpdateSettings(func(current *utils.Settings) error {
+			*current = restored.WithSecretsFrom(*current)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("恢复设置失败: %w", err)
+		}
+		delete(files, backupSettingsName)
+	}
+	if err := h.repo.RestoreFiles(files); err != nil {
+		return nil, fmt.Errorf("恢复数据文件失败: %w", err)
+	}
+
+	h.logger.Info("已从备份恢复数据", zap.String("name", name), zap.String("created_at", manifest.CreatedAt))
+	return manifest, nil
+}
+
+// buildArchive 打包数据文件和去掉密钥的设置
+func (h *BackupHandler) buildArchive(settings *utils.Settings, now time.Time) ([]byte, error) {
+	files, err := h.repo.SnapshotFiles()
+	if err != nil {
+		return nil, fmt.Errorf("读取数据文件失败: %w", err)
+	}
+	raw, err := yaml.Marshal(settings.WithoutSecrets())
+	if err != nil {
+		return nil, fmt.Errorf("序列化设置失败: %w", err)
+	}
+	files[backupSettingsName] = raw
+	return buildBackupArchive(files, now)
+}
+
+// pruneBackups 只保留最新的 keep 个备份
+func (h *BackupHandler) pruneBackups(ctx context.Context, store backupStore, keep int) error {
+	backups, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	if len(backups) <= keep {
+		return nil
+	}
+	for _, b := range backups[keep:] {
+		if err := store.Delete(ctx, b.Name); err != nil {
+			return err
+		}
+		h.logger.Info("已删除旧备份", zap.String("name", b.Name))
+	}
+	return nil
+}
+
+// parseBackupName 从符合命名格式的备份文件名中解析备份时间
+func parseBackupName(name string, size int64) (BackupInfo, bool) {
+	match := backupNamePattern.FindStringSubmatch(name)
+	if match == nil {
+		return BackupInfo{}, false
+	}
+	createdAt, err := time.ParseInLocation(backupTimeLayout, match[1], time.Local)
+	if err != nil {
+		return BackupInfo{}, false
+	}
+	return BackupInfo{Name: name, CreatedAt: createdAt, Size: size}, true
+}
+
+// sortBackups 按时间从新到旧排列备份
+func sortBackups(backups []BackupInfo) []BackupInfo {
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups
+}
+
+// buildBackupArchive 生成tar.gz格式的备份包，包含清单和 data/ 目录下的文件
+func buildBackupArchive(files map[string][]byte, now time.Time) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	manifest := BackupManifest{Version: backupFormatVersion, CreatedAt: now.Format(time.RFC3339)}
+	for _, name := range names {
+		sum := sha256.Sum256(files[name])
+		manifest.Files = append(manifest.Files, BackupFileInfo{
+			Name:   name,
+			Size:   len(files[name]),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化备份清单失败: %w", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := write(backupManifestName, manifestData); err != nil {
+		return nil, fmt.Errorf("写入备份包失败: %w", err)
+	}
+	for _, name := range names {
+		if err := write("data/"+name, files[name]); err != nil {
+			return nil, fmt.Errorf("写入备份包失败: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("写入备份包失败: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("写入备份包失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// readBackupArchive 解析备份包并按清单校验每个文件，只返回已知的数据文件
+func readBackupArchive(data []byte) (*BackupManifest, map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("备份包格式错误: %w", err)
+	}
+	defer gz.Close()
+
+	var manifest *BackupManifest
+	contents := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("备份包格式错误: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		body, err := io.ReadAll(io.LimitReader(tr, maxBackupSize))
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取备份包失败: %w", err)
+		}
+		if header.Name == backupManifestName {
+			manifest = &BackupManifest{}
+			if err := json.Unmarshal(body, manifest); err != nil {
+				return nil, nil, fmt.Errorf("解析备份清单失败: %w", err)
+			}
+			continue
+		}
+		if name, ok := strings.CutPrefix(header.Name, "data/"); ok {
+			contents[name] = body
+		}
+	}
+
+	if manifest == nil {
+		return nil, nil, errors.New("备份包中没有清单文件")
+	}
+	if manifest.Version < 1 || manifest.Version > backupFormatVersion {
+		return nil, nil, fmt.Errorf("不支持的备份格式版本: %d", manifest.Version)
+	}
+
+	allowed := map[string]bool{backupSettingsName: true}
+	for _, name := range repository.BackupFiles {
+		allowed[name] = true
+	}
+	files := make(map[string][]byte)
+	for _, f := range manifest.Files {
+		if !allowed[f.Name] {
+			continue
+		}
+		body, ok := contents[f.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("备份包中缺少文件: %s", f.Name)
+		}
+		sum := sha256.Sum256(body)
+		if hex.EncodeToString(sum[:]) != f.SHA256 {
+			return nil, nil, fmt.Errorf("备份包中的文件已损坏: %s", f.Name)
+		}
+		files[f.Name] = body
+	}
+	return manifest, files, nil
+}
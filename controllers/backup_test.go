@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github-stars-manager/config"
+	"github-stars-manager/repository"
+	"github-stars-manager/utils"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/webdav"
+)
+
+// newBackupTestHandler 在临时目录中创建使用内存WebDAV服务器的备份处理器
+func newBackupTestHandler(t *testing.T, keep int) (*BackupHandler, *webdavBackupStore) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	srv := httptest.NewServer(&webdav.Handler{
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	})
+	t.Cleanup(srv.Close)
+
+	logger := zap.NewNop()
+	repo := repository.NewFileRepository(logger, nil)
+	settingsCli := utils.NewSettingsUtil(logger)
+	settings := &utils.Settings{
+		WebDAV: utils.WebDAVSettings{Url: srv.URL, Password: "webdav-pass", BackupKeep: keep},
+	}
+	if err := settingsCli.SaveSettings(settings); err != nil {
+		t.Fatalf("保存设置失败: %v", err)
+	}
+
+	h := NewBackupHandler(&config.Config{}, logger, repo, settingsCli)
+	store := &webdavBackupStore{client: utils.NewWebDAVClient(settings.WebDAV), dir: settings.WebDAV.BackupDirectory()}
+	return h, store
+}
+
+// backupName 返回指定时间的备份文件名
+func backupName(at time.Time) string {
+	return "github-stars-manager-" + at.Format(backupTimeLayout) + ".tar.gz"
+}
+
+// writeDataFile 直接写入 data/ 目录下的数据文件
+func writeDataFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join("data", name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readDataFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("data", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestBackupArchiveRoundTrip(t *testing.T) {
+	files := map[string][]byte{
+		"repo_tags.json":   []byte(`{"1":{"tags":["go"]}}`),
+		"last_sync.txt":    []byte("2024-01-01T00:00:00Z"),
+		backupSettingsName: []byte("webdav:\n  url: http://example.com\n"),
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	archive, err := buildBackupArchive(files, now)
+	if err != nil {
+		t.Fatalf("buildBackupArchive: %v", err)
+	}
+
+	manifest, got, err := readBackupArchive(archive)
+	if err != nil {
+		t.Fatalf("readBackupArchive: %v", err)
+	}
+	if manifest.Version != backupFormatVersion || manifest.CreatedAt != now.Format(time.RFC3339) {
+		t.Errorf("清单错误: %+v", manifest)
+	}
+	if len(got) != len(files) {
+		t.Fatalf("文件数量 = %d，期望 %d", len(got), len(files))
+	}
+	for name, want := range files {
+		if !bytes.Equal(got[name], want) {
+			t.Errorf("%s = %q，期望 %q", name, got[name], want)
+		}
+	}
+}
+
+func TestBackupUploadAndList(t *testing.T) {
+	h, _ := newBackupTestHandler(t, 3)
+	writeDataFile(t, "repo_tags.json", `{"1":{"tags":["go"]}}`)
+
+	ctx := context.Background()
+	info, err := h.Backup(ctx, BackupTargetWebDAV)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	backups, err := h.Backups(ctx, BackupTargetWebDAV)
+	if err != nil {
+		t.Fatalf("Backups: %v", err)
+	}
+	if len(backups) != 1 || backups[0].Name != info.Name || backups[0].Size != info.Size {
+		t.Fatalf("备份列表 = %+v，期望只有 %+v", backups, info)
+	}
+
+	_, store, _, err := h.store(BackupTargetWebDAV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := store.Get(ctx, info.Name)
+	if err != nil {
+		t.Fatalf("下载备份失败: %v", err)
+	}
+	_, files, err := readBackupArchive(data)
+	if err != nil {
+		t.Fatalf("readBackupArchive: %v", err)
+	}
+	if string(files["repo_tags.json"]) != `{"1":{"tags":["go"]}}` {
+		t.Errorf("repo_tags.json = %q", files["repo_tags.json"])
+	}
+	// 备份中的设置不能包含密码
+	if strings.Contains(string(files[backupSettingsName]), "webdav-pass") {
+		t.Errorf("备份中的设置包含密码: %s", files[backupSettingsName])
+	}
+}
+
+func TestPruneBackupsKeepsNewest(t *testing.T) {
+	h, store := newBackupTestHandler(t, 2)
+	ctx := context.Background()
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	var names []string
+	for i := 0; i < 4; i++ {
+		name := backupName(base.Add(time.Duration(i) * time.Hour))
+		if err := store.Put(ctx, name, []byte("backup")); err != nil {
+			t.Fatalf("上传备份失败: %v", err)
+		}
+		names = append(names, name)
+	}
+	// 不符合命名格式的文件不受影响
+	if err := store.client.Put(ctx, store.dir+"/notes.txt", []byte("keep"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.pruneBackups(ctx, store, 2); err != nil {
+		t.Fatalf("pruneBackups: %v", err)
+	}
+	backups, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[0].Name != names[3] || backups[1].Name != names[2] {
+		t.Fatalf("清理后的备份 = %+v，期望 %s 和 %s", backups, names[3], names[2])
+	}
+	files, err := store.client.List(ctx, store.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, f := range files {
+		found = found || f.Name == "notes.txt"
+	}
+	if !found {
+		t.Error("清理时删除了不是备份的文件")
+	}
+}
+
+func TestRestoreChosenBackup(t *testing.T) {
+	h, store := newBackupTestHandler(t, 5)
+	ctx := context.Background()
+
+	older := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	newer := older.Add(time.Hour)
+	for at, tags := range map[time.Time]string{older: `{"old":true}`, newer: `{"new":true}`} {
+		archive, err := buildBackupArchive(map[string][]byte{
+			"repo_tags.json":   []byte(tags),
+			backupSettingsName: []byte("webdav:\n  url: http://restored.example.com\n  backup_keep: 9\n"),
+		}, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Put(ctx, backupName(at), archive); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeDataFile(t, "repo_tags.json", `{"current":true}`)
+
+	manifest, err := h.Restore(ctx, BackupTargetWebDAV, backupName(older))
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if manifest.CreatedAt != older.Format(time.RFC3339) {
+		t.Errorf("恢复的备份时间 = %s，期望 %s", manifest.CreatedAt, older.Format(time.RFC3339))
+	}
+	if got := readDataFile(t, "repo_tags.json"); got != `{"old":true}` {
+		t.Errorf("恢复后的 repo_tags.json = %s", got)
+	}
+
+	// 恢复前的数据保存在本地
+	data, err := os.ReadFile(filepath.Join("data", preRestoreBackupFile))
+	if err != nil {
+		t.Fatalf("没有保存恢复前的数据: %v", err)
+	}
+	_, files, err := readBackupArchive(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(files["repo_tags.json"]) != `{"current":true}` {
+		t.Errorf("恢复前的 repo_tags.json = %s", files["repo_tags.json"])
+	}
+
+	// WebDAV配置和密码保持不变
+	settings, err := h.settingsCli.LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.WebDAV.Url == "http://restored.example.com" || settings.WebDAV.Password != "webdav-pass" {
+		t.Errorf("恢复覆盖了WebDAV配置: %+v", settings.WebDAV)
+	}
+}
+
+func TestRestoreRejectsCorruptedArchive(t *testing.T) {
+	h, store := newBackupTestHandler(t, 5)
+	ctx := context.Background()
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	archive, err := buildBackupArchive(map[string][]byte{"repo_tags.json": []byte(`{"old":true}`)}, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := rewriteArchive(t, archive, "data/repo_tags.json", []byte(`{"evil":true}`))
+	if _, _, err := readBackupArchive(corrupted); err == nil || !strings.Contains(err.Error(), "已损坏") {
+		t.Fatalf("readBackupArchive 错误 = %v，期望校验失败", err)
+	}
+
+	if err := store.Put(ctx, backupName(at), corrupted); err != nil {
+		t.Fatal(err)
+	}
+	writeDataFile(t, "repo_tags.json", `{"current":true}`)
+	if _, err := h.Restore(ctx, BackupTargetWebDAV, backupName(at)); err == nil {
+		t.Fatal("恢复了校验失败的备份")
+	}
+	if got := readDataFile(t, "repo_tags.json"); got != `{"current":true}` {
+		t.Errorf("校验失败后数据被修改: %s", got)
+	}
+}
+
+// rewriteArchive 替换备份包中一个文件的内容，清单保持不变
+func rewriteArchive(t *testing.T, archive []byte, name string, content []byte) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name == name {
+			body = content
+			header.Size = int64(len(body))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github-stars-manager/repository"
+	"github-stars-manager/session"
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 书签导入结果状态
+const (
+	BookmarkStatusExists     = "exists"      // 本地已存在该仓库
+	BookmarkStatusStarred    = "starred"     // 已通过GitHub star并加入本地
+	BookmarkStatusNotStarred = "not_starred" // 尚未star，未加入本地
+	BookmarkStatusFailed     = "failed"      // star或获取仓库信息失败
+)
+
+// BookmarkImportItem 书签导入中识别出的单个GitHub仓库
+type BookmarkImportItem struct {
+	FullName string `json:"full_name"`
+	URL      string `json:"url"`
+	Title    string `json:"title"`
+	Folder   string `json:"folder"`
+	Status   string `json:"status"`
+	Tagged   bool   `json:"tagged"`
+	Error    string `json:"error,omitempty"`
+}
+
+// BookmarkImportReport 书签导入结果
+type BookmarkImportReport struct {
+	DryRun    bool                 `json:"dry_run"`
+	Bookmarks int                  `json:"bookmarks"`
+	Repos     []BookmarkImportItem `json:"repos"`
+}
+
+// ExportBookmarks 导出 Netscape 书签文件，每个分类一个文件夹，标签写入 TAGS 属性
+func (h *ExportHandler) ExportBookmarks(c *gin.Context) {
+	h.logger.Info("导出书签文件")
+
+	repos, err := loadDisplayRepos(h.repo)
+	if err != nil {
+		h.logger.Error("加载仓库数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载仓库数据失败"})
+		return
+	}
+
+	repos = filterExportRepos(repos, c.Query("category"), c.Query("tag"))
+	if err := sortExportRepos(repos, c.DefaultQuery("sort", "name"), c.Query("order")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var folders []utils.BookmarkFolder
+	folderIndex := make(map[string]int)
+	for _, repo := range repos {
+		category := repo.Category
+		if category == "" {
+			category = uncategorizedName
+		}
+		i, ok := folderIndex[category]
+		if !ok {
+			i = len(folders)
+			folderIndex[category] = i
+			folders = append(folders, utils.BookmarkFolder{Name: category})
+		}
+		folders[i].Bookmarks = append(folders[i].Bookmarks, utils.Bookmark{
+			Title:       repo.FullName(),
+			URL:         repo.HTMLURL,
+			Tags:        utils.SplitTags(repo.Tag),
+			Description: repo.Description,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := utils.WriteBookmarks(&buf, "GitHub Stars", folders); err != nil {
+		h.logger.Error("生成书签文件失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成书签文件失败"})
+		return
+	}
+
+	h.logger.Info("书签文件导出成功", zap.Int("count", len(repos)))
+	c.Header("Content-Disposition", `attachment; filename="stars-bookmarks.html"`)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// ImportBookmarks 从浏览器导出的书签文件中识别GitHub仓库链接
+//
+// star=true 时为尚未star的仓库执行star并加入本地数据；tag=false 时不使用书签所在文件夹名称作为标签。
+func (h *ExportHandler) ImportBookmarks(c *gin.Context) {
+	star := c.Query("star") == "true"
+	tag := c.DefaultQuery("tag", "true") == "true"
+	dryRun := c.Query("dry_run") == "true"
+	h.logger.Info("导入书签文件", zap.Bool("star", star), zap.Bool("tag", tag), zap.Bool("dry_run", dryRun))
+	if star && !dryRun && (!requireGitHubToken(c, "为仓库加星") || !requireGitHubScope(c, utils.ScopePublicRepo, "为仓库加星")) {
+		return
+	}
+
+	data, _, err := readImportBody(c)
+	if err != nil {
+		h.logger.Error("读取书签文件失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取书签文件失败: " + err.Error()})
+		return
+	}
+
+	bookmarks, err := utils.ParseBookmarks(bytes.NewReader(data))
+	if err != nil {
+		h.logger.Error("解析书签文件失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	localRepos, err := h.repo.GetReposWithTag()
+	if err != nil {
+		h.logger.Warn("无法加载本地仓库数据", zap.Error(err))
+		localRepos = []utils.Repo{}
+	}
+	tags, err := h.repo.GetRepoTags()
+	if err != nil {
+		h.logger.Error("加载标签数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载标签数据失败"})
+		return
+	}
+
+	localByName := make(map[string]utils.Repo, len(localRepos))
+	for _, repo := range localRepos {
+		localByName[strings.ToLower(repo.FullName())] = repo
+	}
+
+	var token string
+	if s, exists := c.Get("session"); exists {
+		token = s.(*session.SessionData).AccessToken
+	}
+
+	report := &BookmarkImportReport{DryRun: dryRun, Bookmarks: len(bookmarks), Repos: []BookmarkImportItem{}}
+	seen := make(map[string]bool)
+	tagUpdates := make(map[int64]repository.RepoTag)
+	var newRepos []utils.Repo
+
+	for _, bm := range bookmarks {
+		fullName, ok := utils.GitHubRepoFromURL(bm.URL)
+		if !ok || seen[strings.ToLower(fullName)] {
+			continue
+		}
+		seen[strings.ToLower(fullName)] = true
+
+		item := BookmarkImportItem{FullName: fullName, URL: "https://github.com/" + fullName, Title: bm.Title, Folder: bm.Folder}
+		repo, exists := localByName[strings.ToLower(fullName)]
+		switch {
+		case exists:
+			item.Status = BookmarkStatusExists
+		case !star || dryRun:
+			item.Status = BookmarkStatusNotStarred
+		default:
+			detailed, err := h.starImportedRepo(token, fullName)
+			if err != nil {
+				item.Status = BookmarkStatusFailed
+				item.Error = err.Error()
+				break
+			}
+			repo = *detailed
+			newRepos = append(newRepos, repo)
+			item.Status = BookmarkStatusStarred
+		}
+
+		if tag && bm.Folder != "" && (item.Status == BookmarkStatusExists || item.Status == BookmarkStatusStarred) {
+			current, ok := tagUpdates[repo.ID]
+			if !ok {
+				current = tags[repo.ID]
+				current.ID = repo.ID
+			}
+			folderTag := strings.NewReplacer(",", " ", "，", " ").Replace(bm.Folder)
+			merged := strings.Join(mergeTagLists(utils.SplitTags(current.Tag), []string{folderTag}), ",")
+			if merged != current.Tag {
+				current.Tag = merged
+				tagUpdates[repo.ID] = current
+				item.Tagged = true
+			}
+		}
+		report.Repos = append(report.Repos, item)
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	if len(newRepos) > 0 {
+		if err := h.repo.SaveRepos(append(localRepos, newRepos...)); err != nil {
+			h.logger.Error("保存仓库数据失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存仓库数据失败"})
+			return
+		}
+	}
+	if len(tagUpdates) > 0 {
+		updates := make([]repository.RepoTag, 0, len(tagUpdates))
+		for _, t := range tagUpdates {
+			updates = append(updates, t)
+		}
+		if err := h.repo.SaveRepoTags(updates); err != nil {
+			h.logger.Error("保存标签数据失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存标签数据失败"})
+			return
+		}
+	}
+
+	h.logger.Info("书签导入完成",
+		zap.Int("repos", len(report.Repos)),
+		zap.Int("starred", len(newRepos)),
+		zap.Int("tagged", len(tagUpdates)))
+	c.JSON(http.StatusOK, report)
+}
+
+// starImportedRepo star仓库并获取详细信息
+func (h *ExportHandler) starImportedRepo(token, fullName string) (*utils.Repo, error) {
+	if err := h.githubCli.StarRepo(token, fullName); err != nil {
+		return nil, err
+	}
+	repo, err := h.githubCli.GetRepoDetails(token, fullName)
+	if err != nil {
+		return nil, err
+	}
+	if repo.ID == 0 {
+		return nil, fmt.Errorf("未找到仓库 %s", fullName)
+	}
+	repo.Tag = ""
+	repo.Category = ""
+	repo.StarredAt = time.Now().UTC().Format(time.RFC3339)
+	return repo, nil
+}
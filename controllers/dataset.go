@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github-stars-manager/repository"
+	"github-stars-manager/utils"
+)
+
+// 支持的导入导出格式
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// 导入时的冲突处理策略
+const (
+	StrategyKeepLocal = "keep-local"
+	StrategyOverwrite = "overwrite"
+	StrategyMergeTags = "merge-tags"
+)
+
+// csvHeader CSV导出的列顺序
+var csvHeader = []string{
+	"id", "full_name", "name", "html_url", "description", "language",
+	"languages", "topics", "stargazers_count", "tag", "category", "custom_description",
+}
+
+// DatasetRecord 导入导出使用的单条记录，包含仓库信息和用户标注
+type DatasetRecord struct {
+	ID                int64    `json:"id"`
+	FullName          string   `json:"full_name"`
+	Name              string   `json:"name,omitempty"`
+	HTMLURL           string   `json:"html_url,omitempty"`
+	Description       string   `json:"description,omitempty"`
+	Language          string   `json:"language,omitempty"`
+	Languages         []string `json:"languages,omitempty"`
+	Topics            []string `json:"topics,omitempty"`
+	StargazersCount   int      `json:"stargazers_count,omitempty"`
+	Tag               string   `json:"tag"`
+	Category          string   `json:"category"`
+	CustomDescription string   `json:"custom_description"`
+}
+
+// ImportIssue 导入时单条记录的问题
+type ImportIssue struct {
+	Line    int    `json:"line"`
+	Ref     string `json:"ref"`
+	Message string `json:"message"`
+}
+
+// ImportConflict 本地数据和导入数据不一致的字段
+type ImportConflict struct {
+	Line     int    `json:"line"`
+	ID       int64  `json:"id"`
+	FullName string `json:"full_name"`
+	Field    string `json:"field"`
+	Local    string `json:"local"`
+	Imported string `json:"imported"`
+	Resolved string `json:"resolved"`
+}
+
+// ImportReport 导入的校验与执行报告
+type ImportReport struct {
+	Format    string           `json:"format"`
+	Strategy  string           `json:"strategy"`
+	DryRun    bool             `json:"dry_run"`
+	Applied   bool             `json:"applied"`
+	Total     int              `json:"total"`
+	Matched   int              `json:"matched"`
+	Updated   int              `json:"updated"`
+	Unchanged int              `json:"unchanged"`
+	Unmatched []ImportIssue    `json:"unmatched"`
+	Errors    []ImportIssue    `json:"errors"`
+	Conflicts []ImportConflict `json:"conflicts"`
+}
+
+// newDatasetRecord 将仓库和标签信息合并为一条记录
+func newDatasetRecord(repo utils.Repo, tag repository.RepoTag) DatasetRecord {
+	return DatasetRecord{
+		ID:                repo.ID,
+		FullName:          repo.FullName(),
+		Name:              repo.Name,
+		HTMLURL:           repo.HTMLURL,
+		Description:       repo.Description,
+		Language:          repo.Language,
+		Languages:         repo.Languages,
+		Topics:            repo.Topics,
+		StargazersCount:   repo.StargazersCount,
+		Tag:               tag.Tag,
+		Category:          tag.Category,
+		CustomDescription: tag.Description,
+	}
+}
+
+// encodeDataset 按指定格式写出记录
+func encodeDataset(w io.Writer, format string, records []DatasetRecord) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, record := range records {
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+		for _, r := range records {
+			row := []string{
+				strconv.FormatInt(r.ID, 10), r.FullName, r.Name, r.HTMLURL, r.Description, r.Language,
+				strings.Join(r.Languages, ";"), strings.Join(r.Topics, ";"), strconv.Itoa(r.StargazersCount),
+				r.Tag, r.Category, r.CustomDescription,
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("不支持的格式: %s", format)
+	}
+}
+
+// decodeDataset 解析导入数据，单条记录的解析错误记入issues而不中断整体解析
+func decodeDataset(data []byte, format string) ([]DatasetRecord, []int, []ImportIssue, error) {
+	var records []DatasetRecord
+	var lines []int
+	var issues []ImportIssue
+
+	switch format {
+	case FormatJSON:
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, nil, nil, fmt.Errorf("解析JSON失败: %w", err)
+		}
+		for i := range records {
+			lines = append(lines, i+1)
+		}
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+		line := 0
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			var record DatasetRecord
+			if err := json.Unmarshal([]byte(text), &record); err != nil {
+				issues = append(issues, ImportIssue{Line: line, Message: "解析JSON失败: " + err.Error()})
+				continue
+			}
+			records = append(records, record)
+			lines = append(lines, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, nil, nil, fmt.Errorf("读取NDJSON失败: %w", err)
+		}
+	case FormatCSV:
+		cr := csv.NewReader(bytes.NewReader(data))
+		cr.FieldsPerRecord = -1
+		rows, err := cr.ReadAll()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("解析CSV失败: %w", err)
+		}
+		if len(rows) == 0 {
+			return nil, nil, nil, nil
+		}
+		columns := make(map[string]int)
+		for i, name := range rows[0] {
+			columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+		}
+		if _, ok := columns["id"]; !ok {
+			if _, ok := columns["full_name"]; !ok {
+				return nil, nil, nil, fmt.Errorf("CSV缺少 id 或 full_name 列")
+			}
+		}
+		get := func(row []string, name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		for i, row := range rows[1:] {
+			line := i + 2
+			record := DatasetRecord{
+				FullName:          get(row, "full_name"),
+				Name:              get(row, "name"),
+				HTMLURL:           get(row, "html_url"),
+				Description:       get(row, "description"),
+				Language:          get(row, "language"),
+				Tag:               get(row, "tag"),
+				Category:          get(row, "category"),
+				CustomDescription: get(row, "custom_description"),
+			}
+			if id := get(row, "id"); id != "" {
+				parsed, err := strconv.ParseInt(id, 10, 64)
+				if err != nil {
+					issues = append(issues, ImportIssue{Line: line, Ref: id, Message: "id格式错误"})
+					continue
+				}
+				record.ID = parsed
+			}
+			records = append(records, record)
+			lines = append(lines, line)
+		}
+	default:
+		return nil, nil, nil, fmt.Errorf("不支持的格式: %s", format)
+	}
+
+	return records, lines, issues, nil
+}
+
+// mergeImportedTag 按冲突策略合并本地和导入的标注，返回合并结果和冲突字段
+func mergeImportedTag(local repository.RepoTag, record DatasetRecord, strategy string) (repository.RepoTag, []ImportConflict) {
+	merged := local
+	var conflicts []ImportConflict
+
+	fields := []struct {
+		name     string
+		local    *string
+		imported string
+	}{
+		{"tag", &merged.Tag, record.Tag},
+		{"category", &merged.Category, record.Category},
+		{"description", &merged.Description, record.CustomDescription},
+	}
+
+	for _, field := range fields {
+		localValue := *field.local
+		resolved := localValue
+		switch strategy {
+		case StrategyOverwrite:
+			// 导入数据中缺失的字段不清空本地数据
+			if field.imported != "" {
+				resolved = field.imported
+			}
+		case StrategyMergeTags:
+			if field.name == "tag" {
+				resolved = strings.Join(mergeTagLists(utils.SplitTags(localValue), utils.SplitTags(field.imported)), ",")
+			} else if localValue == "" {
+				resolved = field.imported
+			}
+		default:
+			if localValue == "" {
+				resolved = field.imported
+			}
+		}
+
+		if localValue != "" && field.imported != "" && localValue != field.imported {
+			conflicts = append(conflicts, ImportConflict{
+				Field:    field.name,
+				Local:    localValue,
+				Imported: field.imported,
+				Resolved: resolved,
+			})
+		}
+		*field.local = resolved
+	}
+
+	return merged, conflicts
+}
+
+// mergeTagLists 合并两个标签列表并去重，保持原有顺序
+func mergeTagLists(a, b []string) []string {
+	merged := make([]string, 0, len(a)+len(b))
+	for _, tag := range append(append([]string{}, a...), b...) {
+		if !containsString(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
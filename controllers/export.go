@@ -0,0 +1,558 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github-stars-manager/repository"
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// uncategorizedName 未设置分类的仓库所在分组
+	uncategorizedName = "未分类"
+	// untaggedName 未设置标签的仓库所在分组
+	untaggedName = "未标记"
+	// maxImportSize 导入数据的最大字节数
+	maxImportSize = 32 << 20
+)
+
+// defaultMarkdownTemplate 内置的 awesome-list 模板
+const defaultMarkdownTemplate = `# {{ .Title }}
+
+> 共 {{ .Total }} 个仓库，生成于 {{ .GeneratedAt.Format "2006-01-02 15:04" }}
+
+## 目录
+{{ range .Categories }}
+- [{{ .Name }}](#{{ anchor .Name }}) ({{ .Count }})
+{{- end }}
+{{ range .Categories }}
+## {{ .Name }}
+{{ range .Tags }}
+### {{ .Name }}
+{{ range .Repos }}
+- [{{ .FullName }}]({{ .URL }}) ⭐ {{ .Stars }}{{ if .Language }} ` + "`{{ .Language }}`" + `{{ end }}{{ if .Description }} - {{ .Description }}{{ end }}
+{{- end }}
+{{ end }}
+{{- end }}`
+
+// MarkdownRepo 模板中的单个仓库
+type MarkdownRepo struct {
+	ID          int64
+	Name        string
+	FullName    string
+	URL         string
+	Stars       int
+	Language    string
+	Description string
+	Topics      []string
+	Tags        []string
+	Category    string
+}
+
+// MarkdownTagGroup 模板中按标签分组的仓库
+type MarkdownTagGroup struct {
+	Name  string
+	Repos []MarkdownRepo
+}
+
+// MarkdownCategory 模板中按分类分组的仓库
+type MarkdownCategory struct {
+	Name  string
+	Count int
+	Tags  []MarkdownTagGroup
+}
+
+// MarkdownDocument 传递给Markdown模板的数据
+type MarkdownDocument struct {
+	Title       string
+	GeneratedAt time.Time
+	Total       int
+	Categories  []MarkdownCategory
+}
+
+// ExportHandler 处理数据导出相关的请求
+type ExportHandler struct {
+	repo        repository.Repository
+	logger      *zap.Logger
+	settingsCli *utils.SettingsUtil
+	githubCli   *utils.GithubUtil
+}
+
+// NewExportHandler 创建导出处理器实例
+func NewExportHandler(repo repository.Repository, logger *zap.Logger, settingsCli *utils.SettingsUtil, githubCli *utils.GithubUtil) *ExportHandler {
+	return &ExportHandler{
+		repo:        repo,
+		logger:      logger,
+		settingsCli: settingsCli,
+		githubCli:   githubCli,
+	}
+}
+
+// ExportMarkdown 以 awesome-list 形式导出Markdown文档
+func (h *ExportHandler) ExportMarkdown(c *gin.Context) {
+	h.logger.Info("导出Markdown文档")
+
+	repos, err := loadDisplayRepos(h.repo)
+	if err != nil {
+		h.logger.Error("加载仓库数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载仓库数据失败"})
+		return
+	}
+
+	repos = filterExportRepos(repos, c.Query("category"), c.Query("tag"))
+	if err := sortExportRepos(repos, c.DefaultQuery("sort", "stars"), c.Query("order")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.settingsCli.LoadSettings()
+	if err != nil {
+		h.logger.Error("加载设置失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载设置失败"})
+		return
+	}
+
+	doc := buildMarkdownDocument(repos)
+	doc.Title = settings.Export.MarkdownTitle
+	if doc.Title == "" {
+		doc.Title = "Awesome Stars"
+	}
+
+	content, err := renderMarkdown(settings.Export.MarkdownTemplate, doc)
+	if err != nil {
+		h.logger.Error("渲染Markdown模板失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "渲染Markdown模板失败: " + err.Error()})
+		return
+	}
+
+	h.logger.Info("Markdown文档导出成功", zap.Int("count", doc.Total))
+	c.Header("Content-Disposition", `attachment; filename="stars.md"`)
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", content)
+}
+
+// ExportData 导出合并了标签信息的完整数据集
+func (h *ExportHandler) ExportData(c *gin.Context) {
+	format := c.DefaultQuery("format", FormatJSON)
+	h.logger.Info("导出数据集", zap.String("format", format))
+
+	contentTypes := map[string]string{
+		FormatCSV:    "text/csv; charset=utf-8",
+		FormatJSON:   "application/json; charset=utf-8",
+		FormatNDJSON: "application/x-ndjson; charset=utf-8",
+	}
+	contentType, ok := contentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的格式: " + format})
+		return
+	}
+
+	repos, err := h.repo.GetReposWithTag()
+	if err != nil {
+		h.logger.Error("加载仓库数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载仓库数据失败"})
+		return
+	}
+	tags, err := h.repo.GetRepoTags()
+	if err != nil {
+		h.logger.Error("加载标签数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载标签数据失败"})
+		return
+	}
+
+	records := make([]DatasetRecord, 0, len(repos))
+	for _, repo := range repos {
+		records = append(records, newDatasetRecord(repo, tags[repo.ID]))
+	}
+
+	var buf bytes.Buffer
+	if err := encodeDataset(&buf, format, records); err != nil {
+		h.logger.Error("序列化数据集失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "序列化数据集失败"})
+		return
+	}
+
+	h.logger.Info("数据集导出成功", zap.Int("count", len(records)))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="stars.%s"`, format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// ImportData 导入数据集，按仓库ID或 owner/name 合并用户标注
+//
+// 先校验全部记录，存在错误或 dry_run=true 时只返回报告，不写入任何数据。
+func (h *ExportHandler) ImportData(c *gin.Context) {
+	strategy := c.DefaultQuery("strategy", StrategyKeepLocal)
+	dryRun := c.Query("dry_run") == "true"
+	h.logger.Info("导入数据集", zap.String("strategy", strategy), zap.Bool("dry_run", dryRun))
+
+	if strategy != StrategyKeepLocal && strategy != StrategyOverwrite && strategy != StrategyMergeTags {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的冲突策略: " + strategy})
+		return
+	}
+
+	data, filename, err := readImportBody(c)
+	if err != nil {
+		h.logger.Error("读取导入数据失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取导入数据失败: " + err.Error()})
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = detectImportFormat(filename, c.ContentType(), data)
+	}
+
+	records, lines, issues, err := decodeDataset(data, format)
+	if err != nil {
+		h.logger.Error("解析导入数据失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	repos, err := h.repo.GetReposWithTag()
+	if err != nil {
+		h.logger.Error("加载仓库数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载仓库数据失败"})
+		return
+	}
+	tags, err := h.repo.GetRepoTags()
+	if err != nil {
+		h.logger.Error("加载标签数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载标签数据失败"})
+		return
+	}
+
+	report, updates := planImport(repos, tags, records, lines, strategy)
+	report.Format = format
+	report.DryRun = dryRun
+	report.Errors = append(append([]ImportIssue{}, issues...), report.Errors...)
+	report.Total += len(issues)
+
+	if len(report.Errors) > 0 {
+		h.logger.Warn("导入数据校验失败", zap.Int("errors", len(report.Errors)))
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+	if dryRun || len(updates) == 0 {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	if err := h.repo.SaveRepoTags(updates); err != nil {
+		h.logger.Error("保存导入数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存导入数据失败"})
+		return
+	}
+	report.Applied = true
+
+	h.logger.Info("数据集导入成功", zap.Int("updated", report.Updated))
+	c.JSON(http.StatusOK, report)
+}
+
+// planImport 匹配导入记录并计算需要写入的标签信息
+func planImport(repos []utils.Repo, tags map[int64]repository.RepoTag, records []DatasetRecord, lines []int, strategy string) (*ImportReport, []repository.RepoTag) {
+	report := &ImportReport{
+		Strategy:  strategy,
+		Total:     len(records),
+		Unmatched: []ImportIssue{},
+		Errors:    []ImportIssue{},
+		Conflicts: []ImportConflict{},
+	}
+
+	byID := make(map[int64]utils.Repo, len(repos))
+	byName := make(map[string]utils.Repo, len(repos))
+	for _, repo := range repos {
+		byID[repo.ID] = repo
+		byName[strings.ToLower(repo.FullName())] = repo
+	}
+
+	seen := make(map[int64]int)
+	var updates []repository.RepoTag
+	for i, record := range records {
+		line := lines[i]
+		ref := record.FullName
+		if ref == "" && record.ID != 0 {
+			ref = fmt.Sprint(record.ID)
+		}
+		if record.ID == 0 && record.FullName == "" {
+			report.Errors = append(report.Errors, ImportIssue{Line: line, Message: "缺少 id 或 full_name"})
+			continue
+		}
+
+		repo, ok := byID[record.ID]
+		if !ok {
+			repo, ok = byName[strings.ToLower(record.FullName)]
+		}
+		if !ok {
+			report.Unmatched = append(report.Unmatched, ImportIssue{Line: line, Ref: ref, Message: "本地不存在该仓库"})
+			continue
+		}
+		if prev, dup := seen[repo.ID]; dup {
+			report.Errors = append(report.Errors, ImportIssue{Line: line, Ref: ref, Message: fmt.Sprintf("与第 %d 行重复", prev)})
+			continue
+		}
+		seen[repo.ID] = line
+		report.Matched++
+
+		local := tags[repo.ID]
+		local.ID = repo.ID
+		merged, conflicts := mergeImportedTag(local, record, strategy)
+		for _, conflict := range conflicts {
+			conflict.Line = line
+			conflict.ID = repo.ID
+			conflict.FullName = repo.FullName()
+			report.Conflicts = append(report.Conflicts, conflict)
+		}
+
+		if merged.Tag == local.Tag && merged.Category == local.Category && merged.Description == local.Description {
+			report.Unchanged++
+			continue
+		}
+		report.Updated++
+		updates = append(updates, merged)
+	}
+
+	return report, updates
+}
+
+// readImportBody 读取导入数据，支持 multipart 的 file 字段或原始请求体
+func readImportBody(c *gin.Context) ([]byte, string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return nil, "", err
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, "", err
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		return data, fileHeader.Filename, err
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	return data, "", err
+}
+
+// detectImportFormat 根据文件名、Content-Type 或内容推断导入格式
+func detectImportFormat(filename, contentType string, data []byte) string {
+	switch {
+	case strings.HasSuffix(filename, ".csv"), contentType == "text/csv":
+		return FormatCSV
+	case strings.HasSuffix(filename, ".ndjson"), strings.HasSuffix(filename, ".jsonl"), contentType == "application/x-ndjson":
+		return FormatNDJSON
+	case strings.HasSuffix(filename, ".json"), contentType == "application/json":
+		return FormatJSON
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return FormatJSON
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatNDJSON
+	default:
+		return FormatCSV
+	}
+}
+
+// loadDisplayRepos 加载仓库列表，并使用AI分析或用户编辑的描述替换原始描述
+func loadDisplayRepos(r repository.Repository) ([]utils.Repo, error) {
+	repos, err := r.GetReposWithTag()
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := r.GetRepoTags()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range repos {
+		if tagInfo, ok := tags[repos[i].ID]; ok && tagInfo.Description != "" {
+			repos[i].Description = tagInfo.Description
+		}
+	}
+	return repos, nil
+}
+
+// filterExportRepos 按分类和标签筛选仓库，参数为空时不筛选
+func filterExportRepos(repos []utils.Repo, category, tag string) []utils.Repo {
+	if category == "" && tag == "" {
+		return repos
+	}
+
+	var filtered []utils.Repo
+	for _, repo := range repos {
+		if category != "" && repo.Category != category {
+			continue
+		}
+		if tag != "" && !containsString(utils.SplitTags(repo.Tag), tag) {
+			continue
+		}
+		filtered = append(filtered, repo)
+	}
+	return filtered
+}
+
+// sortExportRepos 按指定字段排序，stars 默认降序，其余默认升序
+func sortExportRepos(repos []utils.Repo, field, order string) error {
+	var less func(a, b utils.Repo) bool
+	desc := false
+	switch field {
+	case "stars":
+		less = func(a, b utils.Repo) bool { return a.StargazersCount < b.StargazersCount }
+		desc = true
+	case "name":
+		less = func(a, b utils.Repo) bool { return strings.ToLower(a.FullName()) < strings.ToLower(b.FullName()) }
+	case "language":
+		less = func(a, b utils.Repo) bool { return a.Language < b.Language }
+	default:
+		return fmt.Errorf("不支持的排序字段: %s", field)
+	}
+
+	switch order {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		return fmt.Errorf("不支持的排序方向: %s", order)
+	}
+
+	sort.SliceStable(repos, func(i, j int) bool {
+		if desc {
+			return less(repos[j], repos[i])
+		}
+		return less(repos[i], repos[j])
+	})
+	return nil
+}
+
+// buildMarkdownDocument 按分类、标签对仓库分组，分组内保持传入的顺序
+func buildMarkdownDocument(repos []utils.Repo) *MarkdownDocument {
+	doc := &MarkdownDocument{
+		GeneratedAt: time.Now(),
+		Total:       len(repos),
+	}
+
+	categoryIndex := make(map[string]int)
+	tagIndex := make(map[string]map[string]int)
+	for _, repo := range repos {
+		category := repo.Category
+		if category == "" {
+			category = uncategorizedName
+		}
+		ci, ok := categoryIndex[category]
+		if !ok {
+			ci = len(doc.Categories)
+			categoryIndex[category] = ci
+			tagIndex[category] = make(map[string]int)
+			doc.Categories = append(doc.Categories, MarkdownCategory{Name: category})
+		}
+		cat := &doc.Categories[ci]
+		cat.Count++
+
+		tags := utils.SplitTags(repo.Tag)
+		item := MarkdownRepo{
+			ID:          repo.ID,
+			Name:        repo.Name,
+			FullName:    repo.FullName(),
+			URL:         repo.HTMLURL,
+			Stars:       repo.StargazersCount,
+			Language:    repo.Language,
+			Description: strings.TrimSpace(repo.Description),
+			Topics:      repo.Topics,
+			Tags:        tags,
+			Category:    repo.Category,
+		}
+
+		groups := tags
+		if len(groups) == 0 {
+			groups = []string{untaggedName}
+		}
+		for _, tag := range groups {
+			ti, ok := tagIndex[category][tag]
+			if !ok {
+				ti = len(cat.Tags)
+				tagIndex[category][tag] = ti
+				cat.Tags = append(cat.Tags, MarkdownTagGroup{Name: tag})
+			}
+			cat.Tags[ti].Repos = append(cat.Tags[ti].Repos, item)
+		}
+	}
+
+	// 分类和标签按名称排序，未分类/未标记放在最后
+	sort.SliceStable(doc.Categories, func(i, j int) bool {
+		return groupLess(doc.Categories[i].Name, doc.Categories[j].Name, uncategorizedName)
+	})
+	for i := range doc.Categories {
+		tags := doc.Categories[i].Tags
+		sort.SliceStable(tags, func(a, b int) bool {
+			return groupLess(tags[a].Name, tags[b].Name, untaggedName)
+		})
+	}
+	return doc
+}
+
+// groupLess 比较分组名称，fallback 分组始终排在最后
+func groupLess(a, b, fallback string) bool {
+	if a == fallback || b == fallback {
+		return b == fallback && a != fallback
+	}
+	return a < b
+}
+
+var anchorStrip = regexp.MustCompile(`[^\p{L}\p{N}\s_-]`)
+
+// markdownAnchor 生成与GitHub一致的标题锚点
+func markdownAnchor(heading string) string {
+	anchor := strings.ToLower(strings.TrimSpace(heading))
+	anchor = anchorStrip.ReplaceAllString(anchor, "")
+	return strings.ReplaceAll(anchor, " ", "-")
+}
+
+// renderMarkdown 使用自定义模板渲染文档，模板为空时使用内置模板
+func renderMarkdown(tmpl string, doc *MarkdownDocument) ([]byte, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		tmpl = defaultMarkdownTemplate
+	}
+
+	t, err := template.New("markdown").Funcs(template.FuncMap{
+		"anchor": markdownAnchor,
+		"join":   strings.Join,
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("解析模板失败: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, doc); err != nil {
+		return nil, fmt.Errorf("执行模板失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// containsString 判断字符串切片中是否包含指定值
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
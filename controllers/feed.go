@@ -0,0 +1,378 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github-stars-manager/repository"
+	"github-stars-manager/session"
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultFeedLimit 订阅源默认返回的条目数
+	defaultFeedLimit = 50
+	// maxFeedLimit 订阅源最多返回的条目数
+	maxFeedLimit = 200
+)
+
+// FeedHandler 处理Atom/RSS订阅源相关的请求
+type FeedHandler struct {
+	repo   repository.Repository
+	logger *zap.Logger
+	// auth 用于检查令牌的用户是否仍在登录白名单或允许的组织中
+	auth *AuthHandler
+}
+
+// NewFeedHandler 创建订阅源处理器实例
+func NewFeedHandler(repo repository.Repository, logger *zap.Logger, auth *AuthHandler) *FeedHandler {
+	return &FeedHandler{
+		repo:   repo,
+		logger: logger,
+		auth:   auth,
+	}
+}
+
+// FeedTokenMiddleware 订阅源认证中间件，订阅器无法携带会话cookie，使用 token 查询参数认证
+func (h *FeedHandler) FeedTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			h.logger.Warn("订阅源请求缺少token", zap.String("path", c.Request.URL.Path))
+			c.String(http.StatusUnauthorized, "缺少token参数")
+			c.Abort()
+			return
+		}
+
+		tokens, err := h.repo.GetFeedTokens()
+		if err != nil {
+			h.logger.Error("加载订阅源令牌失败", zap.Error(err))
+			c.String(http.StatusInternalServerError, "加载订阅源令牌失败")
+			c.Abort()
+			return
+		}
+
+		hash := hashToken(token)
+		for _, t := range tokens {
+			if subtle.ConstantTimeCompare([]byte(t.TokenHash), []byte(hash)) == 1 {
+				// 从用户白名单中移除或离开允许的组织后令牌失效
+				if allowed, verified := h.auth.tokenUserAllowed(t.UserName); !verified {
+					h.logger.Warn("无法确认订阅源令牌用户的组织成员身份", zap.String("user", t.UserName))
+					c.String(http.StatusUnauthorized, "无法确认用户的组织成员身份，请先在浏览器中登录")
+					c.Abort()
+					return
+				} else if !allowed {
+					h.logger.Warn("订阅源令牌的用户不在登录白名单中", zap.String("user", t.UserName))
+					c.String(http.StatusUnauthorized, "令牌的用户没有访问权限")
+					c.Abort()
+					return
+				}
+				c.Set("feed_user", t.UserName)
+				c.Next()
+				return
+			}
+		}
+
+		h.logger.Warn("订阅源token无效", zap.String("path", c.Request.URL.Path))
+		c.String(http.StatusUnauthorized, "token无效")
+		c.Abort()
+	}
+}
+
+// StarredAtom 以Atom格式输出最近star的仓库
+func (h *FeedHandler) StarredAtom(c *gin.Context) {
+	h.writeStarredFeed(c, "atom")
+}
+
+// StarredRSS 以RSS格式输出最近star的仓库
+func (h *FeedHandler) StarredRSS(c *gin.Context) {
+	h.writeStarredFeed(c, "rss")
+}
+
+// writeStarredFeed 生成最近star的仓库订阅源，支持 category、tag、limit 参数
+func (h *FeedHandler) writeStarredFeed(c *gin.Context, format string) {
+	h.logger.Info("生成star订阅源", zap.String("format", format))
+
+	repos, err := loadDisplayRepos(h.repo)
+	if err != nil {
+		h.logger.Error("加载仓库数据失败", zap.Error(err))
+		c.String(http.StatusInternalServerError, "加载仓库数据失败")
+		return
+	}
+
+	limit := defaultFeedLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.String(http.StatusBadRequest, "limit参数错误")
+			return
+		}
+		limit = n
+	}
+	if limit > maxFeedLimit {
+		limit = maxFeedLimit
+	}
+
+	category, tag := c.Query("category"), c.Query("tag")
+	repos = filterExportRepos(repos, category, tag)
+
+	// 只有记录了star时间的仓库才能按时间排序
+	starred := make([]utils.Repo, 0, len(repos))
+	for _, repo := range repos {
+		if !repo.StarredTime().IsZero() {
+			starred = append(starred, repo)
+		}
+	}
+	sort.SliceStable(starred, func(i, j int) bool {
+		return starred[i].StarredTime().After(starred[j].StarredTime())
+	})
+	if len(starred) > limit {
+		starred = starred[:limit]
+	}
+
+	title := "GitHub Stars - 最近star的仓库"
+	if category != "" {
+		title += " - " + category
+	}
+	if tag != "" {
+		title += " - #" + tag
+	}
+
+	base := requestBaseURL(c)
+	feed := &utils.Feed{
+		Title:       title,
+		Link:        base + "/",
+		SelfLink:    base + c.Request.URL.RequestURI(),
+		Description: "最近star的GitHub仓库",
+		Updated:     time.Now(),
+	}
+	if len(starred) > 0 {
+		feed.Updated = starred[0].StarredTime()
+	}
+	for _, repo := range starred {
+		categories := utils.SplitTags(repo.Tag)
+		if repo.Category != "" {
+			categories = append([]string{repo.Category}, categories...)
+		}
+		summary := repo.Description
+		if repo.Language != "" {
+			summary = fmt.Sprintf("[%s] ⭐ %d %s", repo.Language, repo.StargazersCount, summary)
+		}
+		feed.Items = append(feed.Items, utils.FeedItem{
+			ID:         repo.HTMLURL,
+			Title:      repo.FullName(),
+			Link:       repo.HTMLURL,
+			Summary:    strings.TrimSpace(summary),
+			Categories: categories,
+			Published:  repo.StarredTime(),
+			Updated:    repo.StarredTime(),
+		})
+	}
+
+	h.writeFeed(c, format, feed)
+}
+
+// ReleasesAtom 以Atom格式输出跟踪仓库的发布
+func (h *FeedHandler) ReleasesAtom(c *gin.Context) {
+	h.writeReleasesFeed(c, "atom")
+}
+
+// ReleasesRSS 以RSS格式输出跟踪仓库的发布
+func (h *FeedHandler) ReleasesRSS(c *gin.Context) {
+	h.writeReleasesFeed(c, "rss")
+}
+
+// writeReleasesFeed 生成发布订阅源，支持 category、limit 参数
+func (h *FeedHandler) writeReleasesFeed(c *gin.Context, format string) {
+	h.logger.Info("生成发布订阅源", zap.String("format", format))
+
+	items, err := loadReleaseTimeline(h.repo, 0)
+	if err != nil {
+		h.logger.Error("加载发布数据失败", zap.Error(err))
+		c.String(http.StatusInternalServerError, "加载发布数据失败")
+		return
+	}
+
+	limit, err := parseOptionalInt(c.Query("limit"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "limit参数错误")
+		return
+	}
+	if limit == 0 {
+		limit = defaultFeedLimit
+	}
+	if limit > maxFeedLimit {
+		limit = maxFeedLimit
+	}
+
+	category := c.Query("category")
+	title := "GitHub Stars - 发布"
+	if category != "" {
+		title += " - " + category
+	}
+
+	base := requestBaseURL(c)
+	feed := &utils.Feed{
+		Title:       title,
+		Link:        base + "/",
+		SelfLink:    base + c.Request.URL.RequestURI(),
+		Description: "跟踪仓库的最新发布",
+		Updated:     time.Now(),
+	}
+	for _, item := range items {
+		if category != "" && item.Category != category {
+			continue
+		}
+		if len(feed.Items) >= limit {
+			break
+		}
+		name := item.Name
+		if name == "" {
+			name = item.TagName
+		}
+		feed.Items = append(feed.Items, utils.FeedItem{
+			ID:         item.HTMLURL,
+			Title:      item.FullName + " " + name,
+			Link:       item.HTMLURL,
+			Summary:    item.Body,
+			Categories: []string{item.FullName},
+			Published:  item.PublishedTime(),
+			Updated:    item.PublishedTime(),
+		})
+	}
+	if len(feed.Items) > 0 {
+		feed.Updated = feed.Items[0].Published
+	}
+
+	h.writeFeed(c, format, feed)
+}
+
+// writeFeed 按格式输出订阅源
+func (h *FeedHandler) writeFeed(c *gin.Context, format string, feed *utils.Feed) {
+	var buf bytes.Buffer
+	var err error
+	contentType := "application/atom+xml; charset=utf-8"
+	if format == "rss" {
+		contentType = "application/rss+xml; charset=utf-8"
+		err = utils.WriteRSS(&buf, feed)
+	} else {
+		err = utils.WriteAtom(&buf, feed)
+	}
+	if err != nil {
+		h.logger.Error("生成订阅源失败", zap.Error(err))
+		c.String(http.StatusInternalServerError, "生成订阅源失败")
+		return
+	}
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// GetFeedToken 查询当前用户是否已创建订阅源令牌
+func (h *FeedHandler) GetFeedToken(c *gin.Context) {
+	s, _ := c.Get("session")
+	sess := s.(*session.SessionData)
+
+	tokens, err := h.repo.GetFeedTokens()
+	if err != nil {
+		h.logger.Error("加载订阅源令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载订阅源令牌失败"})
+		return
+	}
+
+	token, ok := tokens[sess.UserName]
+	c.JSON(http.StatusOK, gin.H{
+		"exists":     ok,
+		"created_at": token.CreatedAt,
+	})
+}
+
+// CreateFeedToken 创建或轮换当前用户的订阅源令牌，令牌明文只在此时返回一次
+func (h *FeedHandler) CreateFeedToken(c *gin.Context) {
+	s, _ := c.Get("session")
+	sess := s.(*session.SessionData)
+	h.logger.Info("创建订阅源令牌", zap.String("user", sess.UserName))
+
+	token, err := generateToken()
+	if err != nil {
+		h.logger.Error("生成订阅源令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成订阅源令牌失败"})
+		return
+	}
+
+	record := &repository.FeedToken{
+		UserName:  sess.UserName,
+		TokenHash: hashToken(token),
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	if err := h.repo.SaveFeedToken(record); err != nil {
+		h.logger.Error("保存订阅源令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存订阅源令牌失败"})
+		return
+	}
+
+	base := requestBaseURL(c)
+	query := "?token=" + url.QueryEscape(token)
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"created_at": record.CreatedAt,
+		"feeds": gin.H{
+			"starred_atom":  base + "/feeds/starred.atom" + query,
+			"starred_rss":   base + "/feeds/starred.rss" + query,
+			"releases_atom": base + "/feeds/releases.atom" + query,
+			"releases_rss":  base + "/feeds/releases.rss" + query,
+		},
+	})
+}
+
+// DeleteFeedToken 撤销当前用户的订阅源令牌
+func (h *FeedHandler) DeleteFeedToken(c *gin.Context) {
+	s, _ := c.Get("session")
+	sess := s.(*session.SessionData)
+	h.logger.Info("撤销订阅源令牌", zap.String("user", sess.UserName))
+
+	if err := h.repo.DeleteFeedToken(sess.UserName); err != nil {
+		h.logger.Error("删除订阅源令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除订阅源令牌失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "订阅源令牌已撤销"})
+}
+
+// generateToken 生成随机令牌
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken 计算令牌的SHA-256哈希
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestBaseURL 根据请求推断站点地址
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
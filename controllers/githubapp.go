@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github-stars-manager/config"
+	"github-stars-manager/session"
+	"github-stars-manager/utils"
+
+	"go.uber.org/zap"
+)
+
+// appTokenRefreshMargin access token过期前多久开始刷新，给同步等耗时较长的请求留出时间
+const appTokenRefreshMargin = 5 * time.Minute
+
+// errSessionExpired 会话不存在，或者刷新令牌已失效需要重新登录
+var errSessionExpired = errors.New("GitHub登录已过期，请重新登录")
+
+// GitHubAppAuth 使用GitHub App登录时，负责刷新用户令牌和查询App的安装
+//
+// GitHub App的用户令牌8小时后过期，刷新令牌只能使用一次，每次刷新GitHub都会返回新的刷新令牌。
+// 使用OAuth App或个人令牌登录的会话没有刷新令牌，不会被刷新。
+type GitHubAppAuth struct {
+	config    *config.Config
+	logger    *zap.Logger
+	githubCli *utils.GithubUtil
+	// key App的私钥，未配置时不查询安装
+	key *rsa.PrivateKey
+	// refreshing 串行执行刷新，避免并发的请求使用同一个刷新令牌
+	refreshing sync.Mutex
+
+	appMu sync.Mutex
+	// app 缓存的App信息
+	app *utils.GitHubApp
+}
+
+// NewGitHubAppAuth 创建一个新的GitHubAppAuth实例，登录方式无效或私钥无法读取、解析时返回错误
+func NewGitHubAppAuth(config *config.Config, logger *zap.Logger, githubCli *utils.GithubUtil) (*GitHubAppAuth, error) {
+	a := &GitHubAppAuth{
+		config:    config,
+		logger:    logger,
+		githubCli: githubCli,
+	}
+	if err := config.ValidateGitHubAuthMode(); err != nil {
+		return nil, err
+	}
+	if !config.UseGitHubApp() {
+		return a, nil
+	}
+
+	data, err := config.AppPrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("读取GitHub App私钥失败: %w", err)
+	}
+	if data == nil || config.GitHubAppID == "" {
+		logger.Warn("未配置 GITHUB_APP_ID 或GitHub App私钥，登录后不会检查App是否已安装")
+		return a, nil
+	}
+	key, err := utils.ParseAppPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub App私钥无效: %w", err)
+	}
+	a.key = key
+	return a, nil
+}
+
+// Enabled 是否使用GitHub App登录
+func (a *GitHubAppAuth) Enabled() bool {
+	return a.config.UseGitHubApp()
+}
+
+// Fresh 返回会话的最新数据，access token即将过期时先使用刷新令牌续期
+//
+// 会话不存在或刷新令牌被GitHub拒绝时返回 errSessionExpired，后者同时删除会话。刷新请求失败但
+// access token尚未过期时继续使用原来的token，下一次请求会再次尝试刷新。
+func (a *GitHubAppAuth) Fresh(sessionID string) (*session.SessionData, error) {
+	sess, ok := session.Get(sessionID)
+	if !ok || sess == nil {
+		return nil, errSessionExpired
+	}
+	now := time.Now()
+	if !sess.NeedsRefresh(now, appTokenRefreshMargin) {
+		return sess, nil
+	}
+
+	a.refreshing.Lock()
+	defer a.refreshing.Unlock()
+	// 等待期间其他请求可能已经完成了刷新
+	sess, ok = session.Get(sessionID)
+	if !ok || sess == nil {
+		return nil, errSessionExpired
+	}
+	if !sess.NeedsRefresh(now, appTokenRefreshMargin) {
+		return sess, nil
+	}
+	if !sess.RefreshExpiresAt.IsZero() && now.After(sess.RefreshExpiresAt) {
+		a.logger.Info("GitHub刷新令牌已过期", zap.String("user", sess.UserName))
+		session.Delete(sessionID)
+		return nil, errSessionExpired
+	}
+
+	token, err := a.githubCli.RefreshAccessToken(a.config.GitHubClientID, a.config.GitHubClientSecret, sess.RefreshToken)
+	if err != nil {
+		var oauthErr *utils.OAuthError
+		if errors.As(err, &oauthErr) {
+			// 刷新令牌已被使用或撤销，或者用户取消了对App的授权
+			a.logger.Warn("GitHub拒绝刷新用户令牌", zap.String("user", sess.UserName), zap.String("error", oauthErr.Code))
+			session.Delete(sessionID)
+			return nil, errSessionExpired
+		}
+		if now.Before(sess.TokenExpiresAt) {
+			a.logger.Warn("刷新GitHub用户令牌失败，继续使用未过期的token", zap.String("user", sess.UserName), zap.Error(err))
+			return sess, nil
+		}
+		a.logger.Error("刷新GitHub用户令牌失败", zap.String("user", sess.UserName), zap.Error(err))
+		return nil, err
+	}
+
+	// 不修改原来的会话，已经取得旧数据的请求可以继续使用旧token直到它过期
+	updated := *sess
+	updated.ApplyToken(token)
+	session.Set(sessionID, &updated)
+	a.logger.Info("已刷新GitHub用户令牌", zap.String("user", updated.UserName), zap.Time("expires_at", updated.TokenExpiresAt))
+	return &updated, nil
+}
+
+// Installation 查询App在用户账号上的安装，未安装或未配置私钥时返回nil
+func (a *GitHubAppAuth) Installation(login string) (*utils.AppInstallation, error) {
+	if a.key == nil {
+		return nil, nil
+	}
+	jwt, err := utils.AppJWT(a.config.GitHubAppID, a.key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return a.githubCli.GetUserInstallation(jwt, login)
+}
+
+// App 返回App的信息，第一次调用时从GitHub获取，未配置私钥时返回nil
+func (a *GitHubAppAuth) App() (*utils.GitHubApp, error) {
+	if a.key == nil {
+		return nil, nil
+	}
+	a.appMu.Lock()
+	defer a.appMu.Unlock()
+	if a.app != nil {
+		return a.app, nil
+	}
+	jwt, err := utils.AppJWT(a.config.GitHubAppID, a.key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	app, err := a.githubCli.GetApp(jwt)
+	if err != nil {
+		return nil, err
+	}
+	a.app = app
+	return app, nil
+}
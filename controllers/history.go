@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github-stars-manager/repository"
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TrendingRepo 在时间窗口内增长的仓库
+type TrendingRepo struct {
+	ID            int64   `json:"id"`
+	FullName      string  `json:"full_name"`
+	HTMLURL       string  `json:"html_url"`
+	Language      string  `json:"language"`
+	Category      string  `json:"category"`
+	Stars         int     `json:"stars"`
+	StarsGrowth   int     `json:"stars_growth"`
+	GrowthPercent float64 `json:"growth_percent"`
+	ForksGrowth   int     `json:"forks_growth"`
+	From          string  `json:"from"`
+	To            string  `json:"to"`
+}
+
+// GetRepoHistory 获取仓库的star数、fork数和issue数历史，支持 days 参数只返回最近N天
+func (h *StarHandler) GetRepoHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "仓库ID格式错误"})
+		return
+	}
+	days, err := parseOptionalInt(c.Query("days"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days参数错误"})
+		return
+	}
+	h.logger.Info("获取仓库历史数据", zap.Int64("repo_id", id))
+
+	points, err := h.repo.GetRepoHistory(id)
+	if err != nil {
+		h.logger.Error("获取仓库历史数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取仓库历史数据失败"})
+		return
+	}
+
+	if days > 0 {
+		since := time.Now().AddDate(0, 0, -days)
+		filtered := []repository.HistoryPoint{}
+		for _, point := range points {
+			if !point.ParsedTime().Before(since) {
+				filtered = append(filtered, point)
+			}
+		}
+		points = filtered
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"repo_id": id,
+		"points":  points,
+	})
+}
+
+// GetTrending 按时间窗口内的star增长对仓库排序
+//
+// 支持 days（窗口天数，默认30）、limit（默认20）、sort（growth 按增长数，percent 按增长比例）参数。
+func (h *StarHandler) GetTrending(c *gin.Context) {
+	days, err := parseOptionalInt(c.DefaultQuery("days", "30"))
+	if err != nil || days == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days参数错误"})
+		return
+	}
+	limit, err := parseOptionalInt(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit参数错误"})
+		return
+	}
+	sortBy := c.DefaultQuery("sort", "growth")
+	if sortBy != "growth" && sortBy != "percent" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort参数错误，可选 growth、percent"})
+		return
+	}
+	h.logger.Info("获取star增长排行", zap.Int("days", days), zap.String("sort", sortBy))
+
+	repos, err := h.repo.GetReposWithTag()
+	if err != nil {
+		h.logger.Error("加载仓库数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载仓库数据失败"})
+		return
+	}
+	histories, err := h.repo.GetRepoHistories()
+	if err != nil {
+		h.logger.Error("获取仓库历史数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取仓库历史数据失败"})
+		return
+	}
+
+	trending := rankTrending(repos, histories, time.Now().AddDate(0, 0, -days), sortBy)
+	if limit > 0 && len(trending) > limit {
+		trending = trending[:limit]
+	}
+	c.JSON(http.StatusOK, trending)
+}
+
+// recordHistory 记录本次同步时每个仓库的数据点
+func (h *StarHandler) recordHistory(repos []utils.Repo) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	points := make(map[int64]repository.HistoryPoint, len(repos))
+	for _, repo := range repos {
+		points[repo.ID] = repository.HistoryPoint{
+			Time:       now,
+			Stars:      repo.StargazersCount,
+			Forks:      repo.ForksCount,
+			OpenIssues: repo.OpenIssuesCount,
+		}
+	}
+	if err := h.repo.AppendRepoHistory(points); err != nil {
+		h.logger.Error("保存仓库历史数据失败", zap.Error(err))
+	}
+}
+
+// rankTrending 计算每个仓库从窗口开始到最新数据点的增长并排序
+//
+// 窗口开始前最后一个点作为基准，没有时使用窗口内第一个点；少于两个点的仓库不参与排序。
+func rankTrending(repos []utils.Repo, histories map[int64][]repository.HistoryPoint, since time.Time, sortBy string) []TrendingRepo {
+	trending := []TrendingRepo{}
+	for _, repo := range repos {
+		points := histories[repo.ID]
+		if len(points) < 2 {
+			continue
+		}
+
+		base := -1
+		for i, point := range points {
+			if point.ParsedTime().After(since) {
+				if base < 0 {
+					base = i
+				}
+				break
+			}
+			base = i
+		}
+		last := points[len(points)-1]
+		if base < 0 || base == len(points)-1 {
+			continue
+		}
+		first := points[base]
+
+		item := TrendingRepo{
+			ID:          repo.ID,
+			FullName:    repo.FullName(),
+			HTMLURL:     repo.HTMLURL,
+			Language:    repo.Language,
+			Category:    repo.Category,
+			Stars:       last.Stars,
+			StarsGrowth: last.Stars - first.Stars,
+			ForksGrowth: last.Forks - first.Forks,
+			From:        first.Time,
+			To:          last.Time,
+		}
+		if first.Stars > 0 {
+			item.GrowthPercent = float64(int(float64(item.StarsGrowth)/float64(first.Stars)*10000)) / 100
+		}
+		trending = append(trending, item)
+	}
+
+	sort.SliceStable(trending, func(i, j int) bool {
+		if sortBy == "percent" && trending[i].GrowthPercent != trending[j].GrowthPercent {
+			return trending[i].GrowthPercent > trending[j].GrowthPercent
+		}
+		return trending[i].StarsGrowth > trending[j].StarsGrowth
+	})
+	return trending
+}
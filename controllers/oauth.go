@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github-stars-manager/session"
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// oauthLoginCookie 登录前设置的cookie，保存PKCE验证码，state与它绑定
+	oauthLoginCookie = "oauth_login"
+	// oauthStateTTL 登录state的有效期
+	oauthStateTTL = 10 * time.Minute
+)
+
+// 登录回调的错误，作为 /login 页面的 error 参数
+const (
+	loginErrorAccessDenied  = "access_denied"
+	loginErrorInvalidState  = "invalid_state"
+	loginErrorTokenExchange = "token_exchange"
+	loginErrorUserInfo      = "user_info"
+	loginErrorNotAllowed    = "not_allowed"
+)
+
+var errInvalidOAuthState = errors.New("登录state无效或已过期")
+
+// oauthState 签名后作为OAuth的 state 参数传给GitHub
+type oauthState struct {
+	// Binding 登录cookie的哈希值，回调时必须携带同一个cookie
+	Binding string `json:"b"`
+	// ReturnTo 登录后跳转的站内地址
+	ReturnTo string `json:"r,omitempty"`
+	// Expires 过期时间，Unix秒
+	Expires int64 `json:"e"`
+}
+
+// newStateKey 返回签名state的密钥，未配置时随机生成，重启后进行中的登录需要重新开始
+func newStateKey(secret string) []byte {
+	if secret != "" {
+		sum := sha256.Sum256([]byte("oauth-state:" + secret))
+		return sum[:]
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// newPKCEVerifier 生成PKCE验证码，同时用作登录cookie的值
+func newPKCEVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// pkceChallenge 计算S256方式的 code_challenge
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// signOAuthState 生成绑定到登录cookie的签名state
+func signOAuthState(key []byte, verifier, returnTo string, now time.Time) (string, error) {
+	payload, err := json.Marshal(oauthState{
+		Binding:  pkceChallenge(verifier),
+		ReturnTo: returnTo,
+		Expires:  now.Add(oauthStateTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + stateSignature(key, encoded), nil
+}
+
+// verifyOAuthState 校验state的签名、有效期以及与登录cookie的绑定
+func verifyOAuthState(key []byte, state, verifier string, now time.Time) (*oauthState, error) {
+	encoded, signature, ok := strings.Cut(state, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(stateSignature(key, encoded))) {
+		return nil, errInvalidOAuthState
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidOAuthState
+	}
+	var s oauthState
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return nil, errInvalidOAuthState
+	}
+	if now.Unix() > s.Expires || verifier == "" {
+		return nil, errInvalidOAuthState
+	}
+	if subtle.ConstantTimeCompare([]byte(s.Binding), []byte(pkceChallenge(verifier))) != 1 {
+		return nil, errInvalidOAuthState
+	}
+	return &s, nil
+}
+
+// stateSignature 计算state的HMAC签名
+func stateSignature(key []byte, encoded string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// safeReturnTo 只允许跳转到站内路径，其他地址返回空字符串
+func safeReturnTo(returnTo string) string {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.HasPrefix(returnTo, "/\\") {
+		return ""
+	}
+	u, err := url.Parse(returnTo)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return ""
+	}
+	if strings.HasPrefix(u.Path, "/auth/") || u.Path == "/login" || u.Path == "/logout" {
+		return ""
+	}
+	return returnTo
+}
+
+// setLoginCookie 设置或清除（value为空时）登录cookie，只在登录流程的路径下发送
+func setLoginCookie(c *gin.Context, value string) {
+	maxAge := int(oauthStateTTL / time.Second)
+	if value == "" {
+		maxAge = -1
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oauthLoginCookie,
+		Value:    value,
+		Path:     "/auth/github",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   isHTTPS(c),
+		// 从GitHub跳转回来是顶级导航，Lax模式下会携带cookie
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// isHTTPS 判断请求是否通过HTTPS访问，支持反向代理设置的 X-Forwarded-Proto
+func isHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+}
+
+// requireGitHubScope 检查当前会话的GitHub授权是否包含指定权限，缺少时返回403和启用方法
+func requireGitHubScope(c *gin.Context, scope, feature string) bool {
+	s, exists := c.Get("session")
+	if !exists || s.(*session.SessionData).Scopes.Has(scope) {
+		return true
+	}
+	hint := "请在 GITHUB_OAUTH_SCOPES 中加入 " + scope + " 后重新登录"
+	if scope == utils.ScopePublicRepo {
+		hint = "请设置 GITHUB_STAR_ENABLED=true 后重新登录"
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":         fmt.Sprintf("当前GitHub授权没有 %s 权限，无法%s。%s", scope, feature, hint),
+		"missing_scope": scope,
+	})
+	return false
+}
+
+// requireGitHubToken 检查当前会话是否有可用的GitHub token，没有时返回409
+//
+// 使用API令牌访问时，只有令牌的用户在浏览器中有已登录的会话才能访问GitHub，不会借用服务器的 GITHUB_TOKEN。
+func requireGitHubToken(c *gin.Context, feature string) bool {
+	s, exists := c.Get("session")
+	if exists && s.(*session.SessionData).AccessToken != "" {
+		return true
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error":        fmt.Sprintf("没有可用的GitHub授权，无法%s。使用API令牌时需要该用户先在浏览器中登录", feature),
+		"github_login": true,
+	})
+	return false
+}
+
+// loginErrorURL 返回带错误参数的登录页地址
+func loginErrorURL(code, returnTo string) string {
+	query := url.Values{"error": {code}}
+	if returnTo != "" {
+		query.Set("return_to", returnTo)
+	}
+	return "/login?" + query.Encode()
+}
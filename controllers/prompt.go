@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxPromptExistingTags 提示中最多列出的已有标签数量
+const maxPromptExistingTags = 50
+
+// defaultPromptTemplate 内置的仓库分析提示模板
+const defaultPromptTemplate = `你是一个专业的GitHub项目分析师，请分析以下GitHub仓库信息，并用{{.Language}}提供结构化的分析结果。
+
+仓库信息：
+- 名称：{{.Repo.Name}}
+- 描述：{{or .Description "无描述"}}
+- 主要编程语言：{{.Repo.Language}}
+- 编程语言列表：{{if .Languages}}{{join .Languages ", "}}{{else}}无{{end}}
+- 主题标签：{{if .Topics}}{{join .Topics ", "}}{{else}}无{{end}}
+- README内容：{{if .Readme}}{{.Readme}}{{if .ReadmeTruncated}}...(内容过长已截断){{end}}{{else}}无README{{end}}
+
+请根据以上信息提供以下三方面的分析：
+
+1. 分类：请从以下分类中选择最合适的一个分类：
+{{range .Categories}}- {{.}}
+{{end}}
+2. 标签：请提供{{.TagCount}}个最能代表此仓库的标签，尽量使用{{.Language}}，用逗号分隔{{if .ExistingTags}}；如果合适，优先使用已有标签：{{join .ExistingTags ", "}}{{end}}
+
+3. 描述：请用{{.Language}}写一段简洁明了的描述（不超过{{.DescriptionLength}}字），这个描述应该比原始描述更详细和准确
+
+请严格按照以下JSON格式返回结果，不要包含其他内容：
+{
+  "category": "分类名称（如前端、后端等）",
+  "tags": [{{range $i := seq .TagCount}}{{if $i}}, {{end}}"标签{{inc $i}}"{{end}}],
+  "description": "项目描述"
+}`
+
+// PromptData 提示模板可用的变量
+type PromptData struct {
+	// Repo 仓库信息，例如 {{.Repo.Name}}、{{.Repo.FullName}}、{{.Repo.StargazersCount}}
+	Repo utils.Repo
+	// Description 仓库在GitHub上的原始描述
+	Description string
+	Languages   []string
+	Topics      []string
+	// Readme 按 ReadmeBytes 截断后的README内容
+	Readme          string
+	ReadmeTruncated bool
+	// Categories 可选的分类
+	Categories []string
+	// ExistingTags 已经在使用的标签，按使用次数从多到少排列
+	ExistingTags      []string
+	Language          string
+	TagCount          int
+	DescriptionLength int
+}
+
+// promptFuncs 提示模板可用的函数
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+	"seq": func(n int) []int {
+		s := make([]int, n)
+		for i := range s {
+			s[i] = i
+		}
+		return s
+	},
+	"inc": func(i int) int { return i + 1 },
+}
+
+// parsePromptTemplate 解析提示模板，text 为空时使用内置模板
+func parsePromptTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultPromptTemplate
+	}
+	return template.New("prompt").Funcs(promptFuncs).Parse(text)
+}
+
+// newPromptData 组装提示模板变量，README按字节数截断且不会截断多字节字符
+func newPromptData(repo *utils.Repo, readme string, categories, existingTags []string, tmpl utils.PromptTemplate) *PromptData {
+	data := &PromptData{
+		Repo:              *repo,
+		Description:       repo.Description,
+		Languages:         repo.Languages,
+		Topics:            repo.Topics,
+		Readme:            utils.TruncateUTF8(readme, tmpl.ReadmeBytes),
+		Categories:        categories,
+		ExistingTags:      existingTags,
+		Language:          tmpl.Language,
+		TagCount:          tmpl.TagCount,
+		DescriptionLength: tmpl.DescriptionLength,
+	}
+	data.ReadmeTruncated = len(data.Readme) < len(readme)
+	return data
+}
+
+// promptVersion 根据模板内容和参数生成版本号，用于记录AI建议使用的提示
+func promptVersion(tmpl utils.PromptTemplate) string {
+	text := tmpl.Template
+	if text == "" {
+		text = defaultPromptTemplate
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%d\x00%d",
+		text, tmpl.Language, tmpl.TagCount, tmpl.DescriptionLength, tmpl.ReadmeBytes)))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// renderPrompt 使用模板渲染提示
+func renderPrompt(tmpl utils.PromptTemplate, data *PromptData) (string, error) {
+	t, err := parsePromptTemplate(tmpl.Template)
+	if err != nil {
+		return "", fmt.Errorf("解析提示模板失败: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染提示模板失败: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// buildAIAnalysisPrompt 构造AI分析提示
+func (h *StarHandler) buildAIAnalysisPrompt(tmpl utils.PromptTemplate, categories []string, repo *utils.Repo, readme string) (string, *PromptData, error) {
+	var existingTags []string
+	if repos, err := h.repo.GetReposWithTag(); err == nil {
+		existingTags = collectExistingTags(repos, maxPromptExistingTags)
+	}
+
+	data := newPromptData(repo, readme, categories, existingTags, tmpl)
+	prompt, err := renderPrompt(tmpl, data)
+	if err != nil {
+		return "", nil, err
+	}
+	return prompt, data, nil
+}
+
+// PreviewPrompt 渲染指定仓库的分析提示，不调用AI服务
+//
+// 请求体可以提交尚未保存的模板；为空时使用设置中当前的模板。readme=false 时不获取README。
+func (h *StarHandler) PreviewPrompt(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "仓库ID格式错误"})
+		return
+	}
+	h.logger.Info("预览分析提示", zap.Int64("repo_id", id))
+
+	repo, err := h.getRepoByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未找到指定仓库"})
+		return
+	}
+
+	settings, err := h.settingsCli.LoadSettings()
+	if err != nil {
+		h.logger.Error("加载设置失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载设置失败"})
+		return
+	}
+	tmpl := settings.AI.ActivePromptTemplate()
+	if c.Request.ContentLength > 0 {
+		tmpl = utils.PromptTemplate{}
+		if err := c.ShouldBindJSON(&tmpl); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据格式错误"})
+			return
+		}
+		tmpl = tmpl.WithDefaults()
+	}
+
+	var readme string
+	if c.Query("readme") != "false" {
+		readme, err = h.getRepoReadmeWithToken(repo.HTMLURL, c)
+		if err != nil {
+			h.logger.Warn("获取仓库README失败", zap.Error(err))
+		}
+		h.cacheReadme(id, readme)
+	}
+
+	prompt, data, err := h.buildAIAnalysisPrompt(tmpl, settings.AI.CategoryList(), repo, readme)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"name":             tmpl.Name,
+		"prompt":           prompt,
+		"readme_truncated": data.ReadmeTruncated,
+	})
+}
+
+// collectExistingTags 统计已使用的标签，按使用次数从多到少返回前 limit 个
+func collectExistingTags(repos []utils.Repo, limit int) []string {
+	counts := make(map[string]int)
+	for _, repo := range repos {
+		for _, tag := range utils.SplitTags(repo.Tag) {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]string, 0, len(counts))
+	for tag := range counts {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if counts[tags[i]] != counts[tags[j]] {
+			return counts[tags[i]] > counts[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags
+}
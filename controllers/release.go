@@ -0,0 +1,379 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github-stars-manager/config"
+	"github-stars-manager/repository"
+	"github-stars-manager/session"
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultReleaseInterval 后台检查发布的默认间隔
+	defaultReleaseInterval = 6 * time.Hour
+	// minReleaseInterval 后台检查发布的最小间隔
+	minReleaseInterval = 15 * time.Minute
+	// maxReleaseHistory 每个仓库保留的发布数量
+	maxReleaseHistory = 50
+	// maxReleaseBodySize 保存的发布说明的最大字节数
+	maxReleaseBodySize = 4000
+)
+
+// ReleaseTimelineItem 发布时间线中的条目
+type ReleaseTimelineItem struct {
+	RepoID   int64  `json:"repo_id"`
+	FullName string `json:"full_name"`
+	RepoURL  string `json:"repo_url"`
+	Category string `json:"category"`
+	utils.Release
+	New bool `json:"new"`
+}
+
+// ReleaseCheckResult 一次发布检查的结果
+type ReleaseCheckResult struct {
+	Checked  int      `json:"checked"`
+	Updated  int      `json:"updated"`
+	Failed   int      `json:"failed"`
+	NewRepos []string `json:"new_releases"`
+}
+
+// ReleaseHandler 处理发布跟踪相关的请求和后台检查
+type ReleaseHandler struct {
+	repo        repository.Repository
+	logger      *zap.Logger
+	config      *config.Config
+	settingsCli *utils.SettingsUtil
+	githubCli   *utils.GithubUtil
+	app         *GitHubAppAuth
+	checking    sync.Mutex
+}
+
+// NewReleaseHandler 创建发布跟踪处理器实例
+func NewReleaseHandler(
+	repo repository.Repository,
+	logger *zap.Logger,
+	config *config.Config,
+	settingsCli *utils.SettingsUtil,
+	githubCli *utils.GithubUtil,
+	app *GitHubAppAuth,
+) *ReleaseHandler {
+	return &ReleaseHandler{
+		repo:        repo,
+		logger:      logger,
+		config:      config,
+		settingsCli: settingsCli,
+		githubCli:   githubCli,
+		app:         app,
+	}
+}
+
+// GetReleases 获取发布时间线，标记上次查看之后的新发布
+//
+// 支持 days（只返回最近N天）、limit 参数；mark_seen=false 时不更新上次查看时间。
+func (h *ReleaseHandler) GetReleases(c *gin.Context) {
+	h.logger.Info("获取发布时间线")
+	s, _ := c.Get("session")
+	sess := s.(*session.SessionData)
+
+	days, err := parseOptionalInt(c.Query("days"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days参数错误"})
+		return
+	}
+	limit, err := parseOptionalInt(c.DefaultQuery("limit", "100"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit参数错误"})
+		return
+	}
+
+	items, err := loadReleaseTimeline(h.repo, days)
+	if err != nil {
+		h.logger.Error("加载发布数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载发布数据失败"})
+		return
+	}
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+
+	lastVisit, err := h.repo.GetReleaseVisit(sess.UserName)
+	if err != nil {
+		h.logger.Warn("加载上次查看时间失败", zap.Error(err))
+	}
+	lastVisitTime, _ := time.Parse(time.RFC3339, lastVisit)
+	newCount := 0
+	for i := range items {
+		if items[i].PublishedTime().After(lastVisitTime) {
+			items[i].New = true
+			newCount++
+		}
+	}
+
+	if c.Query("mark_seen") != "false" {
+		if err := h.repo.SaveReleaseVisit(sess.UserName, time.Now().Format(time.RFC3339)); err != nil {
+			h.logger.Warn("保存查看时间失败", zap.Error(err))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"last_visit": lastVisit,
+		"new_count":  newCount,
+		"items":      items,
+	})
+}
+
+// CheckReleases 立即检查所有跟踪仓库的最新发布
+func (h *ReleaseHandler) CheckReleases(c *gin.Context) {
+	h.logger.Info("手动检查发布")
+	if !requireGitHubToken(c, "检查发布") {
+		return
+	}
+	s, _ := c.Get("session")
+	sess := s.(*session.SessionData)
+
+	result, err := h.checkReleases(sess.AccessToken)
+	if err != nil {
+		h.logger.Error("检查发布失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查发布失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// WatchRepo 开启或关闭单个仓库的发布跟踪
+func (h *ReleaseHandler) WatchRepo(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "仓库ID格式错误"})
+		return
+	}
+	var body struct {
+		Watch bool `json:"watch"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据格式错误"})
+		return
+	}
+	h.logger.Info("设置仓库发布跟踪", zap.Int64("repo_id", id), zap.Bool("watch", body.Watch))
+
+	err = h.settingsCli.UpdateSettings(func(settings *utils.Settings) error {
+		ids := make([]int64, 0, len(settings.Releases.RepoIDs)+1)
+		for _, repoID := range settings.Releases.RepoIDs {
+			if repoID != id {
+				ids = append(ids, repoID)
+			}
+		}
+		if body.Watch {
+			ids = append(ids, id)
+		}
+		settings.Releases.RepoIDs = ids
+		return nil
+	})
+	if err != nil {
+		h.logger.Error("保存发布跟踪设置失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存设置失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "更新成功", "watch": body.Watch})
+}
+
+// RunWatcher 后台定时检查发布，未启用或没有可用token时跳过本轮
+func (h *ReleaseHandler) RunWatcher() {
+	for {
+		interval := defaultReleaseInterval
+		settings, err := h.settingsCli.LoadSettings()
+		if err != nil {
+			h.logger.Warn("加载发布跟踪设置失败", zap.Error(err))
+		} else {
+			if settings.Releases.IntervalMinutes > 0 {
+				interval = time.Duration(settings.Releases.IntervalMinutes) * time.Minute
+			}
+			if settings.Releases.Enabled {
+				h.runScheduledCheck()
+			}
+		}
+
+		if interval < minReleaseInterval {
+			interval = minReleaseInterval
+		}
+		time.Sleep(interval)
+	}
+}
+
+// runScheduledCheck 使用配置的token或任意已登录管理员的token执行一次检查
+//
+// 普通用户的token只在该用户自己的请求中使用，后台任务不会借用。
+func (h *ReleaseHandler) runScheduledCheck() {
+	token := h.config.GitHubToken
+	if token == "" {
+		if id, active := session.AnyWithRole(config.RoleAdmin); active != nil {
+			if fresh, err := h.app.Fresh(id); err == nil {
+				token = fresh.AccessToken
+			}
+		}
+	}
+	if token == "" {
+		h.logger.Info("没有可用的GitHub token，跳过本轮发布检查")
+		return
+	}
+
+	result, err := h.checkReleases(token)
+	if err != nil {
+		h.logger.Error("定时检查发布失败", zap.Error(err))
+		return
+	}
+	h.logger.Info("定时检查发布完成",
+		zap.Int("checked", result.Checked),
+		zap.Int("updated", result.Updated),
+		zap.Int("failed", result.Failed))
+}
+
+// checkReleases 检查所有跟踪仓库的最新发布并保存新发布
+func (h *ReleaseHandler) checkReleases(token string) (*ReleaseCheckResult, error) {
+	h.checking.Lock()
+	defer h.checking.Unlock()
+
+	settings, err := h.settingsCli.LoadSettings()
+	if err != nil {
+		return nil, fmt.Errorf("加载设置失败: %w", err)
+	}
+	repos, err := h.repo.GetReposWithTag()
+	if err != nil {
+		return nil, fmt.Errorf("加载仓库数据失败: %w", err)
+	}
+	states, err := h.repo.GetReleaseStates()
+	if err != nil {
+		return nil, fmt.Errorf("加载发布数据失败: %w", err)
+	}
+
+	result := &ReleaseCheckResult{NewRepos: []string{}}
+	var updates []repository.ReleaseState
+	for _, repo := range watchedRepos(repos, settings.Releases) {
+		state := states[repo.ID]
+		state.RepoID = repo.ID
+
+		release, etag, notModified, err := h.githubCli.GetLatestRelease(token, repo.FullName(), state.ETag)
+		result.Checked++
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		state.CheckedAt = time.Now().Format(time.RFC3339)
+		state.ETag = etag
+
+		if !notModified && release != nil && !hasRelease(state.Releases, release.TagName) {
+			release.Body = utils.TruncateUTF8(release.Body, maxReleaseBodySize)
+			state.Releases = append([]utils.Release{*release}, state.Releases...)
+			if len(state.Releases) > maxReleaseHistory {
+				state.Releases = state.Releases[:maxReleaseHistory]
+			}
+			result.Updated++
+			result.NewRepos = append(result.NewRepos, repo.FullName())
+		}
+		updates = append(updates, state)
+	}
+
+	if len(updates) > 0 {
+		if err := h.repo.SaveReleaseStates(updates); err != nil {
+			return nil, fmt.Errorf("保存发布数据失败: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// loadReleaseTimeline 加载已保存的发布并按发布时间倒序排列，days 大于0时只返回最近N天
+func loadReleaseTimeline(r repository.Repository, days int) ([]ReleaseTimelineItem, error) {
+	repos, err := r.GetReposWithTag()
+	if err != nil {
+		return nil, err
+	}
+	states, err := r.GetReleaseStates()
+	if err != nil {
+		return nil, err
+	}
+
+	var since time.Time
+	if days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+
+	items := []ReleaseTimelineItem{}
+	for _, repo := range repos {
+		for _, release := range states[repo.ID].Releases {
+			if release.PublishedTime().Before(since) {
+				continue
+			}
+			items = append(items, ReleaseTimelineItem{
+				RepoID:   repo.ID,
+				FullName: repo.FullName(),
+				RepoURL:  repo.HTMLURL,
+				Category: repo.Category,
+				Release:  release,
+			})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].PublishedTime().After(items[j].PublishedTime())
+	})
+	return items, nil
+}
+
+// filterReleasedWithin 只保留最近N天内有发布的仓库
+func filterReleasedWithin(repos []utils.Repo, states map[int64]repository.ReleaseState, days int) []utils.Repo {
+	since := time.Now().AddDate(0, 0, -days)
+	filtered := []utils.Repo{}
+	for _, repo := range repos {
+		releases := states[repo.ID].Releases
+		if len(releases) > 0 && releases[0].PublishedTime().After(since) {
+			filtered = append(filtered, repo)
+		}
+	}
+	return filtered
+}
+
+// watchedRepos 返回单独跟踪或属于跟踪分类的仓库
+func watchedRepos(repos []utils.Repo, settings utils.ReleaseSettings) []utils.Repo {
+	ids := make(map[int64]bool, len(settings.RepoIDs))
+	for _, id := range settings.RepoIDs {
+		ids[id] = true
+	}
+
+	var watched []utils.Repo
+	for _, repo := range repos {
+		if ids[repo.ID] || (repo.Category != "" && containsString(settings.Categories, repo.Category)) {
+			watched = append(watched, repo)
+		}
+	}
+	return watched
+}
+
+// hasRelease 判断发布历史中是否已包含指定版本
+func hasRelease(releases []utils.Release, tagName string) bool {
+	for _, release := range releases {
+		if release.TagName == tagName {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOptionalInt 解析可选的非负整数参数，为空时返回0
+func parseOptionalInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("无效的数字: %s", value)
+	}
+	return n, nil
+}
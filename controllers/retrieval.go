@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github-stars-manager/repository"
+	"github-stars-manager/utils"
+)
+
+// 检索时各字段的权重
+const (
+	weightName        = 3
+	weightTopic       = 2
+	weightTag         = 2
+	weightDescription = 2
+	weightReadme      = 1
+)
+
+// BM25参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// retrievalStopwords 检索时忽略的常见词
+var retrievalStopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "of": true, "in": true, "on": true, "for": true, "to": true,
+	"and": true, "or": true, "is": true, "are": true, "with": true, "can": true, "do": true, "does": true,
+	"which": true, "what": true, "my": true, "me": true, "i": true, "that": true, "it": true, "by": true,
+	"哪些": true, "哪个": true, "什么": true, "可以": true, "能够": true, "我的": true, "一个": true,
+}
+
+// RetrievedRepo 检索到的仓库
+type RetrievedRepo struct {
+	Repo  utils.Repo
+	Score float64
+	// Readme 缓存的README，没有缓存时为空
+	Readme string
+}
+
+// retrievalDoc 参与检索的仓库文档
+type retrievalDoc struct {
+	repo   utils.Repo
+	readme string
+	// tf 加权后的词频
+	tf     map[string]float64
+	length float64
+}
+
+// tokenize 把文本拆分为检索词：英文和数字按单词拆分并转为小写，中文按相邻两字拆分
+func tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var han []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			w := strings.ToLower(string(word))
+			if !retrievalStopwords[w] {
+				tokens = append(tokens, w)
+			}
+			word = word[:0]
+		}
+	}
+	flushHan := func() {
+		switch {
+		case len(han) == 1:
+			tokens = append(tokens, string(han))
+		case len(han) > 1:
+			for i := 0; i+1 < len(han); i++ {
+				if bigram := string(han[i : i+2]); !retrievalStopwords[bigram] {
+					tokens = append(tokens, bigram)
+				}
+			}
+		}
+		han = han[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
+
+// newRetrievalDoc 按字段权重统计仓库文档的词频
+func newRetrievalDoc(repo utils.Repo, readme string) *retrievalDoc {
+	doc := &retrievalDoc{repo: repo, readme: readme, tf: make(map[string]float64)}
+	add := func(text string, weight float64) {
+		for _, token := range tokenize(text) {
+			doc.tf[token] += weight
+			doc.length += weight
+		}
+	}
+
+	// 仓库名中的连字符和下划线按单词拆分
+	add(repo.FullName(), weightName)
+	add(repo.Description, weightDescription)
+	add(repo.Category, weightTag)
+	add(strings.Join(utils.SplitTags(repo.Tag), " "), weightTag)
+	add(strings.Join(repo.Topics, " "), weightTopic)
+	add(repo.Language, weightTopic)
+	add(readme, weightReadme)
+	return doc
+}
+
+// retrieveRepos 使用BM25按问题检索最相关的仓库，只返回得分大于0的前 limit 个
+func retrieveRepos(question string, repos []utils.Repo, tags map[int64]repository.RepoTag, readmes map[int64]repository.CachedReadme, limit int) []RetrievedRepo {
+	terms := uniqueStrings(tokenize(question))
+	if len(terms) == 0 || len(repos) == 0 {
+		return nil
+	}
+
+	docs := make([]*retrievalDoc, 0, len(repos))
+	var totalLength float64
+	df := make(map[string]int)
+	for _, repo := range repos {
+		// 使用自定义描述，同时保留原始描述参与检索
+		if tagInfo, ok := tags[repo.ID]; ok && tagInfo.Description != "" && tagInfo.Description != repo.Description {
+			repo.Description = tagInfo.Description + " " + repo.Description
+		}
+		doc := newRetrievalDoc(repo, readmes[repo.ID].Content)
+		docs = append(docs, doc)
+		totalLength += doc.length
+		for _, term := range terms {
+			if doc.tf[term] > 0 {
+				df[term]++
+			}
+		}
+	}
+	avgLength := totalLength / float64(len(docs))
+	if avgLength == 0 {
+		avgLength = 1
+	}
+
+	n := float64(len(docs))
+	results := make([]RetrievedRepo, 0, limit)
+	for _, doc := range docs {
+		var score float64
+		for _, term := range terms {
+			tf := doc.tf[term]
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(df[term])+0.5)/(float64(df[term])+0.5))
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*doc.length/avgLength))
+		}
+		if score > 0 {
+			results = append(results, RetrievedRepo{Repo: doc.repo, Score: score, Readme: doc.readme})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Repo.StargazersCount > results[j].Repo.StargazersCount
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// readmeSnippet 截取README中与问题最相关的片段：从第一个匹配的检索词附近开始，不超过 maxBytes 字节
+func readmeSnippet(readme string, terms []string, maxBytes int) string {
+	if len(readme) <= maxBytes {
+		return readme
+	}
+	lower := strings.ToLower(readme)
+	start := -1
+	for _, term := range terms {
+		if i := strings.Index(lower, term); i >= 0 && (start < 0 || i < start) {
+			start = i
+		}
+	}
+	// 保留匹配位置之前的少量内容作为上下文
+	if start > maxBytes/4 && start < len(readme) {
+		start -= maxBytes / 4
+		for start < len(readme) && !utf8.RuneStart(readme[start]) {
+			start++
+		}
+		return "..." + utils.TruncateUTF8(readme[start:], maxBytes)
+	}
+	return utils.TruncateUTF8(readme, maxBytes)
+}
+
+// uniqueStrings 去除重复的字符串，保留原有顺序
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	result := make([]string, 0, len(list))
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
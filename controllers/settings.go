@@ -14,16 +14,16 @@ import (
 type SettingsHandler struct {
 	config      *config.Config
 	logger      *zap.Logger
-	openaiCli   *utils.OpenAIUtil // 添加OpenAI工具实例
+	llmCli      *utils.LLMUtil // AI服务工具实例
 	settingsCli *utils.SettingsUtil
 }
 
 // NewSettingsHandler 创建设置处理器实例
-func NewSettingsHandler(config *config.Config, logger *zap.Logger, openaiCli *utils.OpenAIUtil, settingsCli *utils.SettingsUtil) *SettingsHandler {
+func NewSettingsHandler(config *config.Config, logger *zap.Logger, llmCli *utils.LLMUtil, settingsCli *utils.SettingsUtil) *SettingsHandler {
 	return &SettingsHandler{
 		config:      config,
 		logger:      logger,
-		openaiCli:   openaiCli,
+		llmCli:      llmCli,
 		settingsCli: settingsCli,
 	}
 }
@@ -52,23 +52,39 @@ func (h *SettingsHandler) SaveSettings(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据格式错误"})
 		return
 	}
-	h.settingsCli.SaveSettings(&settings)
+	// 检查提示模板能否解析，避免分析时才发现错误
+	for _, prompt := range settings.AI.Prompts {
+		if _, err := parsePromptTemplate(prompt.Template); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "提示模板 " + prompt.Name + " 解析失败: " + err.Error()})
+			return
+		}
+	}
+	// 单独跟踪的仓库通过 watch-releases 接口修改，保留文件中的值，避免页面上的旧数据覆盖其他请求的修改
+	err := h.settingsCli.UpdateSettings(func(current *utils.Settings) error {
+		settings.Releases.RepoIDs = current.Releases.RepoIDs
+		*current = settings
+		return nil
+	})
+	if err != nil {
+		h.logger.Error("保存设置失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存设置失败"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "设置已保存"})
 }
 
-// TestOpenAI 测试OpenAI连接
+// TestOpenAI 测试AI服务连接，请求体为完整的设置，使用其中选择的AI服务
 func (h *SettingsHandler) TestOpenAI(c *gin.Context) {
-	var openaiConfig utils.OpenAISettings
-	if err := c.ShouldBindJSON(&openaiConfig); err != nil {
+	var settings utils.Settings
+	if err := c.ShouldBindJSON(&settings); err != nil {
 		h.logger.Error("绑定JSON失败", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据格式错误"})
 		return
 	}
 
-	// 调用OpenAI工具类测试连接
-	_, err := h.openaiCli.CallWithPrompt(openaiConfig, "你好，请简单介绍一下你自己。")
-	if err != nil {
-		h.logger.Error("测试OpenAI连接失败", zap.Error(err))
+	// 通过AI服务接口测试连接
+	if err := h.llmCli.TestConnection(c.Request.Context(), &settings); err != nil {
+		h.logger.Error("测试AI服务连接失败", zap.String("provider", settings.AI.Provider), zap.Error(err))
 		c.JSON(http.StatusOK, gin.H{"success": false, "message": "连接失败: " + err.Error()})
 		return
 	}
@@ -91,32 +107,34 @@ func (h *SettingsHandler) TestWebDAV(c *gin.Context) {
 	}
 
 	// 发送一个PROPFIND请求测试连接
-	client := &http.Client{}
-	req, err := http.NewRequest("PROPFIND", webdavConfig.Url, nil)
-	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"success": false, "message": "创建请求失败: " + err.Error()})
+	if err := utils.NewWebDAVClient(webdavConfig).Test(c.Request.Context()); err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "message": "连接失败: " + err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "连接成功"})
+}
 
-	// 如果提供了用户名和密码，则添加基本认证
-	if webdavConfig.Username != "" && webdavConfig.Password != "" {
-		req.SetBasicAuth(webdavConfig.Username, webdavConfig.Password)
+// TestS3 测试S3兼容对象存储连接
+func (h *SettingsHandler) TestS3(c *gin.Context) {
+	var s3Config utils.S3Settings
+	if err := c.ShouldBindJSON(&s3Config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
 	}
 
-	req.Header.Set("Depth", "0")
-	req.Header.Set("Content-Type", "application/xml")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"success": false, "message": "请求失败: " + err.Error()})
+	if !s3Config.Configured() {
+		c.JSON(http.StatusOK, gin.H{"success": false, "message": "请提供S3服务地址和存储桶"})
+		return
+	}
+	if s3Config.AccessKeyID == "" || s3Config.SecretAccessKey == "" {
+		c.JSON(http.StatusOK, gin.H{"success": false, "message": "请提供Access Key和Secret Key"})
 		return
 	}
-	defer resp.Body.Close()
 
-	// WebDAV服务器通常会返回207 Multi-Status或200 OK表示成功
-	if resp.StatusCode == http.StatusMultiStatus || resp.StatusCode == http.StatusOK {
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "连接成功"})
-	} else {
-		c.JSON(http.StatusOK, gin.H{"success": false, "message": "连接失败，状态码: " + resp.Status})
+	// 列出存储桶中的一个对象，同时检查签名和存储桶权限
+	if err := utils.NewS3Client(s3Config).Test(c.Request.Context()); err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "message": "连接失败: " + err.Error()})
+		return
 	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "连接成功"})
 }
@@ -1,8 +1,10 @@
 package controllers
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -20,13 +22,6 @@ import (
 	"go.uber.org/zap"
 )
 
-// WebSocket upgrader
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // 允许所有来源的连接
-	},
-}
-
 // 为 WebSocket 连接添加写入锁
 type SafeWebSocketConn struct {
 	conn *websocket.Conn
@@ -54,6 +49,21 @@ type AIAnalysisResult struct {
 // GetRepos 获取仓库列表
 func (h *StarHandler) GetRepos(c *gin.Context) {
 	h.logger.Info("获取仓库列表")
+
+	// 可选：只返回最近N天内有发布的仓库
+	releasedWithin, err := parseOptionalInt(c.Query("released_within_days"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "released_within_days参数错误"})
+		return
+	}
+
+	// 可选：按健康状态筛选，多个状态用逗号分隔
+	healthFilter, ok := utils.ParseHealthFilter(c.Query("health"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "health参数错误"})
+		return
+	}
+	health := h.loadHealthSettings()
 	
 	// 尝试从本地数据库加载带标签的仓库
 	repos, err := h.repo.GetReposWithTag()
@@ -66,6 +76,17 @@ func (h *StarHandler) GetRepos(c *gin.Context) {
 				repos[i].Description = tagInfo.Description
 			}
 		}
+
+		if releasedWithin > 0 {
+			states, err := h.repo.GetReleaseStates()
+			if err != nil {
+				h.logger.Error("加载发布数据失败", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "加载发布数据失败"})
+				return
+			}
+			repos = filterReleasedWithin(repos, states, releasedWithin)
+		}
+		repos = applyRepoHealth(repos, health, healthFilter)
 		
 		h.logger.Info("成功从本地获取仓库列表", zap.Int("count", len(repos)))
 		c.JSON(http.StatusOK, repos)
@@ -80,6 +101,9 @@ func (h *StarHandler) GetRepos(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
 		return
 	}
+	if !requireGitHubToken(c, "从GitHub获取仓库列表") {
+		return
+	}
 	sess := s.(*session.SessionData)
 	repos, err = h.githubCli.GetStarredRepos(sess.AccessToken)
 	if err != nil {
@@ -93,6 +117,7 @@ func (h *StarHandler) GetRepos(c *gin.Context) {
 		h.logger.Warn("保存仓库数据失败", zap.Error(saveErr))
 	}
 	h.repo.SaveSyncTime()
+	repos = applyRepoHealth(repos, health, healthFilter)
 	
 	// 对于新获取的数据，暂时不填充AI描述（因为还没有分析）
 	h.logger.Info("成功从API获取仓库列表", zap.Int("count", len(repos)))
@@ -127,10 +152,10 @@ func (h *StarHandler) AnalyzeRepo(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载AI设置失败"})
 		return
 	}
-	openSetting := settings.OpenAI
-	if openSetting.Key == "" || openSetting.Endpoint == "" || openSetting.Model == "" {
-		h.logger.Warn("AI配置不完整")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "AI配置不完整，请先在设置中配置AI参数"})
+	provider, err := h.llmCli.Provider(settings)
+	if err != nil {
+		h.logger.Warn("AI配置不完整", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "AI配置不完整，请先在设置中配置AI参数: " + err.Error()})
 		return
 	}
 	
@@ -139,38 +164,164 @@ func (h *StarHandler) AnalyzeRepo(c *gin.Context) {
 	if err != nil {
 		h.logger.Warn("获取仓库README失败", zap.Error(err))
 	}
+	h.cacheReadme(id, readmeContent)
 	
-	// 构造AI分析提示
-	prompt := h.buildAIAnalysisPrompt(repo, readmeContent)
+	// 使用设置中的模板构造AI分析提示
+	tmpl := settings.AI.ActivePromptTemplate()
+	categories := settings.AI.CategoryList()
+	prompt, _, err := h.buildAIAnalysisPrompt(tmpl, categories, repo, readmeContent)
+	if err != nil {
+		h.logger.Error("构造分析提示失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	
-	// 调用AI分析
-	analysisResult, err := h.callAIAnalysis(openSetting, prompt)
+	// stream=true 时通过SSE返回AI回复的片段，结果和错误也通过事件返回
+	stream := c.Query("stream") == "true"
+	var onDelta func(attempt int, delta string)
+	if stream {
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		onDelta = func(attempt int, delta string) {
+			c.SSEvent("delta", gin.H{"attempt": attempt, "content": delta})
+			c.Writer.Flush()
+		}
+	}
+	fail := func(status int, message string) {
+		if stream {
+			c.SSEvent("error", gin.H{"error": message, "status": status})
+			c.Writer.Flush()
+			return
+		}
+		c.JSON(status, gin.H{"error": message})
+	}
+	
+	// 调用AI分析，输入未变化时使用缓存的结果，force=true 时重新分析；客户端断开时取消请求
+	analysisResult, cached, err := h.analyzeWithCache(c.Request.Context(), provider, settings.AI, analysisInput{
+		RepoID: id,
+		Prompt: prompt,
+		Hash:   analysisInputHash(provider, tmpl, prompt, categories),
+		Force:  c.Query("force") == "true",
+		Spec: analysisSpec{
+			Categories:     categories,
+			TagCount:       tmpl.TagCount,
+			Structured:     !settings.AI.DisableStructuredOutput,
+			RepairAttempts: settings.AI.RepairAttemptCount(),
+		},
+		OnDelta: onDelta,
+	})
+	if errors.Is(err, context.Canceled) {
+		h.logger.Info("客户端已断开，取消AI分析", zap.Int64("repo_id", id))
+		return
+	}
+	if errors.Is(err, errAIBudgetExceeded) {
+		h.logger.Warn("本月AI预算已用完", zap.Float64("budget", settings.AI.MonthlyBudget))
+		fail(http.StatusPaymentRequired, err.Error())
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		h.logger.Error("AI分析超时", zap.Error(err))
+		fail(http.StatusGatewayTimeout, "AI分析超时: "+err.Error())
+		return
+	}
 	if err != nil {
 		h.logger.Error("AI分析失败", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI分析失败: " + err.Error()})
+		fail(http.StatusInternalServerError, "AI分析失败: "+err.Error())
 		return
 	}
 	
-	// 验证分析结果
-	if analysisResult.Category == "" || len(analysisResult.Tags) == 0 {
-		h.logger.Error("AI分析结果不完整", 
-			zap.String("category", analysisResult.Category),
-			zap.Strings("tags", analysisResult.Tags),
-			zap.String("description", analysisResult.Description))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI分析结果不完整，请稍后重试"})
+	// 保存为待审核的建议，由用户决定是否采用
+	suggestion := &repository.Suggestion{
+		RepoID:        id,
+		Category:      analysisResult.Category,
+		Tags:          analysisResult.Tags,
+		Description:   analysisResult.Description,
+		Provider:      provider.Name(),
+		Model:         provider.Model(),
+		PromptName:    tmpl.Name,
+		PromptVersion: promptVersion(tmpl),
+		CreatedAt:     time.Now().Format(time.RFC3339),
+	}
+	if err := h.repo.SaveSuggestion(suggestion); err != nil {
+		h.logger.Error("保存AI建议失败", zap.Error(err))
+		fail(http.StatusInternalServerError, "保存AI建议失败")
 		return
 	}
 	
-	// 保存分析结果
-	err = h.saveAnalysisResult(id, analysisResult)
+	h.logger.Info("AI建议已保存，等待审核", zap.Int64("repo_id", id), zap.Bool("cached", cached))
+	view, err := h.suggestionView(*suggestion)
 	if err != nil {
-		h.logger.Error("保存分析结果失败", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存分析结果失败"})
+		h.logger.Error("加载仓库标签失败", zap.Error(err))
+		fail(http.StatusInternalServerError, "加载仓库标签失败")
 		return
 	}
-	
-	// 返回AI分析结果
-	c.JSON(http.StatusOK, analysisResult)
+	view.Cached = cached
+	if stream {
+		c.SSEvent("result", view)
+		c.SSEvent("done", gin.H{})
+		c.Writer.Flush()
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+// loadHealthSettings 加载健康状态配置，失败时使用默认值
+func (h *StarHandler) loadHealthSettings() utils.HealthSettings {
+	settings, err := h.settingsCli.LoadSettings()
+	if err != nil {
+		h.logger.Warn("加载健康状态配置失败，使用默认值", zap.Error(err))
+		return utils.HealthSettings{}.WithDefaults()
+	}
+	return settings.Health.WithDefaults()
+}
+
+// applyRepoHealth 计算每个仓库的健康状态，filter 非空时只保留匹配的仓库
+func applyRepoHealth(repos []utils.Repo, settings utils.HealthSettings, filter map[string]bool) []utils.Repo {
+	now := time.Now()
+	filtered := make([]utils.Repo, 0, len(repos))
+	for _, repo := range repos {
+		repo.Health = utils.RepoHealth(repo, settings, now)
+		if len(filter) == 0 || filter[repo.Health] {
+			filtered = append(filtered, repo)
+		}
+	}
+	return filtered
+}
+
+// refreshRepoMeta 用GitHub返回的最新信息更新本地仓库，保留用户编辑的信息
+func refreshRepoMeta(local, remote utils.Repo) utils.Repo {
+	local.MovedFrom = movedFrom(local, remote)
+	local.Name = remote.Name
+	local.HTMLURL = remote.HTMLURL
+	local.StargazersCount = remote.StargazersCount
+	local.StarredAt = remote.StarredAt
+	local.Archived = remote.Archived
+	local.Disabled = remote.Disabled
+	local.Fork = remote.Fork
+	local.PushedAt = remote.PushedAt
+	local.OpenIssuesCount = remote.OpenIssuesCount
+	local.ForksCount = remote.ForksCount
+	local.License = remote.License
+	if len(remote.LanguageBytes) > 0 {
+		local.Languages = remote.Languages
+		local.LanguageBytes = remote.LanguageBytes
+	}
+	return local
+}
+
+// movedFrom 判断仓库是否被重命名或转移，返回最初的全名
+func movedFrom(local, remote utils.Repo) string {
+	if local.MovedFrom != "" {
+		// 改回原来的名称时不再视为已转移
+		if strings.EqualFold(local.MovedFrom, remote.FullName()) {
+			return ""
+		}
+		return local.MovedFrom
+	}
+	if local.HTMLURL != "" && !strings.EqualFold(local.FullName(), remote.FullName()) {
+		return local.FullName()
+	}
+	return ""
 }
 
 // getRepoByID 根据ID获取仓库信息
@@ -189,6 +340,21 @@ func (h *StarHandler) getRepoByID(repoID int64) (*utils.Repo, error) {
 	return nil, fmt.Errorf("未找到ID为%d的仓库", repoID)
 }
 
+// cacheReadme 缓存获取到的README供问答检索使用，超过长度限制的部分被截断
+func (h *StarHandler) cacheReadme(repoID int64, readme string) {
+	if readme == "" {
+		return
+	}
+	err := h.repo.SaveReadme(&repository.CachedReadme{
+		RepoID:    repoID,
+		Content:   utils.TruncateUTF8(readme, maxCachedReadmeBytes),
+		FetchedAt: time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		h.logger.Warn("缓存README失败", zap.Int64("repo_id", repoID), zap.Error(err))
+	}
+}
+
 // getRepoReadmeWithToken 使用访问令牌获取仓库README内容
 func (h *StarHandler) getRepoReadmeWithToken(repoURL string,  c *gin.Context) (string, error) {
 	// 从上下文中获取session
@@ -261,214 +427,6 @@ func (h *StarHandler) getRepoReadmeWithToken(repoURL string,  c *gin.Context) (s
 	return readmeResp.Content, nil
 }
 
-// buildAIAnalysisPrompt 构造AI分析提示
-func (h *StarHandler) buildAIAnalysisPrompt(repo *utils.Repo, readme string) string {
-	topics := "无"
-	if len(repo.Topics) > 0 {
-		topics = strings.Join(repo.Topics, ", ")
-	}
-	
-	languages := "无"
-	if len(repo.Languages) > 0 {
-		languages = strings.Join(repo.Languages, ", ")
-	}
-	
-	description := "无描述"
-	if repo.Description != "" {
-		description = repo.Description
-	}
-	
-	readmeText := "无README"
-	if readme != "" {
-		// 限制README长度以避免超出token限制
-		if len(readme) > 2000 {
-			readme = readme[:2000] + "...(内容过长已截断)"
-		}
-		readmeText = readme
-	}
-	
-	// 定义标准分类列表（使用中文）
-	categories := []string{
-		"前端",
-		"后端", 
-		"移动开发",
-		"工具",
-		"数据库",
-		"运维",
-		"人工智能",
-		"安全",
-		"物联网",
-		"游戏",
-	}
-	
-	categoryList := ""
-	for _, cat := range categories {
-		categoryList += fmt.Sprintf("- %s\n", cat)
-	}
-	
-	prompt := fmt.Sprintf(`你是一个专业的GitHub项目分析师，请分析以下GitHub仓库信息，并用中文提供结构化的分析结果。
-
-仓库信息：
-- 名称：%s
-- 描述：%s
-- 主要编程语言：%s
-- 编程语言列表：%s
-- 主题标签：%s
-- README内容：%s
-
-请根据以上信息提供以下三方面的分析：
-
-1. 分类：请从以下分类中选择最合适的一个分类：
-%s
-
-2. 标签：请提供3个最能代表此仓库的标签，尽量使用中文，用逗号分隔
-
-3. 描述：请用中文写一段简洁明了的描述（不超过100字），这个描述应该比原始描述更详细和准确
-
-请严格按照以下JSON格式返回结果，不要包含其他内容：
-{
-  "category": "分类名称（如前端、后端等）",
-  "tags": ["标签1", "标签2", "标签3"],
-  "description": "项目描述"
-}`, 
-		repo.Name, 
-		description,
-		repo.Language, 
-		languages, 
-		topics, 
-		readmeText,
-		categoryList)
-	
-	return prompt
-}
-
-// callAIAnalysis 调用AI进行分析
-func (h *StarHandler) callAIAnalysis(settings utils.OpenAISettings, prompt string) (*AIAnalysisResult, error) {
-	// 调用OpenAI工具类
-	content, err := h.openaiCli.CallWithPrompt(settings, prompt)
-	if err != nil {
-		h.logger.Error("调用OpenAI API失败", zap.Error(err))
-		return nil, fmt.Errorf("调用OpenAI API失败: %w", err)
-	}
-
-	var result AIAnalysisResult
-	// 尝试直接解析JSON
-	err = json.Unmarshal([]byte(content), &result)
-	if err != nil {
-		// 如果直接解析失败，尝试提取其中的JSON
-		h.logger.Debug("直接解析JSON失败，尝试提取JSON", zap.String("content", content))
-
-		// 查找第一个 { 和最后一个 } 之间的内容
-		start := strings.Index(content, "{")
-		end := strings.LastIndex(content, "}")
-
-		if start >= 0 && end > start {
-			jsonStr := content[start : end+1]
-			// 清理JSON字符串中的特殊字符
-			jsonStr = strings.ReplaceAll(jsonStr, "\n", "")
-			jsonStr = strings.ReplaceAll(jsonStr, "\\", "")
-
-			h.logger.Debug("提取的JSON字符串", zap.String("json", jsonStr))
-
-			// 先尝试解析为map，处理tags可能为字符串的情况
-			var rawResult map[string]interface{}
-			err = json.Unmarshal([]byte(jsonStr), &rawResult)
-			if err != nil {
-				h.logger.Error("解析提取的JSON失败",
-					zap.Error(err),
-					zap.String("json", jsonStr))
-				return nil, fmt.Errorf("解析AI返回的JSON失败: %w", err)
-			}
-
-			// 处理category
-			if category, ok := rawResult["category"].(string); ok {
-				result.Category = category
-			}
-
-			// 处理description
-			if description, ok := rawResult["description"].(string); ok {
-				result.Description = description
-			}
-
-			// 处理tags，可能是字符串或字符串数组
-			if tags, ok := rawResult["tags"]; ok {
-				switch v := tags.(type) {
-				case string:
-					// 如果tags是字符串，按逗号分割
-					result.Tags = strings.Split(v, ",")
-					// 清理每个标签的空格
-					for i, tag := range result.Tags {
-						result.Tags[i] = strings.TrimSpace(tag)
-					}
-				case []interface{}:
-					// 如果tags是数组，转换为字符串数组
-					result.Tags = make([]string, len(v))
-					for i, tag := range v {
-						result.Tags[i] = fmt.Sprintf("%v", tag)
-					}
-				}
-			}
-		} else {
-			return nil, fmt.Errorf("AI未返回有效的JSON格式结果: %s", content)
-		}
-	}
-
-	// 验证结果
-	if result.Category == "" {
-		return nil, fmt.Errorf("AI返回的分类为空")
-	}
-
-	if len(result.Tags) == 0 {
-		return nil, fmt.Errorf("AI返回的标签为空")
-	}
-
-	// 限制标签数量为3个
-	if len(result.Tags) > 3 {
-		result.Tags = result.Tags[:3]
-	}
-
-	// 清理标签中的空格
-	for i, tag := range result.Tags {
-		result.Tags[i] = strings.TrimSpace(tag)
-	}
-
-	h.logger.Debug("AI分析成功",
-		zap.String("category", result.Category),
-		zap.Strings("tags", result.Tags),
-		zap.String("description", result.Description))
-
-	return &result, nil
-}
-
-// saveAnalysisResult 保存分析结果
-func (h *StarHandler) saveAnalysisResult(repoID int64, result *AIAnalysisResult) error {
-	// 创建RepoTag对象
-	repoTag := &repository.RepoTag{
-		ID:          repoID,
-		Category:    result.Category,
-		Tag:         strings.Join(result.Tags, ","),
-		Description: result.Description,
-	}
-	
-	h.logger.Debug("保存AI分析结果",
-		zap.Int64("repo_id", repoID),
-		zap.String("category", result.Category),
-		zap.Strings("tags", result.Tags),
-		zap.String("description", result.Description))
-	
-	// 保存到仓库（使用现有的SaveRepoTag方法）
-	err := h.repo.SaveRepoTag(repoTag)
-	if err != nil {
-		h.logger.Error("保存仓库标签信息失败",
-			zap.Int64("repo_id", repoID),
-			zap.Error(err))
-		return fmt.Errorf("保存仓库标签信息失败: %w", err)
-	}
-	
-	h.logger.Info("AI分析结果保存成功", zap.Int64("repo_id", repoID))
-	return nil
-}
-
 type SyncProgress struct {
 	Type     string `json:"type"`
 	Progress int    `json:"progress"`
@@ -482,9 +440,12 @@ type StarHandler struct {
 	repo   repository.Repository
 	logger *zap.Logger
 	config *config.Config
-	openaiCli *utils.OpenAIUtil
+	llmCli *utils.LLMUtil
 	settingsCli *utils.SettingsUtil
 	githubCli *utils.GithubUtil
+	app *GitHubAppAuth
+	// upgrader 同步进度的WebSocket升级器，只接受本站和允许的来源
+	upgrader websocket.Upgrader
 }
 
 // NewStarHandler 创建一个新的StarHandler实例
@@ -492,18 +453,27 @@ func NewStarHandler(
 	repo repository.Repository, 
 	logger *zap.Logger, 
 	config *config.Config, 
-	openaiCli *utils.OpenAIUtil, 
+	llmCli *utils.LLMUtil, 
 	settingsCli *utils.SettingsUtil,
 	githubCli *utils.GithubUtil,
+	app *GitHubAppAuth,
 	) *StarHandler {
-	return &StarHandler{
+	h := &StarHandler{
 		repo:   repo,
 		logger: logger,
 		config: config,
-		openaiCli: openaiCli,
+		llmCli: llmCli,
 		settingsCli: settingsCli,
 		githubCli: githubCli,
+		app: app,
+	}
+	// WebSocket不受CORS限制，需要检查Origin，防止其他网站借用户的登录状态连接
+	h.upgrader = websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return originAllowed(r, config.OriginList())
+		},
 	}
+	return h
 }
 
 // IndexPage 首页处理器
@@ -517,10 +487,35 @@ func (h *StarHandler) GetUser(c *gin.Context) {
 	h.logger.Info("获取用户信息")
 	s, _ := c.Get("session")
 	sess := s.(*session.SessionData)
-	c.JSON(http.StatusOK, gin.H{
+	scopes := sess.Scopes.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	user := gin.H{
 		"login":      sess.UserName,
 		"avatar_url": sess.AvatarURL,
-	})
+		"role":       sess.Role,
+		// scopes_known 为false时无法确定权限（如细粒度令牌），features 均按可用处理
+		"scopes":       scopes,
+		"scopes_known": sess.Scopes.Known,
+		"features": gin.H{
+			"star":          sess.Scopes.Has(utils.ScopePublicRepo),
+			"private_repos": sess.Scopes.Has(utils.ScopeRepo),
+		},
+	}
+	// 使用GitHub App登录时返回安装状态，未安装时前端可以引导用户安装。未配置App私钥时无法查询，不返回安装状态
+	if h.app.Enabled() {
+		githubApp := gin.H{}
+		if app, err := h.app.App(); err != nil {
+			h.logger.Warn("获取GitHub App信息失败", zap.Error(err))
+		} else if app != nil {
+			githubApp["installed"] = sess.InstallationID != 0
+			githubApp["installation_id"] = sess.InstallationID
+			githubApp["install_url"] = app.InstallURL()
+		}
+		user["github_app"] = githubApp
+	}
+	c.JSON(http.StatusOK, user)
 }
 
 // GetStats 获取统计信息
@@ -528,8 +523,9 @@ func (h *StarHandler) GetStats(c *gin.Context) {
 	h.logger.Info("获取统计信息")
 	s, _ := c.Get("session")
 	sess := s.(*session.SessionData)
-	
-	stats, err := h.repo.GetStats()
+
+	health := h.loadHealthSettings()
+	stats, err := h.repo.GetStats(health)
 	if err != nil {
 		// 如果获取统计数据失败，重新计算
 		h.logger.Warn("获取统计数据失败，重新计算", zap.Error(err))
@@ -543,13 +539,16 @@ func (h *StarHandler) GetStats(c *gin.Context) {
 		stats = &repository.Stats{
 			TotalRepos:    len(repos),
 			AnalyzedRepos: 0,
+			Health:        make(map[string]int),
 		}
 		
-		// 计算已分析的仓库数量（有标签或分类的仓库）
+		// 计算已分析的仓库数量（有标签或分类的仓库）和各健康状态的数量
+		now := time.Now()
 		for _, repo := range repos {
 			if repo.Tag != "" || repo.Category != "" {
 				stats.AnalyzedRepos++
 			}
+			stats.Health[utils.RepoHealth(repo, health, now)]++
 		}
 		
 		// 获取上次同步时间
@@ -565,9 +564,51 @@ func (h *StarHandler) GetStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// GetAnalytics 获取语言、主题、分类、标签、许可证和star时间的分布统计
+//
+// top 限制各分布列表返回的条目数，默认20，为0时返回全部。
+func (h *StarHandler) GetAnalytics(c *gin.Context) {
+	h.logger.Info("获取分布统计")
+	top, err := parseOptionalInt(c.DefaultQuery("top", "20"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "top参数错误"})
+		return
+	}
+
+	analytics, err := h.repo.GetAnalytics()
+	if err != nil {
+		h.logger.Error("获取分布统计失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取分布统计失败"})
+		return
+	}
+
+	if top > 0 {
+		analytics.Languages = topCounts(analytics.Languages, top)
+		analytics.Topics = topCounts(analytics.Topics, top)
+		analytics.Categories = topCounts(analytics.Categories, top)
+		analytics.Tags = topCounts(analytics.Tags, top)
+		analytics.Licenses = topCounts(analytics.Licenses, top)
+		if len(analytics.LanguageBytes) > top {
+			analytics.LanguageBytes = analytics.LanguageBytes[:top]
+		}
+	}
+	c.JSON(http.StatusOK, analytics)
+}
+
+// topCounts 返回排名前n的条目
+func topCounts(items []repository.CountItem, n int) []repository.CountItem {
+	if len(items) > n {
+		return items[:n]
+	}
+	return items
+}
+
 // SyncStars 同步stars
 func (h *StarHandler) SyncStars(c *gin.Context) {
 	h.logger.Info("开始同步stars")
+	if !requireGitHubToken(c, "同步仓库") {
+		return
+	}
 	s, _ := c.Get("session")
 	sess := s.(*session.SessionData)
 	githubRepos, err := h.githubCli.GetStarredRepos(sess.AccessToken)
@@ -600,8 +641,8 @@ func (h *StarHandler) SyncStars(c *gin.Context) {
 		
 		// 检查该仓库是否已经在本地存在
 		if localRepo, exists := localRepoMap[githubRepo.ID]; exists {
-			// 如果存在，保留用户编辑的信息
-			mergedRepos = append(mergedRepos, localRepo)
+			// 如果存在，保留用户编辑的信息，更新GitHub上会变化的信息
+			mergedRepos = append(mergedRepos, refreshRepoMeta(localRepo, githubRepo))
 		} else {
 			// 如果不存在，添加新的仓库（设置默认值）
 			githubRepo.Tag = ""
@@ -618,6 +659,8 @@ func (h *StarHandler) SyncStars(c *gin.Context) {
 		return
 	}
 	
+	h.recordHistory(mergedRepos)
+	
 	err = h.repo.SaveSyncTime()
 	if err != nil {
 		h.logger.Error("保存同步时间失败", zap.Error(err))
@@ -633,11 +676,14 @@ func (h *StarHandler) SyncStars(c *gin.Context) {
 // SyncProgressWS 同步进度WebSocket
 func (h *StarHandler) SyncProgressWS(c *gin.Context) {
 	h.logger.Info("开始WebSocket同步进度")
+	if !requireGitHubToken(c, "同步仓库") {
+		return
+	}
 	// 升级到 WebSocket 连接
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		h.logger.Error("无法升级到WebSocket连接", zap.Error(err))
-		c.JSON(500, gin.H{"error": "无法升级到 WebSocket 连接"})
+		// Upgrade 失败时已经返回了错误响应，例如来源不被允许时返回403
+		h.logger.Error("无法升级到WebSocket连接", zap.Error(err), zap.String("origin", c.GetHeader("Origin")))
 		return
 	}
 	defer conn.Close()
@@ -761,8 +807,9 @@ func (h *StarHandler) SyncProgressWS(c *gin.Context) {
 		githubRepoMap[githubRepo.ID] = true
 
 		// 检查该仓库是否已经在本地存在
-		if _, exists := localRepoMap[githubRepo.ID]; exists {
+		if localRepo, exists := localRepoMap[githubRepo.ID]; exists {
 			// 如果存在，保留用户编辑的信息
+			githubRepo.MovedFrom = movedFrom(localRepo, githubRepo)
 			mergedRepos = append(mergedRepos, githubRepo)
 		} else {
 			// 如果不存在，添加新的仓库（设置默认值）
@@ -793,11 +840,12 @@ func (h *StarHandler) SyncProgressWS(c *gin.Context) {
 		Progress: 95,
 	})
 
-	// 保存合并后的仓库数据和同步时间
+	// 保存合并后的仓库数据、历史数据和同步时间
 	err = h.repo.SaveRepos(mergedRepos)
 	if err != nil {
 		h.logger.Error("保存仓库数据失败", zap.Error(err))
 	}
+	h.recordHistory(mergedRepos)
 	
 	err = h.repo.SaveSyncTime()
 	if err != nil {
@@ -834,11 +882,12 @@ func (h *StarHandler) UpdateTag(c *gin.Context) {
 		tagInfo = &repository.RepoTag{ID: id}
 	}
 	
-	// 更新标签
+	// 更新标签，手动编辑的字段不再被AI建议覆盖
 	tagInfo.Tag = body.Tag
+	tagInfo.Lock(repository.FieldTag)
 	
-	// 如果标签和分类都为空，则删除该记录
-	if tagInfo.Tag == "" && tagInfo.Category == "" {
+	// 如果记录不再包含任何信息，则删除该记录
+	if tagInfo.Empty() {
 		err = h.repo.DeleteRepoTag(id)
 		if err != nil {
 			h.logger.Error("删除仓库标签失败", zap.Error(err))
@@ -856,6 +905,79 @@ func (h *StarHandler) UpdateTag(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"msg": "更新成功"})
 }
 
+// RenameTag 在所有仓库中重命名标签，新名称为空时删除该标签
+//
+// 仓库已有新名称的标签时合并为一个。修改过的仓库的标签字段视为手动编辑，不再被AI建议覆盖。
+func (h *StarHandler) RenameTag(c *gin.Context) {
+	var body struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据格式错误"})
+		return
+	}
+	from := strings.TrimSpace(body.From)
+	to := strings.TrimSpace(body.To)
+	if from == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "原标签不能为空"})
+		return
+	}
+	if strings.ContainsAny(to, ",，") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "新标签不能包含逗号"})
+		return
+	}
+	h.logger.Info("重命名标签", zap.String("from", from), zap.String("to", to))
+
+	repos, err := h.repo.GetReposWithTag()
+	if err != nil {
+		h.logger.Error("加载仓库数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载仓库数据失败"})
+		return
+	}
+	tags, err := h.repo.GetRepoTags()
+	if err != nil {
+		h.logger.Error("加载标签数据失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载标签数据失败"})
+		return
+	}
+
+	var updates []repository.RepoTag
+	for _, repo := range repos {
+		current := utils.SplitTags(repo.Tag)
+		if !containsString(current, from) {
+			continue
+		}
+		tagInfo, ok := tags[repo.ID]
+		if !ok {
+			tagInfo = repository.RepoTag{ID: repo.ID, Category: repo.Category}
+		}
+		renamed := make([]string, 0, len(current))
+		for _, tag := range current {
+			if tag == from {
+				tag = to
+			}
+			if tag != "" {
+				renamed = append(renamed, tag)
+			}
+		}
+		tagInfo.Tag = strings.Join(mergeTagLists(renamed, nil), ",")
+		tagInfo.Lock(repository.FieldTag)
+		updates = append(updates, tagInfo)
+	}
+
+	if len(updates) > 0 {
+		if err := h.repo.SaveRepoTags(updates); err != nil {
+			h.logger.Error("保存标签数据失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存标签数据失败"})
+			return
+		}
+	}
+
+	h.logger.Info("标签重命名完成", zap.Int("updated", len(updates)))
+	c.JSON(http.StatusOK, gin.H{"msg": "重命名完成", "updated": len(updates)})
+}
+
 // UpdateCategory 更新分类
 func (h *StarHandler) UpdateCategory(c *gin.Context) {
 	h.logger.Info("更新分类")
@@ -876,11 +998,12 @@ func (h *StarHandler) UpdateCategory(c *gin.Context) {
 		tagInfo = &repository.RepoTag{ID: id}
 	}
 	
-	// 更新分类
+	// 更新分类，手动编辑的字段不再被AI建议覆盖
 	tagInfo.Category = body.Category
+	tagInfo.Lock(repository.FieldCategory)
 	
-	// 如果标签和分类都为空，则删除该记录
-	if tagInfo.Tag == "" && tagInfo.Category == "" {
+	// 如果记录不再包含任何信息，则删除该记录
+	if tagInfo.Empty() {
 		err = h.repo.DeleteRepoTag(id)
 		if err != nil {
 			h.logger.Error("删除仓库标签失败", zap.Error(err))
@@ -918,11 +1041,12 @@ func (h *StarHandler) UpdateDescription(c *gin.Context) {
 		tagInfo = &repository.RepoTag{ID: id}
 	}
 	
-	// 更新描述
+	// 更新描述，手动编辑的字段不再被AI建议覆盖
 	tagInfo.Description = body.Description
+	tagInfo.Lock(repository.FieldDescription)
 	
-	// 如果标签、分类和描述都为空，则删除该记录
-	if tagInfo.Tag == "" && tagInfo.Category == "" && tagInfo.Description == "" {
+	// 如果记录不再包含任何信息，则删除该记录
+	if tagInfo.Empty() {
 		err = h.repo.DeleteRepoTag(id)
 		if err != nil {
 			h.logger.Error("删除仓库标签失败", zap.Error(err))
@@ -943,17 +1067,15 @@ func (h *StarHandler) UpdateDescription(c *gin.Context) {
 // GetCategories 获取分类列表
 func (h *StarHandler) GetCategories(c *gin.Context) {
 	h.logger.Info("获取分类列表")
-	categories := []map[string]string{
-		{"value": "前端", "label": "前端"},
-		{"value": "后端", "label": "后端"},
-		{"value": "移动开发", "label": "移动开发"},
-		{"value": "工具", "label": "工具"},
-		{"value": "数据库", "label": "数据库"},
-		{"value": "运维", "label": "运维"},
-		{"value": "人工智能", "label": "人工智能"},
-		{"value": "安全", "label": "安全"},
-		{"value": "物联网", "label": "物联网"},
-		{"value": "游戏", "label": "游戏"},
+	list := utils.DefaultCategories
+	if settings, err := h.settingsCli.LoadSettings(); err == nil {
+		list = settings.AI.CategoryList()
+	} else {
+		h.logger.Warn("加载设置失败，使用默认分类", zap.Error(err))
+	}
+	categories := make([]map[string]string, 0, len(list))
+	for _, category := range list {
+		categories = append(categories, map[string]string{"value": category, "label": category})
 	}
 	c.JSON(http.StatusOK, categories)
 }
@@ -1086,7 +1208,8 @@ func (h *StarHandler) getPageStarredRepos(accessToken string, page int, progress
 	for attempts := 0; attempts < 3; attempts++ {
 		req, _ := http.NewRequest("GET", fmt.Sprintf("https://api.github.com/user/starred?page=%d&per_page=100", page), nil)
 		req.Header.Set("Authorization", "token "+accessToken)
-		req.Header.Set("Accept", "application/vnd.github.v3+json")
+		// 使用star+json以获取star时间
+		req.Header.Set("Accept", "application/vnd.github.star+json")
 		
 		resp, err = client.Do(req)
 		if err == nil {
@@ -1104,16 +1227,11 @@ func (h *StarHandler) getPageStarredRepos(accessToken string, page int, progress
 	
 	defer resp.Body.Close()
 	
-	var repos []struct {
-		ID              int64  `json:"id"`
-		Name            string `json:"name"`
-		HTMLURL         string `json:"html_url"`
-		StargazersCount int    `json:"stargazers_count"`
-		Description     string `json:"description"`
-		Language        string `json:"language"`
-		Topics          []string `json:"topics"` // 添加Topics字段
-	}
-	err = json.NewDecoder(resp.Body).Decode(&repos)
+	var starred []struct {
+		StarredAt string `json:"starred_at"`
+		Repo      utils.Repo `json:"repo"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&starred)
 	if err != nil {
 		h.logger.Error("解析页面仓库列表失败", 
 			zap.Error(err), 
@@ -1122,7 +1240,8 @@ func (h *StarHandler) getPageStarredRepos(accessToken string, page int, progress
 	}
 	
 	var detailedRepos []utils.Repo
-	for _, repo := range repos {
+	for _, item := range starred {
+		repo := item.Repo
 		// 构建仓库全名 (owner/repo)
 		urlParts := strings.Split(strings.TrimSuffix(repo.HTMLURL, ".git"), "/")
 		if len(urlParts) >= 2 {
@@ -1142,6 +1261,7 @@ func (h *StarHandler) getPageStarredRepos(accessToken string, page int, progress
 			if repoErr == nil && detailedRepo != nil {
 				detailedRepo.Tag = ""      // 初始化用户标签为空
 				detailedRepo.Category = ""  // 初始化分类为空
+				detailedRepo.StarredAt = item.StarredAt
 				detailedRepos = append(detailedRepos, *detailedRepo)
 			} else {
 				// 如果获取详细信息失败，使用基础信息
@@ -1159,6 +1279,14 @@ func (h *StarHandler) getPageStarredRepos(accessToken string, page int, progress
 					Topics:          repo.Topics,
 					Tag:             "",
 					Category:        "",
+					StarredAt:       item.StarredAt,
+					Archived:        repo.Archived,
+					Disabled:        repo.Disabled,
+					Fork:            repo.Fork,
+					PushedAt:        repo.PushedAt,
+					OpenIssuesCount: repo.OpenIssuesCount,
+					ForksCount:      repo.ForksCount,
+					License:         repo.License,
 				}
 				detailedRepos = append(detailedRepos, basicRepo)
 			}
@@ -1177,6 +1305,14 @@ func (h *StarHandler) getPageStarredRepos(accessToken string, page int, progress
 				Topics:          repo.Topics,
 				Tag:             "",
 				Category:        "",
+				StarredAt:       item.StarredAt,
+				Archived:        repo.Archived,
+				Disabled:        repo.Disabled,
+				Fork:            repo.Fork,
+				PushedAt:        repo.PushedAt,
+				OpenIssuesCount: repo.OpenIssuesCount,
+				ForksCount:      repo.ForksCount,
+				License:         repo.License,
 			}
 			detailedRepos = append(detailedRepos, basicRepo)
 		}
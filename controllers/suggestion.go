@@ -0,0 +1,362 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github-stars-manager/repository"
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SuggestionValues 仓库的分类、标签和描述
+type SuggestionValues struct {
+	Category    string   `json:"category"`
+	Tags        []string `json:"tags"`
+	Description string   `json:"description"`
+}
+
+// SuggestionView 带有仓库当前信息的AI建议，便于对比审核
+type SuggestionView struct {
+	repository.Suggestion
+	FullName string           `json:"full_name"`
+	Current  SuggestionValues `json:"current"`
+	// Locked 手动编辑过的字段，批量采用时会跳过
+	Locked []string `json:"locked"`
+	// Cached 分析结果来自缓存，没有调用AI服务
+	Cached bool `json:"cached,omitempty"`
+}
+
+// SuggestionResult 单个建议的处理结果
+type SuggestionResult struct {
+	RepoID int64 `json:"repo_id"`
+	// Applied 写入的字段，拒绝建议时为空
+	Applied []string `json:"applied"`
+	// Skipped 因被锁定而跳过的字段
+	Skipped []string `json:"skipped"`
+}
+
+// suggestionRequest 批量处理建议的请求体
+type suggestionRequest struct {
+	// IDs 要处理的仓库ID
+	IDs []int64 `json:"ids"`
+	// All 为 true 时处理所有待审核的建议
+	All bool `json:"all"`
+	// Fields 只采用这些字段；显式指定的字段即使被锁定也会写入并解除锁定
+	Fields []string `json:"fields"`
+}
+
+// GetSuggestions 获取所有待审核的AI建议，按生成时间从新到旧排列
+func (h *StarHandler) GetSuggestions(c *gin.Context) {
+	suggestions, err := h.repo.GetSuggestions()
+	if err != nil {
+		h.logger.Error("加载AI建议失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载AI建议失败"})
+		return
+	}
+
+	list := make([]repository.Suggestion, 0, len(suggestions))
+	for _, s := range suggestions {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt != list[j].CreatedAt {
+			return list[i].CreatedAt > list[j].CreatedAt
+		}
+		return list[i].RepoID < list[j].RepoID
+	})
+
+	views, err := h.suggestionViews(list)
+	if err != nil {
+		h.logger.Error("加载仓库信息失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载仓库信息失败"})
+		return
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// AcceptSuggestion 采用单个仓库的AI建议，请求体可以用 fields 指定只采用部分字段
+func (h *StarHandler) AcceptSuggestion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "仓库ID格式错误"})
+		return
+	}
+	var req suggestionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据格式错误"})
+			return
+		}
+	}
+	h.handleSuggestions(c, []int64{id}, req.Fields, true)
+}
+
+// RejectSuggestion 拒绝单个仓库的AI建议
+func (h *StarHandler) RejectSuggestion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "仓库ID格式错误"})
+		return
+	}
+	h.handleSuggestions(c, []int64{id}, nil, false)
+}
+
+// AcceptSuggestions 批量采用AI建议
+func (h *StarHandler) AcceptSuggestions(c *gin.Context) {
+	h.handleBulkSuggestions(c, true)
+}
+
+// RejectSuggestions 批量拒绝AI建议
+func (h *StarHandler) RejectSuggestions(c *gin.Context) {
+	h.handleBulkSuggestions(c, false)
+}
+
+// UnlockRepoFields 解除仓库字段的锁定，使其可以被批量采用的AI建议更新
+func (h *StarHandler) UnlockRepoFields(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "仓库ID格式错误"})
+		return
+	}
+	var body struct {
+		// Fields 要解除锁定的字段，为空时解除所有锁定
+		Fields []string `json:"fields"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据格式错误"})
+			return
+		}
+	}
+	if err := validateSuggestionFields(body.Fields); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tagInfo, err := h.repo.GetRepoTag(id)
+	if err != nil {
+		h.logger.Error("加载仓库标签失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载仓库标签失败"})
+		return
+	}
+	if tagInfo == nil {
+		c.JSON(http.StatusOK, gin.H{"locked": []string{}})
+		return
+	}
+
+	tagInfo.Unlock(body.Fields...)
+	if tagInfo.Empty() {
+		err = h.repo.DeleteRepoTag(id)
+	} else {
+		err = h.repo.SaveRepoTag(tagInfo)
+	}
+	if err != nil {
+		h.logger.Error("保存仓库标签失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存仓库标签失败"})
+		return
+	}
+
+	h.logger.Info("解除字段锁定", zap.Int64("repo_id", id), zap.Strings("fields", body.Fields))
+	locked := tagInfo.Locked
+	if locked == nil {
+		locked = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"locked": locked})
+}
+
+// handleBulkSuggestions 解析批量请求，ids 为空时必须显式指定 all
+func (h *StarHandler) handleBulkSuggestions(c *gin.Context, accept bool) {
+	var req suggestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据格式错误"})
+		return
+	}
+
+	ids := req.IDs
+	if req.All {
+		suggestions, err := h.repo.GetSuggestions()
+		if err != nil {
+			h.logger.Error("加载AI建议失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "加载AI建议失败"})
+			return
+		}
+		ids = make([]int64, 0, len(suggestions))
+		for id := range suggestions {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	} else if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请指定 ids 或 all"})
+		return
+	}
+
+	h.handleSuggestions(c, ids, req.Fields, accept)
+}
+
+// handleSuggestions 采用或拒绝建议并返回处理结果，处理后的建议从待审核列表中移除
+func (h *StarHandler) handleSuggestions(c *gin.Context, ids []int64, fields []string, accept bool) {
+	if err := validateSuggestionFields(fields); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	suggestions, err := h.repo.GetSuggestions()
+	if err != nil {
+		h.logger.Error("加载AI建议失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载AI建议失败"})
+		return
+	}
+	tags, err := h.repo.GetRepoTags()
+	if err != nil {
+		h.logger.Error("加载仓库标签失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载仓库标签失败"})
+		return
+	}
+
+	results := make([]SuggestionResult, 0, len(ids))
+	missing := make([]int64, 0)
+	var resolved []int64
+	var updates []repository.RepoTag
+	for _, id := range ids {
+		suggestion, ok := suggestions[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		resolved = append(resolved, id)
+
+		result := SuggestionResult{RepoID: id, Applied: []string{}, Skipped: []string{}}
+		if accept {
+			tagInfo := tags[id]
+			tagInfo.ID = id
+			result.Applied, result.Skipped = applySuggestion(&tagInfo, suggestion, fields)
+			if len(result.Applied) > 0 {
+				updates = append(updates, tagInfo)
+			}
+		}
+		results = append(results, result)
+	}
+
+	if len(updates) > 0 {
+		if err := h.repo.SaveRepoTags(updates); err != nil {
+			h.logger.Error("保存仓库标签失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存仓库标签失败"})
+			return
+		}
+	}
+	if len(resolved) > 0 {
+		if err := h.repo.DeleteSuggestions(resolved); err != nil {
+			h.logger.Error("删除AI建议失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除AI建议失败"})
+			return
+		}
+	}
+
+	h.logger.Info("处理AI建议完成",
+		zap.Bool("accept", accept),
+		zap.Int("resolved", len(resolved)),
+		zap.Int("missing", len(missing)))
+	if len(ids) == 1 && len(missing) == 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该仓库没有待审核的建议"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"resolved": len(resolved),
+		"results":  results,
+		"missing":  missing,
+	})
+}
+
+// applySuggestion 把建议写入标签信息，返回写入和跳过的字段
+//
+// fields 为空时采用所有未锁定的字段；显式指定的字段视为用户的选择，即使被锁定也会写入并解除锁定。
+func applySuggestion(tagInfo *repository.RepoTag, suggestion repository.Suggestion, fields []string) (applied, skipped []string) {
+	applied, skipped = []string{}, []string{}
+	explicit := len(fields) > 0
+	if !explicit {
+		fields = repository.SuggestionFields
+	}
+
+	for _, field := range fields {
+		if !explicit && tagInfo.IsLocked(field) {
+			skipped = append(skipped, field)
+			continue
+		}
+		switch field {
+		case repository.FieldTag:
+			tagInfo.Tag = strings.Join(suggestion.Tags, ",")
+		case repository.FieldCategory:
+			tagInfo.Category = suggestion.Category
+		case repository.FieldDescription:
+			tagInfo.Description = suggestion.Description
+		}
+		tagInfo.Unlock(field)
+		applied = append(applied, field)
+	}
+	return applied, skipped
+}
+
+// validateSuggestionFields 检查字段名是否有效
+func validateSuggestionFields(fields []string) error {
+	for _, field := range fields {
+		if !containsString(repository.SuggestionFields, field) {
+			return fmt.Errorf("未知字段: %s，可选 %s", field, strings.Join(repository.SuggestionFields, "、"))
+		}
+	}
+	return nil
+}
+
+// suggestionView 返回带有仓库当前信息的单个建议
+func (h *StarHandler) suggestionView(suggestion repository.Suggestion) (*SuggestionView, error) {
+	views, err := h.suggestionViews([]repository.Suggestion{suggestion})
+	if err != nil {
+		return nil, err
+	}
+	return &views[0], nil
+}
+
+// suggestionViews 为建议附加仓库名称、当前的分类、标签和描述以及锁定的字段
+func (h *StarHandler) suggestionViews(list []repository.Suggestion) ([]SuggestionView, error) {
+	tags, err := h.repo.GetRepoTags()
+	if err != nil {
+		return nil, err
+	}
+	repos, err := h.repo.GetReposWithTag()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]utils.Repo, len(repos))
+	for _, repo := range repos {
+		byID[repo.ID] = repo
+	}
+
+	views := make([]SuggestionView, 0, len(list))
+	for _, suggestion := range list {
+		view := SuggestionView{Suggestion: suggestion, Locked: []string{}}
+		if repo, ok := byID[suggestion.RepoID]; ok {
+			view.FullName = repo.FullName()
+			view.Current.Description = repo.Description
+		}
+		if tagInfo, ok := tags[suggestion.RepoID]; ok {
+			view.Current.Category = tagInfo.Category
+			view.Current.Tags = utils.SplitTags(tagInfo.Tag)
+			if tagInfo.Description != "" {
+				view.Current.Description = tagInfo.Description
+			}
+			if tagInfo.Locked != nil {
+				view.Locked = tagInfo.Locked
+			}
+		}
+		if view.Current.Tags == nil {
+			view.Current.Tags = []string{}
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
@@ -0,0 +1,679 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github-stars-manager/config"
+	"github-stars-manager/repository"
+	"github-stars-manager/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// tagSyncFormatVersion 共享标签文件的格式版本，格式不兼容时递增
+	tagSyncFormatVersion = 1
+	// tagSyncFileName 共享标签文件的名称，保存在备份目录中
+	tagSyncFileName = "tags.json"
+	// maxTagSyncFileSize 共享标签文件的最大字节数
+	maxTagSyncFileSize = 50 << 20
+	// tagSyncAttempts 远程文件被其他实例修改时的最大尝试次数
+	tagSyncAttempts = 3
+	// tagSyncTimeout 定时同步的超时时间
+	tagSyncTimeout = 2 * time.Minute
+	// maxTagConflicts 最多保留的冲突记录数量
+	maxTagConflicts = 500
+)
+
+var errTagSyncRunning = errors.New("标签同步正在进行，请稍后再试")
+
+// SharedTagStore 保存在WebDAV上、由多个实例共享的标签数据
+type SharedTagStore struct {
+	Version   int    `json:"version"`
+	UpdatedAt string `json:"updated_at"`
+	UpdatedBy string `json:"updated_by"`
+	// Records 以仓库ID为键的标签记录，删除的记录保留删除时间
+	Records map[int64]SharedTagRecord `json:"records"`
+}
+
+// SharedTagRecord 共享的仓库标签记录
+type SharedTagRecord struct {
+	Tag         string `json:"tag,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Modified 各字段最后修改的时间
+	Modified map[string]string `json:"modified,omitempty"`
+	// Deleted 记录被删除的时间，未删除时为空
+	Deleted string `json:"deleted,omitempty"`
+	// UpdatedBy 最后修改记录的实例
+	UpdatedBy string `json:"updated_by,omitempty"`
+}
+
+// TagSyncResult 一次标签同步的结果
+type TagSyncResult struct {
+	// Pulled 本地被远程修改更新的记录数
+	Pulled int `json:"pulled"`
+	// Pushed 远程被本地修改更新的记录数
+	Pushed int `json:"pushed"`
+	// Conflicts 本次同步发现的冲突
+	Conflicts []repository.TagConflict `json:"conflicts"`
+	SyncedAt  string                   `json:"synced_at"`
+}
+
+// TagSyncHandler 处理多实例之间通过WebDAV双向同步标签
+type TagSyncHandler struct {
+	config      *config.Config
+	logger      *zap.Logger
+	repo        repository.Repository
+	settingsCli *utils.SettingsUtil
+	// instance 写入共享文件的实例名称
+	instance string
+	// running 同一时间只允许一个同步
+	running sync.Mutex
+}
+
+// NewTagSyncHandler 创建标签同步处理器实例
+func NewTagSyncHandler(config *config.Config, logger *zap.Logger, repo repository.Repository, settingsCli *utils.SettingsUtil) *TagSyncHandler {
+	instance, err := os.Hostname()
+	if err != nil || instance == "" {
+		instance = "unknown"
+	}
+	return &TagSyncHandler{
+		config:      config,
+		logger:      logger,
+		repo:        repo,
+		settingsCli: settingsCli,
+		instance:    instance,
+	}
+}
+
+// GetStatus 获取标签同步的设置、上次同步时间和未处理的冲突
+func (h *TagSyncHandler) GetStatus(c *gin.Context) {
+	settings, err := h.settingsCli.LoadSettings()
+	if err != nil {
+		h.logger.Error("加载设置失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载设置失败"})
+		return
+	}
+	state, err := h.repo.GetTagSyncState()
+	if err != nil {
+		h.logger.Error("获取标签同步状态失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取标签同步状态失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":   settings.WebDAV.TagSync && settings.WebDAV.Url != "",
+		"strategy":  settings.WebDAV.TagSyncMergeStrategy(),
+		"last_sync": state.LastSync,
+		"conflicts": state.Conflicts,
+	})
+}
+
+// SyncTags 立即与WebDAV上的共享标签双向同步
+func (h *TagSyncHandler) SyncTags(c *gin.Context) {
+	result, err := h.Sync(c.Request.Context())
+	if err != nil {
+		switch {
+		case errors.Is(err, errWebDAVNotConfigured):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, errTagSyncRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("同步标签失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "同步标签失败: " + err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ClearConflicts 清空冲突记录
+func (h *TagSyncHandler) ClearConflicts(c *gin.Context) {
+	state, err := h.repo.GetTagSyncState()
+	if err == nil {
+		state.Conflicts = []repository.TagConflict{}
+		err = h.repo.SaveTagSyncState(state)
+	}
+	if err != nil {
+		h.logger.Error("清空标签同步冲突失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "清空冲突失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "冲突已清空"})
+}
+
+// RunScheduler 后台按设置的间隔同步标签
+func (h *TagSyncHandler) RunScheduler() {
+	for {
+		interval := utils.DefaultTagSyncInterval
+		settings, err := h.settingsCli.LoadSettings()
+		if err != nil {
+			h.logger.Warn("加载标签同步设置失败", zap.Error(err))
+		} else {
+			interval = settings.WebDAV.TagSyncEvery()
+			if settings.WebDAV.TagSync && settings.WebDAV.Url != "" {
+				h.runScheduledSync()
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// runScheduledSync 执行一次定时同步
+func (h *TagSyncHandler) runScheduledSync() {
+	ctx, cancel := context.WithTimeout(context.Background(), tagSyncTimeout)
+	defer cancel()
+	result, err := h.Sync(ctx)
+	if err != nil {
+		if !errors.Is(err, errTagSyncRunning) {
+			h.logger.Error("定时同步标签失败", zap.Error(err))
+		}
+		return
+	}
+	h.logger.Info("定时同步标签完成",
+		zap.Int("pulled", result.Pulled),
+		zap.Int("pushed", result.Pushed),
+		zap.Int("conflicts", len(result.Conflicts)))
+}
+
+// Sync 与WebDAV上的共享标签双向同步
+//
+// 下载共享文件和它的ETag，与本地标签合并后，只在远程文件的ETag没有变化时上传合并结果，
+// 然后写入本地。上传时远程文件已被其他实例修改则重新下载合并，最多尝试 tagSyncAttempts 次。
+func (h *TagSyncHandler) Sync(ctx context.Context) (*TagSyncResult, error) {
+	if !h.running.TryLock() {
+		return nil, errTagSyncRunning
+	}
+	defer h.running.Unlock()
+
+	settings, err := h.settingsCli.LoadSettings()
+	if err != nil {
+		return nil, fmt.Errorf("加载设置失败: %w", err)
+	}
+	if settings.WebDAV.Url == "" {
+		return nil, errWebDAVNotConfigured
+	}
+	client := utils.NewWebDAVClient(settings.WebDAV)
+	dir := settings.WebDAV.BackupDirectory()
+	if err := client.MkdirAll(ctx, dir); err != nil {
+		return nil, fmt.Errorf("创建同步目录失败: %w", err)
+	}
+
+	state, err := h.repo.GetTagSyncState()
+	if err != nil {
+		return nil, err
+	}
+	// 以开始同步的时间作为本次同步时间，同步期间的本地修改在下次同步时仍视为新修改
+	startedAt := time.Now()
+	p := dir + "/" + tagSyncFileName
+	strategy := settings.WebDAV.TagSyncMergeStrategy()
+
+	var result *TagSyncResult
+	var etag string
+	for attempt := 1; ; attempt++ {
+		result, etag, err = h.syncOnce(ctx, client, p, strategy, parseTime(state.LastSync))
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, utils.ErrWebDAVPreconditionFailed) {
+			return nil, err
+		}
+		if attempt >= tagSyncAttempts {
+			return nil, fmt.Errorf("远程标签文件正在被其他实例修改，请稍后再试: %w", err)
+		}
+		h.logger.Info("远程标签文件已被其他实例修改，重新合并", zap.Int("attempt", attempt))
+	}
+
+	result.SyncedAt = startedAt.Format(time.RFC3339)
+	state.LastSync = result.SyncedAt
+	state.ETag = etag
+	state.Conflicts = appendTagConflicts(state.Conflicts, result.Conflicts)
+	if err := h.repo.SaveTagSyncState(state); err != nil {
+		return nil, err
+	}
+	h.logger.Info("标签同步完成",
+		zap.String("strategy", strategy),
+		zap.Int("pulled", result.Pulled),
+		zap.Int("pushed", result.Pushed),
+		zap.Int("conflicts", len(result.Conflicts)))
+	return result, nil
+}
+
+// syncOnce 下载、合并并上传一次共享标签，返回同步结果和远程文件的新ETag
+func (h *TagSyncHandler) syncOnce(ctx context.Context, client *utils.WebDAVClient, p, strategy string, lastSync time.Time) (*TagSyncResult, string, error) {
+	store, etag, err := downloadTagStore(ctx, client, p)
+	if err != nil {
+		return nil, "", err
+	}
+	local, err := h.repo.GetRepoTags()
+	if err != nil {
+		return nil, "", err
+	}
+	deletions, err := h.repo.GetTagDeletions()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	plan := planTagSync(local, deletions, store, strategy, lastSync, h.instance)
+	if len(plan.conflicts) > 0 {
+		names := h.repoNames()
+		for i := range plan.conflicts {
+			plan.conflicts[i].FullName = names[plan.conflicts[i].RepoID]
+			plan.conflicts[i].DetectedAt = now
+		}
+	}
+
+	if plan.storeChanged {
+		store.Version = tagSyncFormatVersion
+		store.UpdatedAt = now
+		store.UpdatedBy = h.instance
+		store.Records = plan.records
+		data, err := json.MarshalIndent(store, "", "  ")
+		if err != nil {
+			return nil, "", fmt.Errorf("序列化共享标签失败: %w", err)
+		}
+		// 部分服务器忽略If-Match，上传前再确认一次ETag，缩小并发写入的窗口
+		if err := checkTagStoreETag(ctx, client, p, etag); err != nil {
+			return nil, "", err
+		}
+		newETag, err := client.PutIfMatch(ctx, p, data, "application/json", etag)
+		if err != nil {
+			return nil, "", err
+		}
+		if newETag == "" {
+			newETag, _ = client.ETag(ctx, p)
+		}
+		etag = newETag
+	}
+
+	if len(plan.localUpdates) > 0 || len(plan.localDeletes) > 0 {
+		if err := h.repo.MergeRepoTags(plan.localUpdates, plan.localDeletes); err != nil {
+			return nil, "", fmt.Errorf("写入本地标签失败: %w", err)
+		}
+	}
+
+	conflicts := plan.conflicts
+	if conflicts == nil {
+		conflicts = []repository.TagConflict{}
+	}
+	return &TagSyncResult{Pulled: plan.pulled, Pushed: plan.pushed, Conflicts: conflicts}, etag, nil
+}
+
+// repoNames 返回仓库ID到仓库全名的映射，读取失败时返回空映射
+func (h *TagSyncHandler) repoNames() map[int64]string {
+	names := make(map[int64]string)
+	repos, err := h.repo.GetReposWithTag()
+	if err != nil {
+		return names
+	}
+	for _, r := range repos {
+		names[r.ID] = r.FullName()
+	}
+	return names
+}
+
+// downloadTagStore 下载共享标签文件和它的ETag，文件不存在时返回空数据和空ETag
+func downloadTagStore(ctx context.Context, client *utils.WebDAVClient, p string) (*SharedTagStore, string, error) {
+	store := &SharedTagStore{Version: tagSyncFormatVersion}
+	data, etag, err := client.Get(ctx, p, maxTagSyncFileSize)
+	if errors.Is(err, utils.ErrWebDAVNotFound) {
+		store.Records = make(map[int64]SharedTagRecord)
+		return store, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("下载共享标签失败: %w", err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, "", fmt.Errorf("解析共享标签失败: %w", err)
+	}
+	if store.Version > tagSyncFormatVersion {
+		return nil, "", fmt.Errorf("共享标签的格式版本 %d 高于当前支持的版本 %d，请先升级", store.Version, tagSyncFormatVersion)
+	}
+	if store.Records == nil {
+		store.Records = make(map[int64]SharedTagRecord)
+	}
+	return store, etag, nil
+}
+
+// checkTagStoreETag 确认远程文件在下载之后没有被修改，已被修改时返回 utils.ErrWebDAVPreconditionFailed
+func checkTagStoreETag(ctx context.Context, client *utils.WebDAVClient, p, etag string) error {
+	current, err := client.ETag(ctx, p)
+	if errors.Is(err, utils.ErrWebDAVNotFound) {
+		if etag == "" {
+			return nil
+		}
+		return utils.ErrWebDAVPreconditionFailed
+	}
+	if err != nil {
+		return err
+	}
+	// 下载时文件还不存在，说明其他实例刚刚创建了文件
+	if etag == "" {
+		return utils.ErrWebDAVPreconditionFailed
+	}
+	// 服务器没有返回ETag时无法比较，只能依赖If-Match
+	if current != "" && current != etag {
+		return utils.ErrWebDAVPreconditionFailed
+	}
+	return nil
+}
+
+// tagSyncPlan 合并本地和远程标签后需要执行的修改
+type tagSyncPlan struct {
+	// records 合并后的共享记录
+	records      map[int64]SharedTagRecord
+	storeChanged bool
+	localUpdates []repository.RepoTag
+	localDeletes map[int64]string
+	conflicts    []repository.TagConflict
+	pulled       int
+	pushed       int
+}
+
+// tagVersion 一方的标签记录，删除的记录所有字段为空，修改时间为删除时间
+type tagVersion struct {
+	values   map[string]string
+	modified map[string]string
+}
+
+// localTagVersion 返回本地的记录，deletedAt 为本地删除记录的时间
+func localTagVersion(tag *repository.RepoTag, deletedAt string) tagVersion {
+	v := tagVersion{values: make(map[string]string), modified: make(map[string]string)}
+	for _, field := range repository.SuggestionFields {
+		if tag != nil {
+			v.values[field] = tag.Value(field)
+			v.modified[field] = tag.Modified[field]
+		} else {
+			v.modified[field] = deletedAt
+		}
+	}
+	return v
+}
+
+// remoteTagVersion 返回共享文件中的记录
+func remoteTagVersion(rec *SharedTagRecord) tagVersion {
+	v := tagVersion{values: make(map[string]string), modified: make(map[string]string)}
+	if rec == nil {
+		return v
+	}
+	tag := repository.RepoTag{Tag: rec.Tag, Category: rec.Category, Description: rec.Description}
+	for _, field := range repository.SuggestionFields {
+		if rec.Deleted != "" {
+			v.modified[field] = rec.Deleted
+			continue
+		}
+		v.values[field] = tag.Value(field)
+		v.modified[field] = rec.Modified[field]
+	}
+	return v
+}
+
+// empty 判断记录的所有字段是否为空
+func (v tagVersion) empty() bool {
+	for _, value := range v.values {
+		if value != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// sameValues 判断两条记录的值是否相同
+func (v tagVersion) sameValues(other tagVersion) bool {
+	for _, field := range repository.SuggestionFields {
+		if v.values[field] != other.values[field] {
+			return false
+		}
+	}
+	return true
+}
+
+// sameModified 判断两条记录的修改时间是否相同
+func (v tagVersion) sameModified(other tagVersion) bool {
+	for _, field := range repository.SuggestionFields {
+		if !parseTime(v.modified[field]).Equal(parseTime(other.modified[field])) {
+			return false
+		}
+	}
+	return true
+}
+
+// latest 返回记录中最晚的修改时间
+func (v tagVersion) latest() string {
+	latest := ""
+	for _, field := range repository.SuggestionFields {
+		if laterTime(v.modified[field], latest) {
+			latest = v.modified[field]
+		}
+	}
+	return latest
+}
+
+// key 返回记录所有字段的值，修改时间相同时用于确定性地选择一方
+func (v tagVersion) key() string {
+	parts := make([]string, len(repository.SuggestionFields))
+	for i, field := range repository.SuggestionFields {
+		parts[i] = v.values[field]
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// planTagSync 合并本地和远程的标签，计算需要写入本地和远程的修改
+//
+// field 策略按字段比较修改时间，较晚修改的值胜出；record 策略比较整条记录最后修改的时间，
+// 较晚修改的记录整条胜出。修改时间相同时按值排序选择，保证所有实例得到相同的结果。
+// 两边在上次同步之后都修改了同一字段且值不同时记为冲突。
+func planTagSync(local map[int64]repository.RepoTag, deletions map[int64]string, store *SharedTagStore, strategy string, lastSync time.Time, instance string) *tagSyncPlan {
+	plan := &tagSyncPlan{
+		records:      make(map[int64]SharedTagRecord),
+		localDeletes: make(map[int64]string),
+	}
+
+	ids := make(map[int64]bool)
+	for id := range local {
+		ids[id] = true
+	}
+	for id := range deletions {
+		ids[id] = true
+	}
+	for id := range store.Records {
+		ids[id] = true
+	}
+	sorted := make([]int64, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	for _, id := range sorted {
+		var localTag *repository.RepoTag
+		if tag, ok := local[id]; ok {
+			localTag = &tag
+		}
+		var remoteRec *SharedTagRecord
+		if rec, ok := store.Records[id]; ok {
+			remoteRec = &rec
+		}
+		lv := localTagVersion(localTag, deletions[id])
+		rv := remoteTagVersion(remoteRec)
+
+		merged, localWins := mergeTagVersions(lv, rv, strategy)
+		for _, field := range repository.SuggestionFields {
+			if lv.values[field] == rv.values[field] {
+				continue
+			}
+			// record 策略下只要两边的记录在上次同步后都修改过，不同的字段都算冲突
+			localChanged, remoteChanged := lv.modified[field], rv.modified[field]
+			if strategy == utils.TagSyncStrategyRecord {
+				localChanged, remoteChanged = lv.latest(), rv.latest()
+			}
+			if !parseTime(localChanged).After(lastSync) || !parseTime(remoteChanged).After(lastSync) {
+				continue
+			}
+			conflict := repository.TagConflict{
+				RepoID:         id,
+				Field:          field,
+				Local:          lv.values[field],
+				Remote:         rv.values[field],
+				LocalModified:  lv.modified[field],
+				RemoteModified: rv.modified[field],
+				Kept:           "remote",
+			}
+			if remoteRec != nil {
+				conflict.RemoteBy = remoteRec.UpdatedBy
+			}
+			if merged.values[field] == lv.values[field] {
+				conflict.Kept = "local"
+			}
+			plan.conflicts = append(plan.conflicts, conflict)
+		}
+
+		// 写入本地
+		if merged.empty() {
+			if localTag != nil {
+				plan.localDeletes[id] = merged.latest()
+				plan.pulled++
+			}
+		} else if localTag == nil || !merged.sameValues(lv) || !merged.sameModified(lv) {
+			tag := repository.RepoTag{ID: id, Modified: make(map[string]string)}
+			for _, field := range repository.SuggestionFields {
+				tag.SetValue(field, merged.values[field])
+				if merged.modified[field] != "" {
+					tag.Modified[field] = merged.modified[field]
+				}
+			}
+			if localTag != nil {
+				tag.Locked = localTag.Locked
+			}
+			plan.localUpdates = append(plan.localUpdates, tag)
+			if localTag == nil || !merged.sameValues(lv) {
+				plan.pulled++
+			}
+		}
+
+		// 写入远程，两边都没有的记录不需要保存删除时间
+		if merged.empty() && remoteRec == nil && lv.empty() && deletions[id] == "" {
+			continue
+		}
+		rec := SharedTagRecord{UpdatedBy: instance}
+		if remoteRec != nil && !localWins {
+			rec.UpdatedBy = remoteRec.UpdatedBy
+		}
+		if merged.empty() {
+			rec.Deleted = merged.latest()
+		} else {
+			rec.Tag = merged.values[repository.FieldTag]
+			rec.Category = merged.values[repository.FieldCategory]
+			rec.Description = merged.values[repository.FieldDescription]
+			rec.Modified = make(map[string]string)
+			for field, t := range merged.modified {
+				if t != "" {
+					rec.Modified[field] = t
+				}
+			}
+		}
+		remoteDeleted := remoteRec != nil && remoteRec.Deleted != ""
+		if remoteRec == nil || !merged.sameValues(rv) || !merged.sameModified(rv) || remoteDeleted != (rec.Deleted != "") {
+			plan.storeChanged = true
+			if remoteRec == nil || !merged.sameValues(rv) || remoteDeleted != (rec.Deleted != "") {
+				plan.pushed++
+			}
+		} else {
+			// 没有变化时保持远程记录原样
+			rec = *remoteRec
+		}
+		plan.records[id] = rec
+	}
+	return plan
+}
+
+// mergeTagVersions 按策略合并一条记录，同时返回合并结果是否包含本地的修改
+func mergeTagVersions(local, remote tagVersion, strategy string) (tagVersion, bool) {
+	merged := tagVersion{values: make(map[string]string), modified: make(map[string]string)}
+	localWins := false
+
+	if strategy == utils.TagSyncStrategyRecord && !local.sameValues(remote) {
+		lt, rt := local.latest(), remote.latest()
+		winner := remote
+		if laterTime(lt, rt) || (parseTime(lt).Equal(parseTime(rt)) && local.key() > remote.key()) {
+			winner = local
+			localWins = true
+		}
+		// 修改时间取两边较晚的，使落败一方较新的字段也被整条记录覆盖
+		for _, field := range repository.SuggestionFields {
+			merged.values[field] = winner.values[field]
+			merged.modified[field] = local.modified[field]
+			if laterTime(remote.modified[field], local.modified[field]) {
+				merged.modified[field] = remote.modified[field]
+			}
+		}
+		return merged, localWins
+	}
+
+	for _, field := range repository.SuggestionFields {
+		lv, rv := local.values[field], remote.values[field]
+		lt, rt := local.modified[field], remote.modified[field]
+		if lv == rv {
+			merged.values[field] = lv
+			merged.modified[field] = rt
+			if laterTime(lt, rt) {
+				merged.modified[field] = lt
+			}
+			continue
+		}
+		if laterTime(lt, rt) || (parseTime(lt).Equal(parseTime(rt)) && lv > rv) {
+			merged.values[field] = lv
+			merged.modified[field] = lt
+			localWins = true
+		} else {
+			merged.values[field] = rv
+			merged.modified[field] = rt
+		}
+	}
+	return merged, localWins
+}
+
+// appendTagConflicts 追加新的冲突，同一仓库同一字段只保留最新的一条，超过 maxTagConflicts 时删除最早的
+func appendTagConflicts(existing, added []repository.TagConflict) []repository.TagConflict {
+	type key struct {
+		id    int64
+		field string
+	}
+	replaced := make(map[key]bool)
+	for _, c := range added {
+		replaced[key{c.RepoID, c.Field}] = true
+	}
+	result := make([]repository.TagConflict, 0, len(existing)+len(added))
+	for _, c := range existing {
+		if !replaced[key{c.RepoID, c.Field}] {
+			result = append(result, c)
+		}
+	}
+	result = append(result, added...)
+	if len(result) > maxTagConflicts {
+		result = result[len(result)-maxTagConflicts:]
+	}
+	return result
+}
+
+// parseTime 解析RFC3339时间，格式错误或为空时返回零值
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+// laterTime 判断时间 a 是否晚于 b
+func laterTime(a, b string) bool {
+	return parseTime(a).After(parseTime(b))
+}
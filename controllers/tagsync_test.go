@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github-stars-manager/repository"
+	"github-stars-manager/utils"
+)
+
+const (
+	tagSyncBefore = "2024-05-01T09:00:00Z"
+	tagSyncAfter1 = "2024-05-01T11:00:00Z"
+	tagSyncAfter2 = "2024-05-01T12:00:00Z"
+)
+
+// tagSyncLastSync 上次同步的时间，位于 tagSyncBefore 和 tagSyncAfter1 之间
+var tagSyncLastSync = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// tagSyncResultView 合并结果中测试关心的部分
+type tagSyncResultView struct {
+	// local 同步后本地的 tag/category，本地被删除时为 "<deleted>"
+	local string
+	// remote 同步后远程的 tag/category，远程记录为删除时为 "<deleted>"
+	remote string
+	// conflicts 冲突的字段和保留的一方
+	conflicts []string
+	pulled    int
+	pushed    int
+}
+
+func viewTagSyncPlan(plan *tagSyncPlan, local map[int64]repository.RepoTag) tagSyncResultView {
+	const id = 1
+	v := tagSyncResultView{pulled: plan.pulled, pushed: plan.pushed}
+
+	if _, ok := plan.localDeletes[id]; ok {
+		v.local = "<deleted>"
+	} else if tag, ok := local[id]; ok {
+		v.local = tag.Tag + "/" + tag.Category
+	}
+	for _, tag := range plan.localUpdates {
+		if tag.ID == id {
+			v.local = tag.Tag + "/" + tag.Category
+		}
+	}
+
+	if rec, ok := plan.records[id]; ok {
+		if rec.Deleted != "" {
+			v.remote = "<deleted>"
+		} else {
+			v.remote = rec.Tag + "/" + rec.Category
+		}
+	}
+	for _, c := range plan.conflicts {
+		v.conflicts = append(v.conflicts, c.Field+":"+c.Kept)
+	}
+	return v
+}
+
+func TestPlanTagSync(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  string
+		local     *repository.RepoTag
+		deletedAt string
+		remote    *SharedTagRecord
+		want      tagSyncResultView
+	}{
+		{
+			name:     "只有本地修改",
+			strategy: utils.TagSyncStrategyField,
+			local:    &repository.RepoTag{Tag: "go", Category: "lang", Modified: map[string]string{"tag": tagSyncAfter1, "category": tagSyncBefore}},
+			remote:   &SharedTagRecord{Tag: "old", Category: "lang", Modified: map[string]string{"tag": tagSyncBefore, "category": tagSyncBefore}},
+			want:     tagSyncResultView{local: "go/lang", remote: "go/lang", pushed: 1},
+		},
+		{
+			name:     "只有远程修改",
+			strategy: utils.TagSyncStrategyField,
+			local:    &repository.RepoTag{Tag: "old", Category: "lang", Modified: map[string]string{"tag": tagSyncBefore, "category": tagSyncBefore}},
+			remote:   &SharedTagRecord{Tag: "rust", Category: "lang", Modified: map[string]string{"tag": tagSyncAfter1, "category": tagSyncBefore}},
+			want:     tagSyncResultView{local: "rust/lang", remote: "rust/lang", pulled: 1},
+		},
+		{
+			name:     "新的远程记录",
+			strategy: utils.TagSyncStrategyField,
+			remote:   &SharedTagRecord{Tag: "rust", Modified: map[string]string{"tag": tagSyncAfter1}},
+			want:     tagSyncResultView{local: "rust/", remote: "rust/", pulled: 1},
+		},
+		{
+			name:     "两边都修改，远程较晚",
+			strategy: utils.TagSyncStrategyField,
+			local:    &repository.RepoTag{Tag: "go", Modified: map[string]string{"tag": tagSyncAfter1}},
+			remote:   &SharedTagRecord{Tag: "rust", Modified: map[string]string{"tag": tagSyncAfter2}},
+			want:     tagSyncResultView{local: "rust/", remote: "rust/", conflicts: []string{"tag:remote"}, pulled: 1},
+		},
+		{
+			name:     "两边都修改，本地较晚",
+			strategy: utils.TagSyncStrategyField,
+			local:    &repository.RepoTag{Tag: "go", Modified: map[string]string{"tag": tagSyncAfter2}},
+			remote:   &SharedTagRecord{Tag: "rust", Modified: map[string]string{"tag": tagSyncAfter1}},
+			want:     tagSyncResultView{local: "go/", remote: "go/", conflicts: []string{"tag:local"}, pushed: 1},
+		},
+		{
+			name:     "两边修改不同字段，按字段合并",
+			strategy: utils.TagSyncStrategyField,
+			local:    &repository.RepoTag{Tag: "go", Category: "lang", Modified: map[string]string{"tag": tagSyncAfter2, "category": tagSyncBefore}},
+			remote:   &SharedTagRecord{Tag: "old", Category: "tools", Modified: map[string]string{"tag": tagSyncBefore, "category": tagSyncAfter1}},
+			want:     tagSyncResultView{local: "go/tools", remote: "go/tools", pulled: 1, pushed: 1},
+		},
+		{
+			name:     "两边修改不同字段，整条记录以较晚的为准",
+			strategy: utils.TagSyncStrategyRecord,
+			local:    &repository.RepoTag{Tag: "go", Category: "lang", Modified: map[string]string{"tag": tagSyncAfter2, "category": tagSyncBefore}},
+			remote:   &SharedTagRecord{Tag: "old", Category: "tools", Modified: map[string]string{"tag": tagSyncBefore, "category": tagSyncAfter1}},
+			want:     tagSyncResultView{local: "go/lang", remote: "go/lang", conflicts: []string{"tag:local", "category:local"}, pushed: 1},
+		},
+		{
+			name:      "本地删除，远程之后修改",
+			strategy:  utils.TagSyncStrategyField,
+			deletedAt: tagSyncAfter1,
+			remote:    &SharedTagRecord{Tag: "rust", Modified: map[string]string{"tag": tagSyncAfter2}},
+			want:      tagSyncResultView{local: "rust/", remote: "rust/", conflicts: []string{"tag:remote"}, pulled: 1},
+		},
+		{
+			name:      "本地删除，远程之前修改",
+			strategy:  utils.TagSyncStrategyField,
+			deletedAt: tagSyncAfter2,
+			remote:    &SharedTagRecord{Tag: "rust", Modified: map[string]string{"tag": tagSyncAfter1}},
+			want:      tagSyncResultView{remote: "<deleted>", conflicts: []string{"tag:local"}, pushed: 1},
+		},
+		{
+			name:     "远程删除，本地之前修改",
+			strategy: utils.TagSyncStrategyField,
+			local:    &repository.RepoTag{Tag: "go", Modified: map[string]string{"tag": tagSyncAfter1}},
+			remote:   &SharedTagRecord{Deleted: tagSyncAfter2},
+			want:     tagSyncResultView{local: "<deleted>", remote: "<deleted>", conflicts: []string{"tag:remote"}, pulled: 1},
+		},
+		{
+			name:     "远程删除，本地未修改",
+			strategy: utils.TagSyncStrategyField,
+			local:    &repository.RepoTag{Tag: "go", Modified: map[string]string{"tag": tagSyncBefore}},
+			remote:   &SharedTagRecord{Deleted: tagSyncAfter1},
+			want:     tagSyncResultView{local: "<deleted>", remote: "<deleted>", pulled: 1},
+		},
+		{
+			name:     "修改时间相同，按值选择",
+			strategy: utils.TagSyncStrategyField,
+			local:    &repository.RepoTag{Tag: "go", Modified: map[string]string{"tag": tagSyncAfter1}},
+			remote:   &SharedTagRecord{Tag: "rust", Modified: map[string]string{"tag": tagSyncAfter1}},
+			want:     tagSyncResultView{local: "rust/", remote: "rust/", conflicts: []string{"tag:remote"}, pulled: 1},
+		},
+		{
+			name:     "修改时间相同，交换两边后结果不变",
+			strategy: utils.TagSyncStrategyField,
+			local:    &repository.RepoTag{Tag: "rust", Modified: map[string]string{"tag": tagSyncAfter1}},
+			remote:   &SharedTagRecord{Tag: "go", Modified: map[string]string{"tag": tagSyncAfter1}},
+			want:     tagSyncResultView{local: "rust/", remote: "rust/", conflicts: []string{"tag:local"}, pushed: 1},
+		},
+		{
+			name:     "整条记录修改时间相同，按值选择",
+			strategy: utils.TagSyncStrategyRecord,
+			local:    &repository.RepoTag{Tag: "go", Modified: map[string]string{"tag": tagSyncAfter1}},
+			remote:   &SharedTagRecord{Tag: "rust", Modified: map[string]string{"tag": tagSyncAfter1}},
+			want:     tagSyncResultView{local: "rust/", remote: "rust/", conflicts: []string{"tag:remote"}, pulled: 1},
+		},
+		{
+			name:     "整条记录修改时间相同，交换两边后结果不变",
+			strategy: utils.TagSyncStrategyRecord,
+			local:    &repository.RepoTag{Tag: "rust", Modified: map[string]string{"tag": tagSyncAfter1}},
+			remote:   &SharedTagRecord{Tag: "go", Modified: map[string]string{"tag": tagSyncAfter1}},
+			want:     tagSyncResultView{local: "rust/", remote: "rust/", conflicts: []string{"tag:local"}, pushed: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := map[int64]repository.RepoTag{}
+			if tt.local != nil {
+				tag := *tt.local
+				tag.ID = 1
+				local[1] = tag
+			}
+			deletions := map[int64]string{}
+			if tt.deletedAt != "" {
+				deletions[1] = tt.deletedAt
+			}
+			store := &SharedTagStore{Records: map[int64]SharedTagRecord{}}
+			if tt.remote != nil {
+				store.Records[1] = *tt.remote
+			}
+
+			plan := planTagSync(local, deletions, store, tt.strategy, tagSyncLastSync, "local")
+			if got := viewTagSyncPlan(plan, local); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planTagSync() = %+v，期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanTagSyncUnchanged(t *testing.T) {
+	local := map[int64]repository.RepoTag{
+		1: {ID: 1, Tag: "go", Modified: map[string]string{"tag": tagSyncBefore}},
+	}
+	store := &SharedTagStore{Records: map[int64]SharedTagRecord{
+		1: {Tag: "go", Modified: map[string]string{"tag": tagSyncBefore}, UpdatedBy: "other"},
+	}}
+	plan := planTagSync(local, nil, store, utils.TagSyncStrategyField, tagSyncLastSync, "local")
+	if plan.storeChanged || len(plan.localUpdates) > 0 || len(plan.localDeletes) > 0 || len(plan.conflicts) > 0 {
+		t.Fatalf("两边相同时不应有修改: %+v", plan)
+	}
+	if plan.records[1].UpdatedBy != "other" {
+		t.Errorf("没有变化的远程记录被修改: %+v", plan.records[1])
+	}
+}
+
+func TestMergeTagVersionsDeterministic(t *testing.T) {
+	version := func(tag, category, modified string) tagVersion {
+		return tagVersion{
+			values:   map[string]string{"tag": tag, "category": category, "description": ""},
+			modified: map[string]string{"tag": modified, "category": modified, "description": ""},
+		}
+	}
+	for _, strategy := range []string{utils.TagSyncStrategyField, utils.TagSyncStrategyRecord} {
+		a := version("go", "lang", tagSyncAfter1)
+		b := version("rust", "lang", tagSyncAfter1)
+		ab, aWins := mergeTagVersions(a, b, strategy)
+		ba, bWins := mergeTagVersions(b, a, strategy)
+		if !reflect.DeepEqual(ab, ba) {
+			t.Errorf("%s: 交换两边后合并结果不同: %+v 和 %+v", strategy, ab, ba)
+		}
+		if aWins == bWins {
+			t.Errorf("%s: 两次合并都认为本地胜出或都认为远程胜出", strategy)
+		}
+		if ab.values["tag"] != "rust" {
+			t.Errorf("%s: 合并后的 tag = %q，期望 rust", strategy, ab.values["tag"])
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github-stars-manager/repository"
+	"github-stars-manager/utils"
+
+	"go.uber.org/zap"
+)
+
+// AI调用用途
+const (
+	UsagePurposeAnalyze = "analyze"
+	UsagePurposeAsk     = "ask"
+)
+
+// meteredProvider 记录每次调用token用量的AI服务适配器
+type meteredProvider struct {
+	utils.LLMProvider
+	repo    repository.Repository
+	logger  *zap.Logger
+	purpose string
+	repoID  int64
+}
+
+// newMeteredProvider 包装AI服务适配器，调用成功后记录用量
+func newMeteredProvider(provider utils.LLMProvider, repo repository.Repository, logger *zap.Logger, purpose string, repoID int64) *meteredProvider {
+	return &meteredProvider{
+		LLMProvider: provider,
+		repo:        repo,
+		logger:      logger,
+		purpose:     purpose,
+		repoID:      repoID,
+	}
+}
+
+func (p *meteredProvider) Complete(ctx context.Context, req utils.CompletionRequest) (*utils.CompletionResponse, error) {
+	resp, err := p.LLMProvider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	record := &repository.AIUsageRecord{
+		Time:         time.Now().Format(time.RFC3339),
+		Provider:     p.Name(),
+		Model:        p.Model(),
+		Purpose:      p.purpose,
+		RepoID:       p.repoID,
+		InputTokens:  resp.Usage.InputTokens,
+		OutputTokens: resp.Usage.OutputTokens,
+	}
+	if err := p.repo.AppendAIUsage(record); err != nil {
+		// 记录失败不影响本次调用
+		p.logger.Warn("记录AI用量失败", zap.Error(err))
+	}
+	return resp, nil
+}
+
+// monthStart 返回 now 所在月份的第一天零点
+func monthStart(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+}
+
+// monthlyAICost 按当前价格估算本月的AI费用
+func monthlyAICost(repo repository.Repository, settings utils.AISettings) (float64, error) {
+	records, err := repo.GetAIUsage(monthStart(time.Now()))
+	if err != nil {
+		return 0, err
+	}
+	var cost float64
+	for _, r := range records {
+		cost += settings.Cost(r.Model, utils.TokenUsage{InputTokens: r.InputTokens, OutputTokens: r.OutputTokens})
+	}
+	return cost, nil
+}
+
+// aiBudgetExceeded 判断本月的估算费用是否已达到预算，未设置预算时始终返回false
+func aiBudgetExceeded(repo repository.Repository, settings utils.AISettings) (bool, error) {
+	if settings.MonthlyBudget <= 0 {
+		return false, nil
+	}
+	cost, err := monthlyAICost(repo, settings)
+	if err != nil {
+		return false, err
+	}
+	return cost >= settings.MonthlyBudget, nil
+}
+
+// analysisInputHash 计算AI分析输入的哈希，输入不变时可以直接使用缓存的结果
+//
+// 使用渲染后的提示而不是单独的字段，模板中用到的任何数据（例如已有的标签）变化后都会重新分析。
+// categories 用于校验AI返回的分类，不一定出现在提示中，因此单独计入。
+func analysisInputHash(provider utils.LLMProvider, tmpl utils.PromptTemplate, prompt string, categories []string) string {
+	input := struct {
+		Provider      string   `json:"provider"`
+		Model         string   `json:"model"`
+		PromptVersion string   `json:"prompt_version"`
+		Prompt        string   `json:"prompt"`
+		Categories    []string `json:"categories"`
+	}{
+		Provider:      provider.Name(),
+		Model:         provider.Model(),
+		PromptVersion: promptVersion(tmpl),
+		Prompt:        prompt,
+		Categories:    categories,
+	}
+	b, _ := json.Marshal(input)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github-stars-manager/config"
+	"github-stars-manager/session"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// userTouchInterval 更新用户最后活动时间的最小间隔，避免每个请求都写文件
+const userTouchInterval = time.Minute
+
+// orgCheck 用户组织成员身份的检查结果
+type orgCheck struct {
+	member    bool
+	checkedAt time.Time
+}
+
+// authorizeLogin 检查用户是否可以登录：未配置白名单、在用户白名单中、是管理员，或者是允许的组织的正式成员
+func (h *AuthHandler) authorizeLogin(login, token string) bool {
+	if h.config.LoginAllowed(login) {
+		return true
+	}
+	member, _ := h.checkOrgMember(login, token)
+	return member
+}
+
+// checkOrgMember 检查用户是否为任一允许的组织的正式成员，并记录检查结果
+//
+// 所有组织都检查失败或不是成员时返回 false；只要有一个组织检查失败就同时返回错误，此时不记录结果。
+func (h *AuthHandler) checkOrgMember(login, token string) (bool, error) {
+	var lastErr error
+	for _, org := range h.config.AllowedOrgList() {
+		member, err := h.githubCli.IsOrgMember(token, org)
+		if err != nil {
+			// 常见原因是token没有 read:org 权限，或者组织限制了OAuth App的访问
+			h.logger.Warn("检查组织成员身份失败", zap.String("user", login), zap.String("org", org), zap.Error(err))
+			lastErr = err
+			continue
+		}
+		if member {
+			h.recordOrgCheck(login, true)
+			return true, nil
+		}
+	}
+	if lastErr != nil {
+		return false, lastErr
+	}
+	h.recordOrgCheck(login, false)
+	return false, nil
+}
+
+// recordOrgCheck 记录用户组织成员身份的检查结果
+func (h *AuthHandler) recordOrgCheck(login string, member bool) {
+	h.orgMu.Lock()
+	defer h.orgMu.Unlock()
+	h.orgChecks[strings.ToLower(login)] = orgCheck{member: member, checkedAt: time.Now()}
+}
+
+// apiTokenOrgMember 判断API令牌的用户是否仍是允许的组织的成员，第二个返回值表示是否能够确认
+//
+// 检查结果缓存 apiTokenTouchInterval，过期后使用该用户已登录会话的token重新检查。用户没有已登录的会话时
+// 无法确认；重新检查失败时沿用上一次的结果。
+func (h *AuthHandler) apiTokenOrgMember(login string) (bool, bool) {
+	if len(h.config.AllowedOrgList()) == 0 {
+		return false, true
+	}
+	h.orgMu.Lock()
+	cached, ok := h.orgChecks[strings.ToLower(login)]
+	h.orgMu.Unlock()
+	if ok && time.Since(cached.checkedAt) < apiTokenTouchInterval {
+		return cached.member, true
+	}
+
+	id, active := session.ForUser(login)
+	if active == nil {
+		return false, false
+	}
+	fresh, err := h.app.Fresh(id)
+	if err != nil {
+		return false, false
+	}
+	member, err := h.checkOrgMember(login, fresh.AccessToken)
+	if err != nil {
+		if ok {
+			return cached.member, true
+		}
+		return false, false
+	}
+	return member, true
+}
+
+// tokenUserAllowed 检查API令牌或订阅源令牌的用户是否仍然允许访问，verified 为 false 表示无法确认组织成员身份
+func (h *AuthHandler) tokenUserAllowed(login string) (allowed bool, verified bool) {
+	if h.config.LoginAllowed(login) {
+		return true, true
+	}
+	return h.apiTokenOrgMember(login)
+}
+
+// recordLogin 设置会话的角色并记录用户登录
+func (h *AuthHandler) recordLogin(sess *session.SessionData) {
+	sess.Role = h.config.RoleFor(sess.UserName)
+	now := time.Now()
+	if err := h.repo.RecordUserLogin(sess.UserName, sess.AvatarURL, now.Format(time.RFC3339)); err != nil {
+		h.logger.Warn("记录用户登录失败", zap.String("user", sess.UserName), zap.Error(err))
+	}
+	h.touchMu.Lock()
+	h.touched[sess.UserName] = now
+	h.touchMu.Unlock()
+}
+
+// touchUser 更新用户的最后活动时间，距上次更新不足 userTouchInterval 时跳过
+func (h *AuthHandler) touchUser(login string) {
+	now := time.Now()
+	h.touchMu.Lock()
+	if last, ok := h.touched[login]; ok && now.Sub(last) < userTouchInterval {
+		h.touchMu.Unlock()
+		return
+	}
+	h.touched[login] = now
+	h.touchMu.Unlock()
+
+	if err := h.repo.TouchUser(login, now.Format(time.RFC3339)); err != nil {
+		h.logger.Warn("更新用户活动时间失败", zap.String("user", login), zap.Error(err))
+	}
+}
+
+// AdminOnly 只允许管理员访问的中间件，需要在 AuthMiddleware 之后使用
+func (h *AuthHandler) AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s, _ := c.Get("session")
+		if sess, ok := s.(*session.SessionData); !ok || sess.Role != config.RoleAdmin {
+			h.logger.Warn("非管理员访问管理功能", zap.String("path", c.Request.URL.Path))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+			return
+		}
+		c.Next()
+	}
+}
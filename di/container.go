@@ -23,6 +23,9 @@ func NewContainer() *dig.Container {
 
 	Container.Provide(utils.NewOpenAIUtil)
 
+	// 提供AI服务工具
+	Container.Provide(utils.NewLLMUtil)
+
 	Container.Provide(utils.NewSettingsUtil)
 
 	Container.Provide(utils.NewGithubCli)
@@ -30,14 +33,38 @@ func NewContainer() *dig.Container {
 	// 提供数据仓库
 	Container.Provide(repository.NewFileRepository)
 
+	// 提供GitHubAppAuth
+	Container.Provide(controllers.NewGitHubAppAuth)
+
 	// 提供StarHandler
 	Container.Provide(controllers.NewStarHandler)
 
 	// 提供AuthHandler
 	Container.Provide(controllers.NewAuthHandler)
 
+	// 提供AdminHandler
+	Container.Provide(controllers.NewAdminHandler)
+
 	Container.Provide(controllers.NewSettingsHandler)
 
+	// 提供ExportHandler
+	Container.Provide(controllers.NewExportHandler)
+
+	// 提供FeedHandler
+	Container.Provide(controllers.NewFeedHandler)
+
+	// 提供ReleaseHandler
+	Container.Provide(controllers.NewReleaseHandler)
+
+	// 提供AIHandler
+	Container.Provide(controllers.NewAIHandler)
+
+	// 提供BackupHandler
+	Container.Provide(controllers.NewBackupHandler)
+
+	// 提供TagSyncHandler
+	Container.Provide(controllers.NewTagSyncHandler)
+
 	// 提供路由引擎
 	Container.Provide(routes.SetupRouter)
 
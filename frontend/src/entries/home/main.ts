@@ -1,6 +1,7 @@
 import { createApp } from 'vue'
 import HomeView from '../../views/HomeView.vue'
 import '../../assets/main.css'
+import '../../utils/auth'
 
 const app = createApp(HomeView)
 app.mount('#app')
\ No newline at end of file
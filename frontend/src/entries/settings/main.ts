@@ -1,6 +1,7 @@
 import { createApp } from 'vue'
 import SettingsView from '../../views/SettingsView.vue'
 import '../../assets/main.css'
+import '../../utils/auth'
 
 const app = createApp(SettingsView)
 app.mount('#app')
\ No newline at end of file
@@ -0,0 +1,29 @@
+import axios from 'axios'
+
+// 接口返回401说明登录已过期，跳转到登录页
+export function redirectToLogin() {
+  window.location.href = '/login'
+}
+
+// 修改数据的请求需要在请求头中携带服务器通过cookie下发的CSRF令牌
+const csrfCookie = 'csrf_token'
+const csrfHeader = 'X-CSRF-Token'
+
+axios.defaults.xsrfCookieName = csrfCookie
+axios.defaults.xsrfHeaderName = csrfHeader
+
+// 返回携带CSRF令牌的请求头，供不经过axios的请求（如fetch）使用
+export function csrfHeaders(): Record<string, string> {
+  const match = document.cookie.match(new RegExp('(?:^|; )' + csrfCookie + '=([^;]*)'))
+  return match ? { [csrfHeader]: decodeURIComponent(match[1]) } : {}
+}
+
+axios.interceptors.response.use(
+  (response) => response,
+  (error) => {
+    if (error.response?.status === 401) {
+      redirectToLogin()
+    }
+    return Promise.reject(error)
+  }
+)
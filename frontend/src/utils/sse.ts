@@ -0,0 +1,51 @@
+import { csrfHeaders, redirectToLogin } from './auth'
+
+// 以POST方式请求返回SSE的接口，每收到一个事件调用一次 onEvent
+//
+// 服务端在开始输出事件前出错时返回JSON，此时抛出包含其中 error 字段的错误。
+export async function postSSE(url: string, body: any, onEvent: (event: string, data: any) => void) {
+  const res = await fetch(url, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json', ...csrfHeaders() },
+    credentials: 'same-origin',
+    body: JSON.stringify(body)
+  })
+  if (res.status === 401) {
+    redirectToLogin()
+  }
+  if (!res.ok || !res.body) {
+    const data = await res.json().catch(() => ({}))
+    throw new Error(data.error || `请求失败: ${res.status}`)
+  }
+
+  // 按空行拆分SSE事件
+  const reader = res.body.getReader()
+  const decoder = new TextDecoder()
+  let buffer = ''
+  for (;;) {
+    const { done, value } = await reader.read()
+    if (done) {
+      break
+    }
+    buffer += decoder.decode(value, { stream: true })
+    let end
+    while ((end = buffer.indexOf('\n\n')) >= 0) {
+      const block = buffer.slice(0, end)
+      buffer = buffer.slice(end + 2)
+      let event = 'message'
+      let data = ''
+      block.split('\n').forEach(line => {
+        if (line.startsWith('event:')) {
+          event = line.slice(6).trim()
+        } else if (line.startsWith('data:')) {
+          data += line.slice(5).replace(/^ /, '')
+        }
+      })
+      try {
+        onEvent(event, data ? JSON.parse(data) : {})
+      } catch (e) {
+        console.error('解析事件失败', e)
+      }
+    }
+  }
+}
@@ -45,6 +45,11 @@ export default defineConfig(({ mode }) => {
           changeOrigin: true,
           secure: false
         },
+        '/logout': {
+          target: env.VITE_API_URL,
+          changeOrigin: true,
+          secure: false
+        },
         '/auth': {
           target: env.VITE_API_URL,
           changeOrigin: true,
@@ -1,17 +1,16 @@
 package main
 
 import (
-	"github-stars-manager/di"
-	"github-stars-manager/routes"
+	"fmt"
+	"os"
+
+	"github-stars-manager/cli"
 )
 
 func main() {
-	// 从DIG容器中获取服务器实例并启动服务
-	container := di.NewContainer()
-	err := container.Invoke(func(server *routes.Server) error {
-		return server.Run()
-	})
-	if err != nil {
-		panic(err)
+	// 没有子命令时启动Web服务器，子命令见 cli 包
+	if err := cli.Run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		os.Exit(1)
 	}
 }
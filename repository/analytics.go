@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github-stars-manager/utils"
+)
+
+// CountItem 分布统计中的一项
+type CountItem struct {
+	Name    string  `json:"name"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// ByteShare 按代码字节数统计的语言占比
+type ByteShare struct {
+	Name    string  `json:"name"`
+	Bytes   int64   `json:"bytes"`
+	Percent float64 `json:"percent"`
+}
+
+// PeriodCount 按时间段统计的star数量
+type PeriodCount struct {
+	Period     string `json:"period"`
+	Count      int    `json:"count"`
+	Cumulative int    `json:"cumulative"`
+}
+
+// Analytics 仓库的语言、主题、分类、标签、许可证和star时间分布
+type Analytics struct {
+	TotalRepos     int           `json:"total_repos"`
+	Languages      []CountItem   `json:"languages"`
+	LanguageBytes  []ByteShare   `json:"language_bytes"`
+	Topics         []CountItem   `json:"topics"`
+	Categories     []CountItem   `json:"categories"`
+	Tags           []CountItem   `json:"tags"`
+	Licenses       []CountItem   `json:"licenses"`
+	StarredByMonth []PeriodCount `json:"starred_by_month"`
+	StarredByYear  []PeriodCount `json:"starred_by_year"`
+	GeneratedAt    string        `json:"generated_at"`
+}
+
+// computeAnalytics 一次遍历统计所有分布
+func computeAnalytics(repos []utils.Repo) *Analytics {
+	languages := make(map[string]int)
+	languageBytes := make(map[string]int64)
+	topics := make(map[string]int)
+	categories := make(map[string]int)
+	tags := make(map[string]int)
+	licenses := make(map[string]int)
+	months := make(map[string]int)
+	years := make(map[string]int)
+
+	for _, repo := range repos {
+		if repo.Language != "" {
+			languages[repo.Language]++
+		}
+		for lang, bytes := range repo.LanguageBytes {
+			languageBytes[lang] += bytes
+		}
+		for _, topic := range repo.Topics {
+			topics[topic]++
+		}
+		if repo.Category != "" {
+			categories[repo.Category]++
+		}
+		for _, tag := range utils.SplitTags(repo.Tag) {
+			tags[tag]++
+		}
+		if repo.License != nil && repo.License.SPDXID != "" && repo.License.SPDXID != "NOASSERTION" {
+			licenses[repo.License.SPDXID]++
+		} else if repo.License != nil && repo.License.Name != "" {
+			licenses[repo.License.Name]++
+		}
+		if starred := repo.StarredTime(); !starred.IsZero() {
+			months[starred.UTC().Format("2006-01")]++
+			years[starred.UTC().Format("2006")]++
+		}
+	}
+
+	total := len(repos)
+	return &Analytics{
+		TotalRepos:     total,
+		Languages:      rankCounts(languages, total),
+		LanguageBytes:  rankBytes(languageBytes),
+		Topics:         rankCounts(topics, total),
+		Categories:     rankCounts(categories, total),
+		Tags:           rankCounts(tags, total),
+		Licenses:       rankCounts(licenses, total),
+		StarredByMonth: periodSeries(months),
+		StarredByYear:  periodSeries(years),
+		GeneratedAt:    time.Now().Format(time.RFC3339),
+	}
+}
+
+// rankCounts 按数量从多到少排列，percent 为占全部仓库的百分比
+func rankCounts(counts map[string]int, total int) []CountItem {
+	items := make([]CountItem, 0, len(counts))
+	for name, count := range counts {
+		items = append(items, CountItem{Name: name, Count: count, Percent: percent(float64(count), float64(total))})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+// rankBytes 按字节数从多到少排列，percent 为占全部代码字节数的百分比
+func rankBytes(counts map[string]int64) []ByteShare {
+	var sum int64
+	for _, bytes := range counts {
+		sum += bytes
+	}
+	items := make([]ByteShare, 0, len(counts))
+	for name, bytes := range counts {
+		items = append(items, ByteShare{Name: name, Bytes: bytes, Percent: percent(float64(bytes), float64(sum))})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Bytes != items[j].Bytes {
+			return items[i].Bytes > items[j].Bytes
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+// periodSeries 按时间段升序排列，并计算累计数量
+func periodSeries(counts map[string]int) []PeriodCount {
+	items := make([]PeriodCount, 0, len(counts))
+	for period, count := range counts {
+		items = append(items, PeriodCount{Period: period, Count: count})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Period < items[j].Period })
+	cumulative := 0
+	for i := range items {
+		cumulative += items[i].Count
+		items[i].Cumulative = cumulative
+	}
+	return items
+}
+
+// percent 计算百分比，保留两位小数
+func percent(part, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(int(part/total*10000+0.5)) / 100
+}
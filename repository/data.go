@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github-stars-manager/utils"
 )
 
@@ -10,6 +12,104 @@ type RepoTag struct {
 	Tag         string `json:"tag"`
 	Category    string `json:"category"`
 	Description string `json:"description,omitempty"`
+	// Locked 用户手动编辑过的字段，AI建议不会覆盖这些字段
+	Locked []string `json:"locked,omitempty"`
+	// Modified 各字段最后修改的时间（RFC3339），用于多实例之间的标签同步
+	Modified map[string]string `json:"modified,omitempty"`
+}
+
+// 可由AI建议修改的字段
+const (
+	FieldTag         = "tag"
+	FieldCategory    = "category"
+	FieldDescription = "description"
+)
+
+// SuggestionFields AI建议包含的所有字段
+var SuggestionFields = []string{FieldTag, FieldCategory, FieldDescription}
+
+// IsLocked 判断字段是否被锁定
+func (t *RepoTag) IsLocked(field string) bool {
+	for _, f := range t.Locked {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Lock 锁定字段
+func (t *RepoTag) Lock(field string) {
+	if !t.IsLocked(field) {
+		t.Locked = append(t.Locked, field)
+	}
+}
+
+// Unlock 解除字段锁定，fields 为空时解除所有锁定
+func (t *RepoTag) Unlock(fields ...string) {
+	if len(fields) == 0 {
+		t.Locked = nil
+		return
+	}
+	var locked []string
+	for _, f := range t.Locked {
+		keep := true
+		for _, field := range fields {
+			if f == field {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			locked = append(locked, f)
+		}
+	}
+	t.Locked = locked
+}
+
+// Value 返回字段的值，未知的字段返回空字符串
+func (t *RepoTag) Value(field string) string {
+	switch field {
+	case FieldTag:
+		return t.Tag
+	case FieldCategory:
+		return t.Category
+	case FieldDescription:
+		return t.Description
+	}
+	return ""
+}
+
+// SetValue 设置字段的值，未知的字段忽略
+func (t *RepoTag) SetValue(field, value string) {
+	switch field {
+	case FieldTag:
+		t.Tag = value
+	case FieldCategory:
+		t.Category = value
+	case FieldDescription:
+		t.Description = value
+	}
+}
+
+// Empty 判断记录是否不再包含任何信息，可以删除
+func (t *RepoTag) Empty() bool {
+	return t.Tag == "" && t.Category == "" && t.Description == "" && len(t.Locked) == 0
+}
+
+// Suggestion 等待用户审核的AI分析建议
+type Suggestion struct {
+	RepoID      int64    `json:"repo_id"`
+	Category    string   `json:"category"`
+	Tags        []string `json:"tags"`
+	Description string   `json:"description"`
+	// Provider、Model 生成建议的AI服务和模型
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// PromptName、PromptVersion 使用的提示模板名称和模板内容的版本
+	PromptName    string `json:"prompt_name"`
+	PromptVersion string `json:"prompt_version"`
+	CreatedAt     string `json:"created_at"`
 }
 
 // Repository 定义数据访问接口
@@ -22,21 +122,266 @@ type Repository interface {
 	
 	// GetRepoTag 获取特定仓库的标签信息
 	GetRepoTag(repoID int64) (*RepoTag, error)
+
+	// GetRepoTags 获取所有仓库的标签信息
+	GetRepoTags() (map[int64]RepoTag, error)
 	
 	// SaveRepoTag 保存仓库标签信息
 	SaveRepoTag(tag *RepoTag) error
+
+	// SaveRepoTags 批量保存仓库标签信息
+	SaveRepoTags(tags []RepoTag) error
 	
 	// DeleteRepoTag 删除仓库标签信息
 	DeleteRepoTag(repoID int64) error
 	
-	// GetStats 获取统计信息
-	GetStats() (*Stats, error)
+	// GetStats 获取统计信息，按给定配置统计各健康状态的仓库数量
+	GetStats(health utils.HealthSettings) (*Stats, error)
+	
+	// GetAnalytics 获取语言、主题、分类、标签、许可证和star时间的分布统计
+	GetAnalytics() (*Analytics, error)
 	
 	// SaveSyncTime 保存同步时间
 	SaveSyncTime() error
 	
 	// LoadSyncTime 加载同步时间
 	LoadSyncTime() (string, error)
+
+	// GetFeedTokens 获取所有用户的订阅源令牌，以用户名为键
+	GetFeedTokens() (map[string]FeedToken, error)
+
+	// SaveFeedToken 保存用户的订阅源令牌
+	SaveFeedToken(token *FeedToken) error
+
+	// DeleteFeedToken 删除用户的订阅源令牌
+	DeleteFeedToken(userName string) error
+
+	// GetAPITokens 获取所有API令牌，以令牌ID为键
+	GetAPITokens() (map[string]APIToken, error)
+
+	// SaveAPIToken 保存API令牌
+	SaveAPIToken(token *APIToken) error
+
+	// DeleteAPIToken 删除API令牌
+	DeleteAPIToken(id string) error
+
+	// TouchAPIToken 更新API令牌的最后使用时间，令牌已被删除时不做任何修改
+	TouchAPIToken(id, usedAt string) error
+
+	// GetUsers 获取所有登录过的用户，以用户名为键
+	GetUsers() (map[string]UserRecord, error)
+
+	// RecordUserLogin 记录用户登录，第一次登录时创建用户
+	RecordUserLogin(login, avatarURL, loginAt string) error
+
+	// TouchUser 更新用户的最后活动时间，用户不存在时创建
+	TouchUser(login, activeAt string) error
+
+	// AppendRepoHistory 追加各仓库本次同步的数据点并压缩历史，不在 points 中的仓库的历史会被删除
+	AppendRepoHistory(points map[int64]HistoryPoint) error
+
+	// GetRepoHistory 获取单个仓库的历史数据，按时间升序
+	GetRepoHistory(repoID int64) ([]HistoryPoint, error)
+
+	// GetRepoHistories 获取所有仓库的历史数据，以仓库ID为键
+	GetRepoHistories() (map[int64][]HistoryPoint, error)
+
+	// GetReleaseStates 获取所有仓库的发布跟踪状态，以仓库ID为键
+	GetReleaseStates() (map[int64]ReleaseState, error)
+
+	// SaveReleaseStates 批量保存仓库的发布跟踪状态
+	SaveReleaseStates(states []ReleaseState) error
+
+	// GetReleaseVisit 获取用户上次查看发布时间线的时间
+	GetReleaseVisit(userName string) (string, error)
+
+	// SaveReleaseVisit 保存用户查看发布时间线的时间
+	SaveReleaseVisit(userName, visitedAt string) error
+
+	// GetSuggestions 获取所有等待审核的AI建议，以仓库ID为键
+	GetSuggestions() (map[int64]Suggestion, error)
+
+	// SaveSuggestion 保存AI建议，替换该仓库之前的建议
+	SaveSuggestion(suggestion *Suggestion) error
+
+	// DeleteSuggestions 删除指定仓库的AI建议
+	DeleteSuggestions(repoIDs []int64) error
+
+	// GetCachedAnalysis 按输入内容的哈希获取缓存的AI分析结果，不存在时返回nil
+	GetCachedAnalysis(hash string) (*CachedAnalysis, error)
+
+	// SaveCachedAnalysis 缓存AI分析结果，每个仓库只保留最新的一条
+	SaveCachedAnalysis(entry *CachedAnalysis) error
+
+	// AppendAIUsage 记录一次AI调用的用量，超过保留期限的记录会被删除
+	AppendAIUsage(record *AIUsageRecord) error
+
+	// GetAIUsage 获取指定时间之后的AI调用记录，按时间升序
+	GetAIUsage(since time.Time) ([]AIUsageRecord, error)
+
+	// GetReadmes 获取所有缓存的README，以仓库ID为键
+	GetReadmes() (map[int64]CachedReadme, error)
+
+	// SaveReadme 缓存仓库的README
+	SaveReadme(readme *CachedReadme) error
+
+	// SnapshotFiles 读取所有需要备份的数据文件，以文件名为键，不存在的文件不包括在内
+	SnapshotFiles() (map[string][]byte, error)
+
+	// RestoreFiles 用备份中的数据文件替换当前数据，备份中没有的数据文件会被删除
+	RestoreFiles(files map[string][]byte) error
+
+	// GetTagDeletions 获取已删除的仓库标签记录的删除时间（RFC3339），以仓库ID为键
+	GetTagDeletions() (map[int64]string, error)
+
+	// MergeRepoTags 写入标签同步合并后的记录，保留记录中的修改时间；deleted 中的记录按给定的删除时间删除
+	MergeRepoTags(tags []RepoTag, deleted map[int64]string) error
+
+	// GetTagSyncState 获取标签同步的状态
+	GetTagSyncState() (*TagSyncState, error)
+
+	// SaveTagSyncState 保存标签同步的状态
+	SaveTagSyncState(state *TagSyncState) error
+}
+
+// BackupFiles 需要备份的数据文件，订阅源令牌等密钥不包括在内，设置文件由调用方单独处理
+var BackupFiles = []string{
+	"repos.json",
+	"repo_tags.json",
+	"last_sync.txt",
+	"history.json",
+	"releases.json",
+	"release_visits.json",
+	"suggestions.json",
+	"ai_cache.json",
+	"ai_usage.json",
+	"readmes.json",
+	"tag_deletions.json",
+}
+
+// CachedReadme 缓存的仓库README，用于问答检索
+type CachedReadme struct {
+	RepoID    int64  `json:"repo_id"`
+	Content   string `json:"content"`
+	FetchedAt string `json:"fetched_at"`
+}
+
+// CachedAnalysis 缓存的AI分析结果，以分析输入的哈希为键
+type CachedAnalysis struct {
+	Hash        string   `json:"hash"`
+	RepoID      int64    `json:"repo_id"`
+	Category    string   `json:"category"`
+	Tags        []string `json:"tags"`
+	Description string   `json:"description"`
+	Provider    string   `json:"provider"`
+	Model       string   `json:"model"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// AIUsageRecord 一次AI调用的用量
+type AIUsageRecord struct {
+	Time     string `json:"time"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// Purpose 调用用途，例如 analyze
+	Purpose      string `json:"purpose"`
+	RepoID       int64  `json:"repo_id,omitempty"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	// Cached 命中缓存没有实际调用AI服务
+	Cached bool `json:"cached,omitempty"`
+}
+
+// ParsedTime 解析记录时间，格式错误时返回零值
+func (r AIUsageRecord) ParsedTime() time.Time {
+	t, _ := time.Parse(time.RFC3339, r.Time)
+	return t
+}
+
+// ReleaseState 仓库的发布跟踪状态和本地保存的发布历史
+type ReleaseState struct {
+	RepoID    int64           `json:"repo_id"`
+	ETag      string          `json:"etag"`
+	CheckedAt string          `json:"checked_at"`
+	Releases  []utils.Release `json:"releases"`
+}
+
+// FeedToken 订阅源访问令牌，只保存令牌的哈希值
+type FeedToken struct {
+	UserName  string `json:"user_name"`
+	TokenHash string `json:"token_hash"`
+	CreatedAt string `json:"created_at"`
+}
+
+// TagSyncState 标签同步的状态
+type TagSyncState struct {
+	// LastSync 上次成功同步的时间
+	LastSync string `json:"last_sync"`
+	// ETag 上次同步后远程标签文件的ETag
+	ETag string `json:"etag"`
+	// Conflicts 同步时两边都修改过的字段，用户确认后清空
+	Conflicts []TagConflict `json:"conflicts"`
+}
+
+// TagConflict 两个实例在上次同步之后都修改过的字段
+type TagConflict struct {
+	RepoID   int64  `json:"repo_id"`
+	FullName string `json:"full_name"`
+	Field    string `json:"field"`
+	Local    string `json:"local"`
+	Remote   string `json:"remote"`
+	// LocalModified、RemoteModified 两边修改的时间
+	LocalModified  string `json:"local_modified"`
+	RemoteModified string `json:"remote_modified"`
+	// RemoteBy 修改远程记录的实例
+	RemoteBy string `json:"remote_by"`
+	// Kept 合并后保留的一方，local 或 remote
+	Kept       string `json:"kept"`
+	DetectedAt string `json:"detected_at"`
+}
+
+// APIToken 用户创建的API令牌，只保存令牌的哈希值
+type APIToken struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	UserName  string `json:"user_name"`
+	AvatarURL string `json:"avatar_url"`
+	TokenHash string `json:"token_hash"`
+	// Scopes 令牌的权限，可选 read、write、ai、sync
+	Scopes    []string `json:"scopes"`
+	CreatedAt string   `json:"created_at"`
+	// ExpiresAt 过期时间，为空时不过期
+	ExpiresAt  string `json:"expires_at,omitempty"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+}
+
+// Expired 判断令牌在 now 时是否已过期
+func (t *APIToken) Expired(now time.Time) bool {
+	if t.ExpiresAt == "" {
+		return false
+	}
+	expiresAt, err := time.Parse(time.RFC3339, t.ExpiresAt)
+	return err != nil || !now.Before(expiresAt)
+}
+
+// HasScope 判断令牌是否拥有指定权限
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// UserRecord 登录过的用户及其活动时间
+type UserRecord struct {
+	Login        string `json:"login"`
+	AvatarURL    string `json:"avatar_url,omitempty"`
+	FirstLoginAt string `json:"first_login_at,omitempty"`
+	LastLoginAt  string `json:"last_login_at,omitempty"`
+	// LastActiveAt 最后一次访问的时间，包括使用API令牌的请求
+	LastActiveAt string `json:"last_active_at,omitempty"`
 }
 
 // Stats 统计信息
@@ -44,4 +389,6 @@ type Stats struct {
 	TotalRepos    int    `json:"total_repos"`
 	AnalyzedRepos int    `json:"analyzed_repos"`
 	LastSync      string `json:"last_sync"`
+	// Health 各健康状态的仓库数量
+	Health map[string]int `json:"health"`
 }
\ No newline at end of file
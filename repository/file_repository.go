@@ -12,12 +12,22 @@ import (
 	"go.uber.org/zap"
 )
 
+// aiUsageRetentionDays AI调用记录的保留天数
+const aiUsageRetentionDays = 400
+
+// tagDeletionRetentionDays 标签删除记录的保留天数，超过该时间没有同步的实例可能会恢复已删除的标签
+const tagDeletionRetentionDays = 365
+
 // FileRepository 基于文件系统的数据存储实现
 type FileRepository struct {
 	dataDir string
 	mu      sync.RWMutex
 	logger  *zap.Logger
 	githubCli *utils.GithubUtil
+
+	// analytics 缓存的分布统计，仓库或标签数据变化时失效
+	analyticsMu sync.Mutex
+	analytics   *Analytics
 }
 
 // NewFileRepository 创建一个新的文件存储实例
@@ -42,37 +52,10 @@ func (f *FileRepository) GetReposWithTag() ([]utils.Repo, error) {
 	defer f.mu.RUnlock()
 
 	f.logger.Debug("从文件系统获取带标签的仓库列表")
-	data, err := os.ReadFile(filepath.Join(f.dataDir, "repos.json"))
-	if err != nil {
-		if os.IsNotExist(err) {
-			f.logger.Debug("仓库数据文件不存在")
-			return nil, err
-		}
-		f.logger.Error("读取仓库数据文件失败", zap.Error(err))
-		return nil, err
-	}
-
-	var repos []utils.Repo
-	err = json.Unmarshal(data, &repos)
+	repos, err := f.loadReposWithTag()
 	if err != nil {
-		f.logger.Error("解析仓库数据失败", zap.Error(err))
 		return nil, err
 	}
-	
-	// 加载标签信息并合并到仓库数据中
-	tags, err := f.loadTags()
-	if err != nil {
-		f.logger.Error("加载标签信息失败", zap.Error(err))
-		return nil, err
-	}
-	
-	// 将标签和分类信息附加到对应的仓库
-	for i := range repos {
-		if tagInfo, exists := tags[repos[i].ID]; exists {
-			repos[i].Tag = tagInfo.Tag
-			repos[i].Category = tagInfo.Category
-		}
-	}
 
 	f.logger.Debug("成功从文件系统获取带标签的仓库列表", zap.Int("count", len(repos)))
 	return repos, nil
@@ -96,6 +79,7 @@ func (f *FileRepository) SaveRepos(repos []utils.Repo) error {
 		f.logger.Error("写入仓库数据文件失败", zap.Error(err))
 		return err
 	}
+	f.invalidateAnalytics()
 
 	f.logger.Debug("成功保存仓库列表到文件系统")
 	return nil
@@ -122,6 +106,15 @@ func (f *FileRepository) GetRepoTag(id int64) (*RepoTag, error) {
 	return &tag, nil
 }
 
+// GetRepoTags 获取所有仓库的标签信息
+func (f *FileRepository) GetRepoTags() (map[int64]RepoTag, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	f.logger.Debug("从文件系统获取所有仓库标签信息")
+	return f.loadTags()
+}
+
 // SaveRepoTag 保存仓库标签信息
 func (f *FileRepository) SaveRepoTag(tag *RepoTag) error {
 	f.mu.Lock()
@@ -133,8 +126,37 @@ func (f *FileRepository) SaveRepoTag(tag *RepoTag) error {
 		return err
 	}
 
+	now := time.Now().Format(time.RFC3339)
+	stampModified(tag, tags[tag.ID], now)
 	tags[tag.ID] = *tag
-	return f.saveTags(tags)
+	if err := f.saveTags(tags); err != nil {
+		return err
+	}
+	return f.clearTagDeletions([]int64{tag.ID})
+}
+
+// SaveRepoTags 批量保存仓库标签信息
+func (f *FileRepository) SaveRepoTags(list []RepoTag) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("批量保存仓库标签信息到文件系统", zap.Int("count", len(list)))
+	tags, err := f.loadTags()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	ids := make([]int64, 0, len(list))
+	for _, tag := range list {
+		stampModified(&tag, tags[tag.ID], now)
+		tags[tag.ID] = tag
+		ids = append(ids, tag.ID)
+	}
+	if err := f.saveTags(tags); err != nil {
+		return err
+	}
+	return f.clearTagDeletions(ids)
 }
 
 // DeleteRepoTag 删除仓库标签信息
@@ -148,34 +170,215 @@ func (f *FileRepository) DeleteRepoTag(id int64) error {
 		return err
 	}
 
+	if _, exists := tags[id]; !exists {
+		return nil
+	}
 	delete(tags, id)
-	return f.saveTags(tags)
+	if err := f.saveTags(tags); err != nil {
+		return err
+	}
+	return f.recordTagDeletions(map[int64]string{id: time.Now().Format(time.RFC3339)})
+}
+
+// GetTagDeletions 获取已删除的仓库标签记录的删除时间
+func (f *FileRepository) GetTagDeletions() (map[int64]string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	deletions := make(map[int64]string)
+	if err := f.readJSON("tag_deletions.json", &deletions); err != nil {
+		return nil, err
+	}
+	return deletions, nil
+}
+
+// MergeRepoTags 写入标签同步合并后的记录
+//
+// 与 SaveRepoTags 不同，记录中的修改时间原样保存，不会被更新为当前时间。
+// 同步期间本地又修改过的字段比合并结果新，保留本地的值；字段锁定状态不参与同步，始终保留本地的。
+func (f *FileRepository) MergeRepoTags(list []RepoTag, deleted map[int64]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("写入同步合并后的仓库标签", zap.Int("updated", len(list)), zap.Int("deleted", len(deleted)))
+	tags, err := f.loadTags()
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int64, 0, len(list))
+	for _, tag := range list {
+		if current, ok := tags[tag.ID]; ok {
+			keepNewerFields(&tag, current)
+		}
+		tags[tag.ID] = tag
+		ids = append(ids, tag.ID)
+	}
+	applied := make(map[int64]string, len(deleted))
+	for id, deletedAt := range deleted {
+		if current, ok := tags[id]; ok && current.modifiedAfter(deletedAt) {
+			continue
+		}
+		delete(tags, id)
+		applied[id] = deletedAt
+	}
+	if err := f.saveTags(tags); err != nil {
+		return err
+	}
+	if err := f.clearTagDeletions(ids); err != nil {
+		return err
+	}
+	return f.recordTagDeletions(applied)
+}
+
+// GetTagSyncState 获取标签同步的状态
+func (f *FileRepository) GetTagSyncState() (*TagSyncState, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	state := &TagSyncState{}
+	if err := f.readJSON("tag_sync.json", state); err != nil {
+		return nil, err
+	}
+	if state.Conflicts == nil {
+		state.Conflicts = []TagConflict{}
+	}
+	return state, nil
+}
+
+// SaveTagSyncState 保存标签同步的状态
+func (f *FileRepository) SaveTagSyncState(state *TagSyncState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.writeJSON("tag_sync.json", state)
+}
+
+// stampModified 把与原记录相比发生变化的字段的修改时间设为 now，未变化的字段沿用原记录的修改时间
+//
+// 调用方已经为字段设置了新的修改时间时保留调用方的时间。
+func stampModified(tag *RepoTag, old RepoTag, now string) {
+	modified := make(map[string]string)
+	for field, t := range old.Modified {
+		modified[field] = t
+	}
+	for _, field := range SuggestionFields {
+		given := tag.Modified[field]
+		if given != "" && given != old.Modified[field] {
+			modified[field] = given
+			continue
+		}
+		if tag.Value(field) != old.Value(field) {
+			modified[field] = now
+		}
+	}
+	if len(modified) == 0 {
+		modified = nil
+	}
+	tag.Modified = modified
+}
+
+// keepNewerFields 对比当前保存的记录，保留修改时间更晚的字段和本地的锁定状态
+func keepNewerFields(tag *RepoTag, current RepoTag) {
+	modified := make(map[string]string, len(tag.Modified))
+	for field, t := range tag.Modified {
+		modified[field] = t
+	}
+	for _, field := range SuggestionFields {
+		if modifiedAt(current.Modified[field]).After(modifiedAt(modified[field])) {
+			tag.SetValue(field, current.Value(field))
+			modified[field] = current.Modified[field]
+		}
+	}
+	tag.Modified = modified
+	tag.Locked = current.Locked
+}
+
+// modifiedAfter 判断记录是否有字段在 t 之后修改过
+func (t *RepoTag) modifiedAfter(at string) bool {
+	limit := modifiedAt(at)
+	for _, m := range t.Modified {
+		if modifiedAt(m).After(limit) {
+			return true
+		}
+	}
+	return false
+}
+
+// modifiedAt 解析修改时间，格式错误或为空时返回零值
+func modifiedAt(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+// clearTagDeletions 删除重新保存的记录的删除时间，调用方需持有写锁
+func (f *FileRepository) clearTagDeletions(ids []int64) error {
+	deletions := make(map[int64]string)
+	if err := f.readJSON("tag_deletions.json", &deletions); err != nil {
+		return err
+	}
+	changed := false
+	for _, id := range ids {
+		if _, ok := deletions[id]; ok {
+			delete(deletions, id)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return f.writeJSON("tag_deletions.json", deletions)
+}
+
+// recordTagDeletions 记录删除时间并清理过期的删除记录，调用方需持有写锁
+func (f *FileRepository) recordTagDeletions(deleted map[int64]string) error {
+	if len(deleted) == 0 {
+		return nil
+	}
+	deletions := make(map[int64]string)
+	if err := f.readJSON("tag_deletions.json", &deletions); err != nil {
+		return err
+	}
+	for id, t := range deleted {
+		deletions[id] = t
+	}
+	cutoff := time.Now().AddDate(0, 0, -tagDeletionRetentionDays)
+	for id, t := range deletions {
+		if deletedAt, err := time.Parse(time.RFC3339, t); err == nil && deletedAt.Before(cutoff) {
+			delete(deletions, id)
+		}
+	}
+	return f.writeJSON("tag_deletions.json", deletions)
 }
 
 // GetStats 获取统计信息
-func (f *FileRepository) GetStats() (*Stats, error) {
+func (f *FileRepository) GetStats(health utils.HealthSettings) (*Stats, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 
 	f.logger.Debug("从文件系统获取统计信息")
-	repos, err := f.GetReposWithTag()
+	repos, err := f.loadReposWithTag()
 	if err != nil {
 		f.logger.Error("获取仓库数据失败", zap.Error(err))
 		return nil, err
 	}
 
-	stats := &Stats{}
+	stats := &Stats{Health: make(map[string]int)}
 	stats.TotalRepos = len(repos)
 
-	// 计算已分析的仓库数量（有标签或分类的仓库）
+	// 计算已分析的仓库数量（有标签或分类的仓库）和各健康状态的数量
+	now := time.Now()
 	for _, repo := range repos {
 		if repo.Tag != "" || repo.Category != "" {
 			stats.AnalyzedRepos++
 		}
+		stats.Health[utils.RepoHealth(repo, health, now)]++
 	}
 	
 	// 获取上次同步时间
-	stats.LastSync, _ = f.LoadSyncTime()
+	if data, err := os.ReadFile(filepath.Join(f.dataDir, "last_sync.txt")); err == nil {
+		stats.LastSync = string(data)
+	}
 
 	f.logger.Debug("成功从文件系统获取统计信息", 
 		zap.Int("total", stats.TotalRepos),
@@ -183,6 +386,30 @@ func (f *FileRepository) GetStats() (*Stats, error) {
 	return stats, nil
 }
 
+// GetAnalytics 获取分布统计，结果缓存到仓库或标签数据下次变化
+func (f *FileRepository) GetAnalytics() (*Analytics, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	// 锁顺序固定为先 mu 后 analyticsMu，写入方持有 mu 时再使缓存失效
+	f.analyticsMu.Lock()
+	defer f.analyticsMu.Unlock()
+
+	if f.analytics == nil {
+		f.logger.Debug("计算分布统计")
+		repos, err := f.loadReposWithTag()
+		if err != nil {
+			f.logger.Error("获取仓库数据失败", zap.Error(err))
+			return nil, err
+		}
+		f.analytics = computeAnalytics(repos)
+	}
+
+	// 返回副本，调用方截取列表不会影响缓存
+	analytics := *f.analytics
+	return &analytics, nil
+}
+
 // SaveSyncTime 保存同步时间
 func (f *FileRepository) SaveSyncTime() error {
 	f.mu.Lock()
@@ -222,6 +449,543 @@ func (f *FileRepository) LoadSyncTime() (string, error) {
 	return string(data), nil
 }
 
+// GetFeedTokens 获取所有用户的订阅源令牌
+func (f *FileRepository) GetFeedTokens() (map[string]FeedToken, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	f.logger.Debug("从文件系统获取订阅源令牌")
+	tokens := make(map[string]FeedToken)
+	if err := f.readJSON("feed_tokens.json", &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// SaveFeedToken 保存用户的订阅源令牌
+func (f *FileRepository) SaveFeedToken(token *FeedToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("保存订阅源令牌到文件系统", zap.String("user", token.UserName))
+	tokens := make(map[string]FeedToken)
+	if err := f.readJSON("feed_tokens.json", &tokens); err != nil {
+		return err
+	}
+	tokens[token.UserName] = *token
+	return f.writeJSON("feed_tokens.json", tokens)
+}
+
+// DeleteFeedToken 删除用户的订阅源令牌
+func (f *FileRepository) DeleteFeedToken(userName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("从文件系统删除订阅源令牌", zap.String("user", userName))
+	tokens := make(map[string]FeedToken)
+	if err := f.readJSON("feed_tokens.json", &tokens); err != nil {
+		return err
+	}
+	delete(tokens, userName)
+	return f.writeJSON("feed_tokens.json", tokens)
+}
+
+// GetAPITokens 获取所有API令牌
+func (f *FileRepository) GetAPITokens() (map[string]APIToken, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	tokens := make(map[string]APIToken)
+	if err := f.readJSON("api_tokens.json", &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// SaveAPIToken 保存API令牌
+func (f *FileRepository) SaveAPIToken(token *APIToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("保存API令牌到文件系统", zap.String("id", token.ID), zap.String("user", token.UserName))
+	tokens := make(map[string]APIToken)
+	if err := f.readJSON("api_tokens.json", &tokens); err != nil {
+		return err
+	}
+	tokens[token.ID] = *token
+	return f.writeJSON("api_tokens.json", tokens)
+}
+
+// DeleteAPIToken 删除API令牌
+func (f *FileRepository) DeleteAPIToken(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("从文件系统删除API令牌", zap.String("id", id))
+	tokens := make(map[string]APIToken)
+	if err := f.readJSON("api_tokens.json", &tokens); err != nil {
+		return err
+	}
+	delete(tokens, id)
+	return f.writeJSON("api_tokens.json", tokens)
+}
+
+// TouchAPIToken 更新API令牌的最后使用时间
+func (f *FileRepository) TouchAPIToken(id, usedAt string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tokens := make(map[string]APIToken)
+	if err := f.readJSON("api_tokens.json", &tokens); err != nil {
+		return err
+	}
+	token, ok := tokens[id]
+	if !ok {
+		return nil
+	}
+	token.LastUsedAt = usedAt
+	tokens[id] = token
+	return f.writeJSON("api_tokens.json", tokens)
+}
+
+// GetUsers 获取所有登录过的用户
+func (f *FileRepository) GetUsers() (map[string]UserRecord, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	users := make(map[string]UserRecord)
+	if err := f.readJSON("users.json", &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// RecordUserLogin 记录用户登录
+func (f *FileRepository) RecordUserLogin(login, avatarURL, loginAt string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("记录用户登录", zap.String("user", login))
+	users := make(map[string]UserRecord)
+	if err := f.readJSON("users.json", &users); err != nil {
+		return err
+	}
+	user, ok := users[login]
+	if !ok {
+		user = UserRecord{Login: login, FirstLoginAt: loginAt}
+	}
+	user.AvatarURL = avatarURL
+	user.LastLoginAt = loginAt
+	user.LastActiveAt = loginAt
+	users[login] = user
+	return f.writeJSON("users.json", users)
+}
+
+// TouchUser 更新用户的最后活动时间
+func (f *FileRepository) TouchUser(login, activeAt string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	users := make(map[string]UserRecord)
+	if err := f.readJSON("users.json", &users); err != nil {
+		return err
+	}
+	user, ok := users[login]
+	if !ok {
+		user = UserRecord{Login: login}
+	}
+	user.LastActiveAt = activeAt
+	users[login] = user
+	return f.writeJSON("users.json", users)
+}
+
+// AppendRepoHistory 追加各仓库本次同步的数据点并压缩历史
+func (f *FileRepository) AppendRepoHistory(points map[int64]HistoryPoint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("追加仓库历史数据到文件系统", zap.Int("count", len(points)))
+	history := make(map[int64][]HistoryPoint)
+	if err := f.readJSON("history.json", &history); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	updated := make(map[int64][]HistoryPoint, len(points))
+	for id, point := range points {
+		updated[id] = compactHistory(append(history[id], point), now)
+	}
+	return f.writeJSON("history.json", updated)
+}
+
+// GetRepoHistory 获取单个仓库的历史数据
+func (f *FileRepository) GetRepoHistory(repoID int64) ([]HistoryPoint, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	f.logger.Debug("从文件系统获取仓库历史数据", zap.Int64("id", repoID))
+	history := make(map[int64][]HistoryPoint)
+	if err := f.readJSON("history.json", &history); err != nil {
+		return nil, err
+	}
+	points := history[repoID]
+	if points == nil {
+		points = []HistoryPoint{}
+	}
+	return points, nil
+}
+
+// GetRepoHistories 获取所有仓库的历史数据
+func (f *FileRepository) GetRepoHistories() (map[int64][]HistoryPoint, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	f.logger.Debug("从文件系统获取所有仓库历史数据")
+	history := make(map[int64][]HistoryPoint)
+	if err := f.readJSON("history.json", &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// GetReleaseStates 获取所有仓库的发布跟踪状态
+func (f *FileRepository) GetReleaseStates() (map[int64]ReleaseState, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	f.logger.Debug("从文件系统获取发布跟踪状态")
+	states := make(map[int64]ReleaseState)
+	if err := f.readJSON("releases.json", &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// SaveReleaseStates 批量保存仓库的发布跟踪状态
+func (f *FileRepository) SaveReleaseStates(list []ReleaseState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("保存发布跟踪状态到文件系统", zap.Int("count", len(list)))
+	states := make(map[int64]ReleaseState)
+	if err := f.readJSON("releases.json", &states); err != nil {
+		return err
+	}
+	for _, state := range list {
+		states[state.RepoID] = state
+	}
+	return f.writeJSON("releases.json", states)
+}
+
+// GetReleaseVisit 获取用户上次查看发布时间线的时间
+func (f *FileRepository) GetReleaseVisit(userName string) (string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	visits := make(map[string]string)
+	if err := f.readJSON("release_visits.json", &visits); err != nil {
+		return "", err
+	}
+	return visits[userName], nil
+}
+
+// SaveReleaseVisit 保存用户查看发布时间线的时间
+func (f *FileRepository) SaveReleaseVisit(userName, visitedAt string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	visits := make(map[string]string)
+	if err := f.readJSON("release_visits.json", &visits); err != nil {
+		return err
+	}
+	visits[userName] = visitedAt
+	return f.writeJSON("release_visits.json", visits)
+}
+
+// GetSuggestions 获取所有等待审核的AI建议
+func (f *FileRepository) GetSuggestions() (map[int64]Suggestion, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	f.logger.Debug("从文件系统获取AI建议")
+	suggestions := make(map[int64]Suggestion)
+	if err := f.readJSON("suggestions.json", &suggestions); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// SaveSuggestion 保存AI建议
+func (f *FileRepository) SaveSuggestion(suggestion *Suggestion) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("保存AI建议到文件系统", zap.Int64("repo_id", suggestion.RepoID))
+	suggestions := make(map[int64]Suggestion)
+	if err := f.readJSON("suggestions.json", &suggestions); err != nil {
+		return err
+	}
+	suggestions[suggestion.RepoID] = *suggestion
+	return f.writeJSON("suggestions.json", suggestions)
+}
+
+// DeleteSuggestions 删除指定仓库的AI建议
+func (f *FileRepository) DeleteSuggestions(repoIDs []int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("从文件系统删除AI建议", zap.Int("count", len(repoIDs)))
+	suggestions := make(map[int64]Suggestion)
+	if err := f.readJSON("suggestions.json", &suggestions); err != nil {
+		return err
+	}
+	for _, id := range repoIDs {
+		delete(suggestions, id)
+	}
+	return f.writeJSON("suggestions.json", suggestions)
+}
+
+// GetCachedAnalysis 按输入内容的哈希获取缓存的AI分析结果
+func (f *FileRepository) GetCachedAnalysis(hash string) (*CachedAnalysis, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	cache := make(map[string]CachedAnalysis)
+	if err := f.readJSON("ai_cache.json", &cache); err != nil {
+		return nil, err
+	}
+	entry, ok := cache[hash]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// SaveCachedAnalysis 缓存AI分析结果，同时删除该仓库之前的缓存
+func (f *FileRepository) SaveCachedAnalysis(entry *CachedAnalysis) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("缓存AI分析结果", zap.Int64("repo_id", entry.RepoID), zap.String("hash", entry.Hash))
+	cache := make(map[string]CachedAnalysis)
+	if err := f.readJSON("ai_cache.json", &cache); err != nil {
+		return err
+	}
+	for hash, cached := range cache {
+		if cached.RepoID == entry.RepoID {
+			delete(cache, hash)
+		}
+	}
+	cache[entry.Hash] = *entry
+	return f.writeJSON("ai_cache.json", cache)
+}
+
+// AppendAIUsage 记录一次AI调用的用量
+func (f *FileRepository) AppendAIUsage(record *AIUsageRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var records []AIUsageRecord
+	if err := f.readJSON("ai_usage.json", &records); err != nil {
+		return err
+	}
+
+	// 删除超过保留期限的记录
+	cutoff := time.Now().AddDate(0, 0, -aiUsageRetentionDays)
+	kept := records[:0]
+	for _, r := range records {
+		if r.ParsedTime().After(cutoff) {
+			kept = append(kept, r)
+		}
+	}
+	kept = append(kept, *record)
+	return f.writeJSON("ai_usage.json", kept)
+}
+
+// GetAIUsage 获取指定时间之后的AI调用记录
+func (f *FileRepository) GetAIUsage(since time.Time) ([]AIUsageRecord, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var records []AIUsageRecord
+	if err := f.readJSON("ai_usage.json", &records); err != nil {
+		return nil, err
+	}
+	result := make([]AIUsageRecord, 0, len(records))
+	for _, r := range records {
+		if !r.ParsedTime().Before(since) {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+// GetReadmes 获取所有缓存的README
+func (f *FileRepository) GetReadmes() (map[int64]CachedReadme, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	readmes := make(map[int64]CachedReadme)
+	if err := f.readJSON("readmes.json", &readmes); err != nil {
+		return nil, err
+	}
+	return readmes, nil
+}
+
+// SaveReadme 缓存仓库的README
+func (f *FileRepository) SaveReadme(readme *CachedReadme) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Debug("缓存仓库README", zap.Int64("repo_id", readme.RepoID), zap.Int("size", len(readme.Content)))
+	readmes := make(map[int64]CachedReadme)
+	if err := f.readJSON("readmes.json", &readmes); err != nil {
+		return err
+	}
+	readmes[readme.RepoID] = *readme
+	return f.writeJSON("readmes.json", readmes)
+}
+
+// SnapshotFiles 读取所有需要备份的数据文件
+func (f *FileRepository) SnapshotFiles() (map[string][]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	files := make(map[string][]byte)
+	for _, name := range BackupFiles {
+		data, err := os.ReadFile(filepath.Join(f.dataDir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			f.logger.Error("读取数据文件失败", zap.String("file", name), zap.Error(err))
+			return nil, err
+		}
+		files[name] = data
+	}
+	return files, nil
+}
+
+// RestoreFiles 用备份中的数据文件替换当前数据
+//
+// 先把所有文件写入临时文件再逐个替换，避免写入失败时只恢复了一部分。
+func (f *FileRepository) RestoreFiles(files map[string][]byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logger.Info("从备份恢复数据文件", zap.Int("files", len(files)))
+	var written []string
+	defer func() {
+		for _, name := range written {
+			os.Remove(filepath.Join(f.dataDir, name+".restore"))
+		}
+	}()
+	for _, name := range BackupFiles {
+		data, ok := files[name]
+		if !ok {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(f.dataDir, name+".restore"), data, 0644); err != nil {
+			f.logger.Error("写入数据文件失败", zap.String("file", name), zap.Error(err))
+			return err
+		}
+		written = append(written, name)
+	}
+
+	for _, name := range BackupFiles {
+		target := filepath.Join(f.dataDir, name)
+		if _, ok := files[name]; !ok {
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				f.logger.Error("删除数据文件失败", zap.String("file", name), zap.Error(err))
+				return err
+			}
+			continue
+		}
+		if err := os.Rename(target+".restore", target); err != nil {
+			f.logger.Error("替换数据文件失败", zap.String("file", name), zap.Error(err))
+			return err
+		}
+	}
+	f.invalidateAnalytics()
+	return nil
+}
+
+// readJSON 读取数据目录下的JSON文件，文件不存在时保持v不变
+func (f *FileRepository) readJSON(name string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(f.dataDir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		f.logger.Error("读取数据文件失败", zap.String("file", name), zap.Error(err))
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		f.logger.Error("解析数据文件失败", zap.String("file", name), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// writeJSON 将数据写入数据目录下的JSON文件
+func (f *FileRepository) writeJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		f.logger.Error("序列化数据失败", zap.String("file", name), zap.Error(err))
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(f.dataDir, name), data, 0644); err != nil {
+		f.logger.Error("写入数据文件失败", zap.String("file", name), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// invalidateAnalytics 使分布统计缓存失效，调用方需持有写锁
+func (f *FileRepository) invalidateAnalytics() {
+	f.analyticsMu.Lock()
+	f.analytics = nil
+	f.analyticsMu.Unlock()
+}
+
+// loadReposWithTag 读取仓库列表并合并标签信息，调用方需持有锁
+func (f *FileRepository) loadReposWithTag() ([]utils.Repo, error) {
+	data, err := os.ReadFile(filepath.Join(f.dataDir, "repos.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			f.logger.Debug("仓库数据文件不存在")
+			return nil, err
+		}
+		f.logger.Error("读取仓库数据文件失败", zap.Error(err))
+		return nil, err
+	}
+
+	var repos []utils.Repo
+	err = json.Unmarshal(data, &repos)
+	if err != nil {
+		f.logger.Error("解析仓库数据失败", zap.Error(err))
+		return nil, err
+	}
+	
+	// 加载标签信息并合并到仓库数据中
+	tags, err := f.loadTags()
+	if err != nil {
+		f.logger.Error("加载标签信息失败", zap.Error(err))
+		return nil, err
+	}
+	
+	// 将标签和分类信息附加到对应的仓库
+	for i := range repos {
+		if tagInfo, exists := tags[repos[i].ID]; exists {
+			repos[i].Tag = tagInfo.Tag
+			repos[i].Category = tagInfo.Category
+		}
+	}
+	return repos, nil
+}
+
 // loadTags 加载所有标签信息
 func (f *FileRepository) loadTags() (map[int64]RepoTag, error) {
 	filename := filepath.Join(f.dataDir, "repo_tags.json")
@@ -265,6 +1029,7 @@ func (f *FileRepository) saveTags(tags map[int64]RepoTag) error {
 		f.logger.Error("写入标签数据文件失败", zap.Error(err))
 		return err
 	}
+	f.invalidateAnalytics()
 
 	return nil
 }
\ No newline at end of file
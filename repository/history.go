@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// historyDailyDays 最近多少天内的历史按天保留，更早的按周保留
+	historyDailyDays = 90
+)
+
+// HistoryPoint 仓库在某次同步时的star数、fork数和未关闭issue数
+type HistoryPoint struct {
+	Time       string `json:"time"`
+	Stars      int    `json:"stars"`
+	Forks      int    `json:"forks"`
+	OpenIssues int    `json:"open_issues"`
+}
+
+// ParsedTime 解析记录时间，格式错误时返回零值
+func (p HistoryPoint) ParsedTime() time.Time {
+	t, _ := time.Parse(time.RFC3339, p.Time)
+	return t
+}
+
+// compactHistory 压缩历史：最近 historyDailyDays 天内每天保留最后一个点，更早的每周保留最后一个点
+func compactHistory(points []HistoryPoint, now time.Time) []HistoryPoint {
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].ParsedTime().Before(points[j].ParsedTime())
+	})
+
+	dailySince := now.AddDate(0, 0, -historyDailyDays)
+	compacted := make([]HistoryPoint, 0, len(points))
+	lastBucket := ""
+	for _, point := range points {
+		t := point.ParsedTime().UTC()
+		if t.IsZero() {
+			continue
+		}
+
+		var bucket string
+		if t.Before(dailySince) {
+			year, week := t.ISOWeek()
+			bucket = fmt.Sprintf("%d-W%02d", year, week)
+		} else {
+			bucket = t.Format("2006-01-02")
+		}
+
+		// 同一时间段内后面的点覆盖前面的点
+		if bucket == lastBucket {
+			compacted[len(compacted)-1] = point
+			continue
+		}
+		compacted = append(compacted, point)
+		lastBucket = bucket
+	}
+	return compacted
+}
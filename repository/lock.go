@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// dataLockFile data 目录下用于进程间互斥的锁文件
+const dataLockFile = ".lock"
+
+// ErrDataLocked data 目录已被其他进程锁定
+var ErrDataLocked = errors.New("data 目录正在被其他进程使用")
+
+// LockDataDir 锁定 data 目录，服务器和本地模式的命令行同一时间只能有一个读写数据文件
+//
+// 已被其他进程锁定时返回 ErrDataLocked。锁在返回的函数被调用或进程退出时释放，进程异常退出不会留下失效的锁。
+func LockDataDir() (func(), error) {
+	if err := os.MkdirAll("data", 0755); err != nil {
+		return nil, err
+	}
+	f, err := lockFile(filepath.Join("data", dataLockFile))
+	if err != nil {
+		return nil, err
+	}
+	return func() { f.Close() }, nil
+}
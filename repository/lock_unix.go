@@ -0,0 +1,25 @@
+//go:build !windows
+
+package repository
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile 打开锁文件并加排他的 flock，文件关闭时释放
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrDataLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
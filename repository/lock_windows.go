@@ -0,0 +1,29 @@
+//go:build windows
+
+package repository
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errSharingViolation 文件已被其他进程以不共享的方式打开
+const errSharingViolation syscall.Errno = 32
+
+// lockFile 以不共享的方式打开锁文件，其他进程在文件关闭前无法再次打开
+func lockFile(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil,
+		syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		if errors.Is(err, errSharingViolation) {
+			return nil, ErrDataLocked
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(handle), path), nil
+}
@@ -10,43 +10,64 @@ import (
 
 // Server 封装gin引擎和配置
 type Server struct {
-	Engine *gin.Engine
-	Config *config.Config
+	Engine   *gin.Engine
+	Config   *config.Config
+	Releases *controllers.ReleaseHandler
+	Backups  *controllers.BackupHandler
+	TagSync  *controllers.TagSyncHandler
 }
 
 // NewServer 创建一个新的服务器实例
-func NewServer(engine *gin.Engine, config *config.Config) *Server {
+func NewServer(engine *gin.Engine, config *config.Config, releases *controllers.ReleaseHandler, backups *controllers.BackupHandler, tagSync *controllers.TagSyncHandler) *Server {
 	return &Server{
-		Engine: engine,
-		Config: config,
+		Engine:   engine,
+		Config:   config,
+		Releases: releases,
+		Backups:  backups,
+		TagSync:  tagSync,
 	}
 }
 
-// Run 启动服务器
+// Run 启动后台任务和服务器
 func (s *Server) Run() error {
+	go s.Releases.RunWatcher()
+	go s.Backups.RunScheduler()
+	go s.TagSync.RunScheduler()
 	return s.Engine.Run(s.Config.ServerPort)
 }
 
-func SetupRouter(sh *controllers.StarHandler, ah *controllers.AuthHandler, seth *controllers.SettingsHandler) *gin.Engine {
+func SetupRouter(sh *controllers.StarHandler, ah *controllers.AuthHandler, seth *controllers.SettingsHandler, eh *controllers.ExportHandler, fh *controllers.FeedHandler, rh *controllers.ReleaseHandler, aih *controllers.AIHandler, bh *controllers.BackupHandler, tsh *controllers.TagSyncHandler, adh *controllers.AdminHandler, cfg *config.Config) *gin.Engine {
 	r := gin.Default()
 	
-	// 添加CORS中间件
-	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true
-	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
-	r.Use(cors.New(config))
+	// 只允许配置的来源跨域访问，未配置时不启用CORS。跨域请求不携带cookie，需要使用API令牌
+	if origins := cfg.OriginList(); len(origins) > 0 {
+		corsConfig := cors.DefaultConfig()
+		corsConfig.AllowOrigins = origins
+		corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+		corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
+		r.Use(cors.New(corsConfig))
+	}
 	
 	r.LoadHTMLGlob("templates/*")
 	r.Static("/static", "./static")
 
 	// 登录相关路由
 	r.GET("/login", ah.LoginPage)
-	r.GET("/logout", ah.Logout)
+	r.POST("/logout", ah.Logout)
 	r.POST("/auth/token-login", ah.TokenLogin)
 	r.GET("/auth/github", ah.GitHubLogin)
 	r.GET("/auth/github/callback", ah.GitHubCallback)
 
+	// 订阅源使用令牌认证，订阅器无法携带会话cookie
+	feeds := r.Group("/feeds")
+	feeds.Use(fh.FeedTokenMiddleware())
+	{
+		feeds.GET("/starred.atom", fh.StarredAtom)
+		feeds.GET("/starred.rss", fh.StarredRSS)
+		feeds.GET("/releases.atom", fh.ReleasesAtom)
+		feeds.GET("/releases.rss", fh.ReleasesRSS)
+	}
+
 	// 需要认证的路由组
 	auth := r.Group("/")
 	auth.Use(ah.AuthMiddleware())
@@ -58,18 +79,62 @@ func SetupRouter(sh *controllers.StarHandler, ah *controllers.AuthHandler, seth
 		{
 			api.GET("/user", sh.GetUser)
 			api.GET("/repos", sh.GetRepos)
+			api.GET("/repos/trending", sh.GetTrending)
+			api.GET("/repos/:id/history", sh.GetRepoHistory)
 			api.GET("/stats", sh.GetStats)
+			api.GET("/stats/analytics", sh.GetAnalytics)
 			api.GET("/categories", sh.GetCategories)
-			api.GET("/sync-progress", sh.SyncProgressWS)
-			api.POST("/sync", sh.SyncStars)
-			api.POST("/repos/:id/tag", sh.UpdateTag)
-			api.POST("/repos/:id/category", sh.UpdateCategory)
-			api.POST("/repos/:id/description", sh.UpdateDescription)
-			api.POST("/repos/:id/analyze", sh.AnalyzeRepo)
-			api.POST("/test-openai", seth.TestOpenAI)
-			api.POST("/test-webdav", seth.TestWebDAV)
-			api.GET("/settings", seth.GetSettings)
-			api.POST("/settings", seth.SaveSettings)
+			api.POST("/repos/:id/prompt-preview", sh.PreviewPrompt)
+			api.GET("/suggestions", sh.GetSuggestions)
+			api.GET("/releases", rh.GetReleases)
+			api.POST("/releases/check", rh.CheckReleases)
+			api.GET("/ai/usage", aih.GetUsage)
+			api.POST("/ask", aih.Ask)
+			api.GET("/search", aih.Search)
+			api.GET("/export", eh.ExportData)
+			api.GET("/export/markdown", eh.ExportMarkdown)
+			api.GET("/export/bookmarks", eh.ExportBookmarks)
+			api.GET("/feed-token", fh.GetFeedToken)
+			api.POST("/feed-token", fh.CreateFeedToken)
+			api.DELETE("/feed-token", fh.DeleteFeedToken)
+			api.GET("/tokens", ah.ListAPITokens)
+			api.POST("/tokens", ah.CreateAPIToken)
+			api.DELETE("/tokens/:id", ah.DeleteAPIToken)
+		}
+
+		// 修改全局设置、修改或替换共享数据和管理用户只允许管理员操作
+		admin := api.Group("")
+		admin.Use(ah.AdminOnly())
+		{
+			admin.POST("/repos/:id/tag", sh.UpdateTag)
+			admin.POST("/tags/rename", sh.RenameTag)
+			admin.POST("/repos/:id/category", sh.UpdateCategory)
+			admin.POST("/repos/:id/description", sh.UpdateDescription)
+			admin.POST("/repos/:id/analyze", sh.AnalyzeRepo)
+			admin.POST("/repos/:id/unlock", sh.UnlockRepoFields)
+			admin.POST("/suggestions/accept", sh.AcceptSuggestions)
+			admin.POST("/suggestions/reject", sh.RejectSuggestions)
+			admin.POST("/suggestions/:id/accept", sh.AcceptSuggestion)
+			admin.POST("/suggestions/:id/reject", sh.RejectSuggestion)
+			admin.POST("/repos/:id/watch-releases", rh.WatchRepo)
+			admin.GET("/sync-progress", sh.SyncProgressWS)
+			admin.POST("/sync", sh.SyncStars)
+			admin.POST("/test-openai", seth.TestOpenAI)
+			admin.POST("/test-webdav", seth.TestWebDAV)
+			admin.POST("/test-s3", seth.TestS3)
+			admin.GET("/backups", bh.ListBackups)
+			admin.POST("/backups", bh.CreateBackup)
+			admin.POST("/backups/:name/restore", bh.RestoreBackup)
+			admin.GET("/tag-sync", tsh.GetStatus)
+			admin.POST("/tag-sync", tsh.SyncTags)
+			admin.DELETE("/tag-sync/conflicts", tsh.ClearConflicts)
+			admin.GET("/settings", seth.GetSettings)
+			admin.POST("/settings", seth.SaveSettings)
+			admin.POST("/import", eh.ImportData)
+			admin.POST("/import/bookmarks", eh.ImportBookmarks)
+			admin.GET("/admin/users", adh.ListUsers)
+			admin.DELETE("/admin/users/:login/sessions", adh.RevokeSessions)
+			admin.DELETE("/admin/users/:login/tokens", adh.RevokeAPITokens)
 		}
 
 	}
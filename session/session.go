@@ -1,11 +1,14 @@
 package session
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"encoding/base64"
 	"net/http"
 	"sync"
 	"time"
 
+	"github-stars-manager/utils"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,26 +16,60 @@ type SessionData struct {
     AccessToken string
     UserName    string
     AvatarURL   string
+    // Scopes access token实际获得的GitHub权限
+    Scopes      utils.GrantedScopes
+    // TokenExpiresAt access token的过期时间，OAuth App和个人令牌不过期，为零值
+    TokenExpiresAt time.Time
+    // RefreshToken 使用GitHub App登录时的刷新令牌，每次刷新后都会更换
+    RefreshToken string
+    // RefreshExpiresAt 刷新令牌的过期时间
+    RefreshExpiresAt time.Time
+    // InstallationID 用户账号上GitHub App的安装ID，未安装或未使用GitHub App时为0
+    InstallationID int64
+    // Role 用户角色，登录时根据配置确定
+    Role string
+    // Local 本地模式命令行的会话，不是真实的用户，不记录活动也不出现在用户列表中
+    Local bool
 }
 
-var store = map[string]*SessionData{}
-var mu sync.Mutex
+// NeedsRefresh 判断access token是否将在 margin 内过期并且可以刷新
+func (s *SessionData) NeedsRefresh(now time.Time, margin time.Duration) bool {
+    return s.RefreshToken != "" && !s.TokenExpiresAt.IsZero() && now.Add(margin).After(s.TokenExpiresAt)
+}
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
+// ApplyToken 保存GitHub返回的access token以及过期时间、刷新令牌
+func (s *SessionData) ApplyToken(token *utils.OAuthToken) {
+    s.AccessToken = token.AccessToken
+    s.TokenExpiresAt = token.ExpiresAt
+    s.RefreshToken = token.RefreshToken
+    s.RefreshExpiresAt = token.RefreshExpiresAt
 }
 
+// Usable 判断会话的access token当前可用，或者过期后仍可以刷新
+func (s *SessionData) Usable(now time.Time) bool {
+    if s.AccessToken == "" {
+        return false
+    }
+    if s.TokenExpiresAt.IsZero() || now.Before(s.TokenExpiresAt) {
+        return true
+    }
+    return s.RefreshToken != "" && (s.RefreshExpiresAt.IsZero() || now.Before(s.RefreshExpiresAt))
+}
+
+var store = map[string]*SessionData{}
+var mu sync.Mutex
+
 func NewSessionData() *SessionData {
 	return &SessionData{}
 }
 
+// generateSessionID 使用加密安全的随机数生成会话ID，会话ID同时用于派生CSRF令牌，不能被猜测
 func generateSessionID() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	b := make([]byte, 32)
-	for i := range b {
-		b[i] = charset[rand.Intn(len(charset))]
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
 	}
-	return string(b)
+	return base64.RawURLEncoding.EncodeToString(b)
 }
 
 func SetSession(c *gin.Context, data *SessionData) {
@@ -48,9 +85,20 @@ func SetSession(c *gin.Context, data *SessionData) {
 		Path:     "/",
 		HttpOnly: true,
 		MaxAge:   3600 * 24, // 24小时
+		// 其他站点发起的POST等跨站请求不携带会话cookie
+		SameSite: http.SameSiteLaxMode,
 	})
 }
 
+// GetSessionID 返回请求cookie中的会话ID，没有时返回空字符串
+func GetSessionID(c *gin.Context) string {
+    cookie, err := c.Request.Cookie("session_id")
+    if err != nil {
+        return ""
+    }
+    return cookie.Value
+}
+
 func GetSession(c *gin.Context) (*SessionData, error) {
 	// 从cookie中获取session_id
 	cookie, err := c.Request.Cookie("session_id")
@@ -111,4 +159,55 @@ func Delete(sessionID string) {
     mu.Lock()
     defer mu.Unlock()
     delete(store, sessionID)
-}
\ No newline at end of file
+}
+
+// DeleteUser 删除指定用户的所有会话，返回删除的数量
+func DeleteUser(userName string) int {
+    mu.Lock()
+    defer mu.Unlock()
+    count := 0
+    for id, data := range store {
+        if data.UserName == userName {
+            delete(store, id)
+            count++
+        }
+    }
+    return count
+}
+
+// CountByUser 返回每个用户已登录的会话数量
+func CountByUser() map[string]int {
+    mu.Lock()
+    defer mu.Unlock()
+    counts := make(map[string]int)
+    for _, data := range store {
+        if !data.Local {
+            counts[data.UserName]++
+        }
+    }
+    return counts
+}
+
+// ForUser 返回指定用户任意一个可用的已登录会话的ID和副本，没有时返回nil
+func ForUser(userName string) (string, *SessionData) {
+    return find(func(data *SessionData) bool { return data.UserName == userName })
+}
+
+// AnyWithRole 返回指定角色的任意一个可用的已登录会话的ID和副本，供后台任务使用
+func AnyWithRole(role string) (string, *SessionData) {
+    return find(func(data *SessionData) bool { return data.Role == role })
+}
+
+// find 返回第一个满足条件且access token可用的用户会话，不包括本地模式的会话
+func find(match func(*SessionData) bool) (string, *SessionData) {
+    mu.Lock()
+    defer mu.Unlock()
+    now := time.Now()
+    for id, data := range store {
+        if !data.Local && data.Usable(now) && match(data) {
+            copied := *data
+            return id, &copied
+        }
+    }
+    return "", nil
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultAnthropicEndpoint Anthropic API默认地址
+	defaultAnthropicEndpoint = "https://api.anthropic.com"
+	// defaultAnthropicVersion 默认的 anthropic-version 请求头
+	defaultAnthropicVersion = "2023-06-01"
+	// defaultAnthropicMaxTokens 默认的最大输出token数
+	defaultAnthropicMaxTokens = 1024
+)
+
+// anthropicRequest Messages API请求结构
+type anthropicRequest struct {
+	Model      string            `json:"model"`
+	MaxTokens  int               `json:"max_tokens"`
+	System     string            `json:"system,omitempty"`
+	Messages   []Message         `json:"messages"`
+	Tools      []anthropicTool   `json:"tools,omitempty"`
+	ToolChoice map[string]string `json:"tool_choice,omitempty"`
+}
+
+// anthropicTool 工具定义，结构化输出通过强制调用工具实现
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// anthropicResponse Messages API响应结构
+type anthropicResponse struct {
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// anthropicProvider Anthropic Messages API适配器
+type anthropicProvider struct {
+	logger   *zap.Logger
+	settings AnthropicSettings
+	timeout  time.Duration
+}
+
+func (p *anthropicProvider) Name() string {
+	return ProviderAnthropic
+}
+
+func (p *anthropicProvider) Model() string {
+	return p.settings.Model
+}
+
+// Complete 调用Messages API，暂不使用流式接口，设置了 OnDelta 时在收到完整回复后调用一次
+func (p *anthropicProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	version := p.settings.Version
+	if version == "" {
+		version = defaultAnthropicVersion
+	}
+	maxTokens := p.settings.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultAnthropicMaxTokens
+	}
+
+	body := anthropicRequest{
+		Model:     p.settings.Model,
+		MaxTokens: maxTokens,
+		System:    req.System,
+		Messages:  req.messages(),
+	}
+	if req.Schema != nil {
+		body.Tools = []anthropicTool{{
+			Name:        req.Schema.Name,
+			Description: req.Schema.Description,
+			InputSchema: req.Schema.Schema,
+		}}
+		body.ToolChoice = map[string]string{"type": "tool", "name": req.Schema.Name}
+	}
+	headers := map[string]string{
+		"x-api-key":         p.settings.Key,
+		"anthropic-version": version,
+	}
+
+	var resp anthropicResponse
+	url := joinEndpoint(p.settings.Endpoint, defaultAnthropicEndpoint, "/v1/messages")
+	if err := postLLMJSON(ctx, p.logger, "Anthropic", url, headers, p.settings.Headers, body, &resp); err != nil {
+		return nil, llmError(ctx, p.timeout, err)
+	}
+	if resp.Error.Message != "" {
+		return nil, fmt.Errorf("Anthropic API返回错误: %s", resp.Error.Message)
+	}
+
+	content, err := anthropicContent(resp, req.Schema != nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.OnDelta != nil {
+		req.OnDelta(content)
+	}
+	return &CompletionResponse{
+		Content: content,
+		Usage:   TokenUsage{InputTokens: resp.Usage.InputTokens, OutputTokens: resp.Usage.OutputTokens},
+	}, nil
+}
+
+// anthropicContent 提取回复内容，structured 为true时优先使用工具参数
+func anthropicContent(resp anthropicResponse, structured bool) (string, error) {
+	var text strings.Builder
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			// 强制调用工具时，工具参数就是结构化的回复
+			if structured && len(block.Input) > 0 {
+				return string(block.Input), nil
+			}
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("Anthropic未返回有效结果")
+	}
+	return text.String(), nil
+}
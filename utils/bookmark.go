@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Bookmark 书签文件中的一个链接
+type Bookmark struct {
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Folder      string    `json:"folder"`
+	Tags        []string  `json:"tags,omitempty"`
+	Description string    `json:"description,omitempty"`
+	AddDate     time.Time `json:"add_date,omitempty"`
+}
+
+// BookmarkFolder 导出时的书签文件夹
+type BookmarkFolder struct {
+	Name      string
+	Bookmarks []Bookmark
+}
+
+// githubReservedOwners github.com 上不属于仓库的一级路径
+var githubReservedOwners = map[string]bool{
+	"about": true, "apps": true, "collections": true, "customer-stories": true, "enterprise": true,
+	"explore": true, "features": true, "issues": true, "login": true, "marketplace": true,
+	"new": true, "notifications": true, "orgs": true, "pricing": true, "pulls": true,
+	"search": true, "security": true, "settings": true, "site": true, "sponsors": true,
+	"team": true, "topics": true, "trending": true, "users": true,
+}
+
+// GitHubRepoFromURL 从链接中解析 owner/repo，非 github.com 仓库链接返回 false
+func GitHubRepoFromURL(link string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host != "github.com" && host != "www.github.com" {
+		return "", false
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	owner := parts[0]
+	name := strings.TrimSuffix(parts[1], ".git")
+	if githubReservedOwners[strings.ToLower(owner)] || name == "" {
+		return "", false
+	}
+	return owner + "/" + name, true
+}
+
+// ParseBookmarks 解析 Netscape Bookmark File 格式的书签，Folder 为链接所在的最内层文件夹
+func ParseBookmarks(r io.Reader) ([]Bookmark, error) {
+	z := xhtml.NewTokenizer(r)
+
+	var bookmarks []Bookmark
+	var folders []string
+	var pendingFolder string
+	var current *Bookmark
+	inFolderTitle := false
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case xhtml.ErrorToken:
+			if z.Err() == io.EOF {
+				return bookmarks, nil
+			}
+			return nil, fmt.Errorf("解析书签文件失败: %w", z.Err())
+
+		case xhtml.StartTagToken:
+			token := z.Token()
+			switch token.DataAtom {
+			case atom.H3:
+				inFolderTitle = true
+				pendingFolder = ""
+			case atom.Dl:
+				folders = append(folders, pendingFolder)
+				pendingFolder = ""
+			case atom.A:
+				current = &Bookmark{}
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "href":
+						current.URL = attr.Val
+					case "tags":
+						for _, tag := range strings.Split(attr.Val, ",") {
+							if tag = strings.TrimSpace(tag); tag != "" {
+								current.Tags = append(current.Tags, tag)
+							}
+						}
+					case "add_date":
+						if sec, err := strconv.ParseInt(attr.Val, 10, 64); err == nil && sec > 0 {
+							current.AddDate = time.Unix(sec, 0)
+						}
+					}
+				}
+				for i := len(folders) - 1; i >= 0; i-- {
+					if folders[i] != "" {
+						current.Folder = folders[i]
+						break
+					}
+				}
+			}
+
+		case xhtml.TextToken:
+			text := string(z.Text())
+			if inFolderTitle {
+				pendingFolder += text
+			} else if current != nil {
+				current.Title += text
+			}
+
+		case xhtml.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.H3:
+				inFolderTitle = false
+				pendingFolder = strings.TrimSpace(pendingFolder)
+			case atom.Dl:
+				if len(folders) > 0 {
+					folders = folders[:len(folders)-1]
+				}
+			case atom.A:
+				if current != nil {
+					current.Title = strings.TrimSpace(current.Title)
+					bookmarks = append(bookmarks, *current)
+					current = nil
+				}
+			}
+		}
+	}
+}
+
+// WriteBookmarks 以 Netscape Bookmark File 格式写出书签，所有文件夹放在 root 之下
+func WriteBookmarks(w io.Writer, root string, folders []BookmarkFolder) error {
+	var b strings.Builder
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	b.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	b.WriteString("<!-- This is an automatically generated file.\n     It will be read and overwritten.\n     DO NOT EDIT! -->\n")
+	b.WriteString(`<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">` + "\n")
+	b.WriteString("<TITLE>Bookmarks</TITLE>\n<H1>Bookmarks</H1>\n<DL><p>\n")
+	fmt.Fprintf(&b, "    <DT><H3 ADD_DATE=\"%s\">%s</H3>\n    <DL><p>\n", now, html.EscapeString(root))
+
+	for _, folder := range folders {
+		fmt.Fprintf(&b, "        <DT><H3 ADD_DATE=\"%s\">%s</H3>\n        <DL><p>\n", now, html.EscapeString(folder.Name))
+		for _, bm := range folder.Bookmarks {
+			b.WriteString(`            <DT><A HREF="` + html.EscapeString(bm.URL) + `"`)
+			if !bm.AddDate.IsZero() {
+				b.WriteString(` ADD_DATE="` + strconv.FormatInt(bm.AddDate.Unix(), 10) + `"`)
+			}
+			if len(bm.Tags) > 0 {
+				b.WriteString(` TAGS="` + html.EscapeString(strings.Join(bm.Tags, ",")) + `"`)
+			}
+			b.WriteString(">" + html.EscapeString(bm.Title) + "</A>\n")
+			if bm.Description != "" {
+				b.WriteString("            <DD>" + html.EscapeString(bm.Description) + "\n")
+			}
+		}
+		b.WriteString("        </DL><p>\n")
+	}
+
+	b.WriteString("    </DL><p>\n</DL><p>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
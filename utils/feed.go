@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Feed 订阅源，可输出为Atom或RSS
+type Feed struct {
+	Title       string
+	Link        string
+	SelfLink    string
+	Description string
+	Updated     time.Time
+	Items       []FeedItem
+}
+
+// FeedItem 订阅源中的条目
+type FeedItem struct {
+	ID         string
+	Title      string
+	Link       string
+	Summary    string
+	Categories []string
+	Published  time.Time
+	Updated    time.Time
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published,omitempty"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	Description string   `xml:"description,omitempty"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// WriteAtom 以Atom 1.0格式写出订阅源
+func WriteAtom(w io.Writer, feed *Feed) error {
+	out := atomFeed{
+		ID:       feed.Link,
+		Title:    feed.Title,
+		Subtitle: feed.Description,
+		Updated:  feed.Updated.UTC().Format(time.RFC3339),
+		Links:    []atomLink{{Href: feed.Link, Rel: "alternate"}},
+	}
+	if feed.SelfLink != "" {
+		out.Links = append(out.Links, atomLink{Href: feed.SelfLink, Rel: "self", Type: "application/atom+xml"})
+	}
+
+	for _, item := range feed.Items {
+		entry := atomEntry{
+			ID:      item.ID,
+			Title:   item.Title,
+			Link:    atomLink{Href: item.Link, Rel: "alternate"},
+			Updated: item.Updated.UTC().Format(time.RFC3339),
+			Summary: item.Summary,
+		}
+		if !item.Published.IsZero() {
+			entry.Published = item.Published.UTC().Format(time.RFC3339)
+		}
+		for _, category := range item.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category})
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+
+	return writeXML(w, out)
+}
+
+// WriteRSS 以RSS 2.0格式写出订阅源
+func WriteRSS(w io.Writer, feed *Feed) error {
+	out := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         feed.Title,
+			Link:          feed.Link,
+			Description:   feed.Description,
+			LastBuildDate: feed.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, item := range feed.Items {
+		rss := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{Value: item.ID, IsPermaLink: item.ID == item.Link},
+			Description: item.Summary,
+			Categories:  item.Categories,
+		}
+		if !item.Published.IsZero() {
+			rss.PubDate = item.Published.UTC().Format(time.RFC1123Z)
+		}
+		out.Channel.Items = append(out.Channel.Items, rss)
+	}
+
+	return writeXML(w, out)
+}
+
+// writeXML 写出带XML声明的文档
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(v)
+}
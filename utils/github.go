@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
 	"go.uber.org/zap"
@@ -22,6 +24,8 @@ func NewGithubCli(logger *zap.Logger) *GithubUtil {
 type User struct {
 	Login     string `json:"login"`
 	AvatarURL string `json:"avatar_url"`
+	// Scopes token实际获得的权限
+	Scopes GrantedScopes `json:"-"`
 }
 
 type Repo struct {
@@ -36,37 +40,165 @@ type Repo struct {
 	Tag             string   `json:"tag"`
 	Category        string   `json:"category"`
 	ReadmeURL       string   `json:"readme_url"`
+	StarredAt       string   `json:"starred_at,omitempty"`
+	Archived        bool     `json:"archived"`
+	Disabled        bool     `json:"disabled"`
+	Fork            bool     `json:"fork"`
+	PushedAt        string   `json:"pushed_at,omitempty"`
+	OpenIssuesCount int      `json:"open_issues_count"`
+	ForksCount      int      `json:"forks_count"`
+	License         *License `json:"license,omitempty"`
+
+	// LanguageBytes 各语言的代码字节数，来自 /languages 接口
+	LanguageBytes map[string]int64 `json:"language_bytes,omitempty"`
+	// MovedFrom 仓库被重命名或转移时记录原来的全名
+	MovedFrom string `json:"moved_from,omitempty"`
+	// Health 根据以上字段计算的健康状态，只在返回给前端时填充
+	Health string `json:"health,omitempty"`
+}
+
+// License 仓库的开源许可证
+type License struct {
+	Key    string `json:"key"`
+	Name   string `json:"name"`
+	SPDXID string `json:"spdx_id"`
+}
+
+// Release 仓库的一次发布
+type Release struct {
+	TagName     string `json:"tag_name"`
+	Name        string `json:"name"`
+	HTMLURL     string `json:"html_url"`
+	Body        string `json:"body,omitempty"`
+	Prerelease  bool   `json:"prerelease"`
+	PublishedAt string `json:"published_at"`
+}
+
+// PublishedTime 解析发布时间，未知时返回零值
+func (r Release) PublishedTime() time.Time {
+	t, _ := time.Parse(time.RFC3339, r.PublishedAt)
+	return t
+}
+
+// StarredRepo 使用 star+json 媒体类型时 /user/starred 返回的条目
+type StarredRepo struct {
+	StarredAt string `json:"starred_at"`
+	Repo      Repo   `json:"repo"`
+}
+
+// StarredTime 解析star时间，未知时返回零值
+func (r Repo) StarredTime() time.Time {
+	t, _ := time.Parse(time.RFC3339, r.StarredAt)
+	return t
+}
+
+// FullName 从仓库地址解析出 owner/repo 形式的全名
+func (r Repo) FullName() string {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimSuffix(r.HTMLURL, "/"), ".git"), "/")
+	if len(parts) < 2 {
+		return r.Name
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// OAuthError GitHub OAuth 接口返回的错误，例如授权码无效或已过期时为 bad_verification_code
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// OAuthToken GitHub OAuth 接口返回的令牌
+//
+// GitHub App 的用户令牌会过期，同时返回刷新令牌；OAuth App 的令牌不过期，过期时间为零值。
+type OAuthToken struct {
+	AccessToken string
+	// ExpiresAt access token的过期时间
+	ExpiresAt    time.Time
+	RefreshToken string
+	// RefreshExpiresAt 刷新令牌的过期时间
+	RefreshExpiresAt time.Time
 }
 
-// GetAccessToken 获取GitHub access token
-func (utl *GithubUtil)GetAccessToken(clientID, clientSecret, code string) (string, error) {
+// ExchangeCode 使用授权码获取GitHub access token，codeVerifier 为PKCE的验证码，不使用PKCE时为空
+func (utl *GithubUtil) ExchangeCode(clientID, clientSecret, code, redirectURI, codeVerifier string) (*OAuthToken, error) {
 	utl.logger.Debug("获取GitHub access token")
-	url := "https://github.com/login/oauth/access_token"
-	payload := fmt.Sprintf("client_id=%s&client_secret=%s&code=%s", clientID, clientSecret, code)
+	form := url.Values{
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"code":          {code},
+	}
+	if redirectURI != "" {
+		form.Set("redirect_uri", redirectURI)
+	}
+	if codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
+	}
+	return utl.requestToken(form)
+}
 
+// RefreshAccessToken 使用刷新令牌获取新的用户令牌，GitHub会同时更换刷新令牌，旧的刷新令牌随即失效
+func (utl *GithubUtil) RefreshAccessToken(clientID, clientSecret, refreshToken string) (*OAuthToken, error) {
+	utl.logger.Debug("刷新GitHub access token")
+	return utl.requestToken(url.Values{
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+}
+
+// requestToken 请求 /login/oauth/access_token 接口
+func (utl *GithubUtil) requestToken(form url.Values) (*OAuthToken, error) {
 	client := &http.Client{Timeout: 30 * time.Second}
-	req, _ := http.NewRequest("POST", url, strings.NewReader(payload))
+	req, _ := http.NewRequest("POST", "https://github.com/login/oauth/access_token", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 
 	resp, err := client.Do(req)
 	if err != nil {
 		utl.logger.Error("获取access token请求失败", zap.Error(err))
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	// 授权码无效等错误也会返回200，错误信息在 error 字段中
 	var result struct {
-		AccessToken string `json:"access_token"`
+		AccessToken           string `json:"access_token"`
+		ExpiresIn             int64  `json:"expires_in"`
+		RefreshToken          string `json:"refresh_token"`
+		RefreshTokenExpiresIn int64  `json:"refresh_token_expires_in"`
+		Error                 string `json:"error"`
+		ErrorDescription      string `json:"error_description"`
 	}
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
-		utl.logger.Error("解析access token响应失败", zap.Error(err))
-		return "", err
+		utl.logger.Error("解析access token响应失败", zap.Int("status", resp.StatusCode), zap.Error(err))
+		return nil, fmt.Errorf("解析access token响应失败（%d）: %w", resp.StatusCode, err)
+	}
+	if result.Error != "" {
+		return nil, &OAuthError{Code: result.Error, Description: result.ErrorDescription}
+	}
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return nil, fmt.Errorf("获取access token失败，状态码 %d", resp.StatusCode)
 	}
 
-	utl.logger.Debug("获取GitHub access token成功")
-	return result.AccessToken, nil
+	now := time.Now()
+	token := &OAuthToken{AccessToken: result.AccessToken, RefreshToken: result.RefreshToken}
+	if result.ExpiresIn > 0 {
+		token.ExpiresAt = now.Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
+	if result.RefreshTokenExpiresIn > 0 {
+		token.RefreshExpiresAt = now.Add(time.Duration(result.RefreshTokenExpiresIn) * time.Second)
+	}
+	utl.logger.Debug("获取GitHub access token成功", zap.Bool("expiring", !token.ExpiresAt.IsZero()))
+	return token, nil
 }
 
 // GetUserInfo 获取用户信息
@@ -84,17 +216,56 @@ func (utl *GithubUtil) GetUserInfo(token string) (*User, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		utl.logger.Error("获取用户信息失败", zap.Int("status", resp.StatusCode))
+		return nil, fmt.Errorf("获取用户信息失败，状态码: %d", resp.StatusCode)
+	}
+
 	var user User
 	err = json.NewDecoder(resp.Body).Decode(&user)
 	if err != nil {
 		utl.logger.Error("解析用户信息响应失败", zap.Error(err))
 		return nil, err
 	}
+	user.Scopes = ParseGrantedScopes(resp.Header)
 
 	utl.logger.Debug("获取GitHub用户信息成功", zap.String("user", user.Login))
 	return &user, nil
 }
 
+// IsOrgMember 判断token对应的用户是否为组织的正式成员，需要 read:org 权限
+//
+// 非成员和待接受邀请的成员返回false；权限不足或组织限制了第三方应用时返回错误。
+func (utl *GithubUtil) IsOrgMember(token, org string) (bool, error) {
+	utl.logger.Debug("检查组织成员身份", zap.String("org", org))
+	client := &http.Client{Timeout: 30 * time.Second}
+	req, _ := http.NewRequest("GET", "https://api.github.com/user/memberships/orgs/"+url.PathEscape(org), nil)
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		utl.logger.Error("检查组织成员身份请求失败", zap.Error(err), zap.String("org", org))
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		utl.logger.Error("检查组织成员身份失败", zap.Int("status", resp.StatusCode), zap.String("org", org))
+		return false, fmt.Errorf("检查组织 %s 的成员身份失败，状态码: %d", org, resp.StatusCode)
+	}
+	var membership struct {
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&membership); err != nil {
+		return false, err
+	}
+	return membership.State == "active", nil
+}
+
 // GetStarredRepos 获取用户star的仓库列表
 func  (utl *GithubUtil)GetStarredRepos(token string) ([]Repo, error) {
 	utl.logger.Debug("获取用户star的仓库列表")
@@ -107,7 +278,8 @@ func  (utl *GithubUtil)GetStarredRepos(token string) ([]Repo, error) {
 		url := fmt.Sprintf("https://api.github.com/user/starred?page=%d&per_page=100", page)
 		req, _ := http.NewRequest("GET", url, nil)
 		req.Header.Set("Authorization", "token "+token)
-		req.Header.Set("Accept", "application/vnd.github.v3+json")
+		// 使用star+json以获取star时间
+		req.Header.Set("Accept", "application/vnd.github.star+json")
 
 		resp, err := client.Do(req)
 		if err != nil {
@@ -117,8 +289,8 @@ func  (utl *GithubUtil)GetStarredRepos(token string) ([]Repo, error) {
 			return nil, err
 		}
 
-		var repos []Repo
-		err = json.NewDecoder(resp.Body).Decode(&repos)
+		var starred []StarredRepo
+		err = json.NewDecoder(resp.Body).Decode(&starred)
 		resp.Body.Close()
 		if err != nil {
 			utl.logger.Error("解析star仓库列表失败", 
@@ -128,10 +300,13 @@ func  (utl *GithubUtil)GetStarredRepos(token string) ([]Repo, error) {
 		}
 
 		// 添加到总列表中
-		allRepos = append(allRepos, repos...)
+		for _, item := range starred {
+			item.Repo.StarredAt = item.StarredAt
+			allRepos = append(allRepos, item.Repo)
+		}
 
 		// 如果当前页面的仓库数量少于100，说明已经是最后一页
-		if len(repos) < 100 {
+		if len(starred) < 100 {
 			break
 		}
 
@@ -142,6 +317,75 @@ func  (utl *GithubUtil)GetStarredRepos(token string) ([]Repo, error) {
 	return allRepos, nil
 }
 
+// StarRepo 为当前用户star指定仓库
+func (utl *GithubUtil) StarRepo(token, repoFullName string) error {
+	utl.logger.Debug("star仓库", zap.String("repo", repoFullName))
+	client := &http.Client{Timeout: 30 * time.Second}
+	url := fmt.Sprintf("https://api.github.com/user/starred/%s", repoFullName)
+	req, _ := http.NewRequest("PUT", url, nil)
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.ContentLength = 0
+
+	resp, err := client.Do(req)
+	if err != nil {
+		utl.logger.Error("star仓库请求失败", zap.Error(err), zap.String("repo", repoFullName))
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		utl.logger.Error("star仓库失败", zap.Int("status", resp.StatusCode), zap.String("repo", repoFullName))
+		return fmt.Errorf("star仓库失败，状态码: %d", resp.StatusCode)
+	}
+
+	utl.logger.Debug("star仓库成功", zap.String("repo", repoFullName))
+	return nil
+}
+
+// GetLatestRelease 使用条件请求获取仓库的最新发布
+//
+// etag 为上次请求返回的ETag，未变化时 notModified 为 true；仓库没有发布时返回 nil。
+func (utl *GithubUtil) GetLatestRelease(token, repoFullName, etag string) (release *Release, newETag string, notModified bool, err error) {
+	utl.logger.Debug("获取仓库最新发布", zap.String("repo", repoFullName))
+	client := &http.Client{Timeout: 30 * time.Second}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", repoFullName)
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		utl.logger.Error("获取最新发布请求失败", zap.Error(err), zap.String("repo", repoFullName))
+		return nil, "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, etag, true, nil
+	case http.StatusNotFound:
+		// 仓库没有发布，同样记录ETag以便下次使用条件请求
+		return nil, resp.Header.Get("ETag"), false, nil
+	case http.StatusOK:
+	default:
+		utl.logger.Warn("获取最新发布失败", zap.Int("status", resp.StatusCode), zap.String("repo", repoFullName))
+		return nil, "", false, fmt.Errorf("获取最新发布失败，状态码: %d", resp.StatusCode)
+	}
+
+	var result Release
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		utl.logger.Error("解析最新发布失败", zap.Error(err), zap.String("repo", repoFullName))
+		return nil, "", false, err
+	}
+
+	utl.logger.Debug("获取仓库最新发布成功", zap.String("repo", repoFullName), zap.String("tag", result.TagName))
+	return &result, resp.Header.Get("ETag"), false, nil
+}
+
 // GetRepoDetails 获取仓库详细信息
 func  (utl *GithubUtil)GetRepoDetails(token, repoFullName string) (*Repo, error) {
 	utl.logger.Debug("获取仓库详细信息", zap.String("repo", repoFullName))
@@ -183,7 +427,7 @@ func  (utl *GithubUtil)GetRepoDetails(token, repoFullName string) (*Repo, error)
 			zap.Error(err), 
 			zap.String("repo", repoFullName))
 	} else {
-		var languages map[string]int
+		var languages map[string]int64
 		err = json.NewDecoder(langResp.Body).Decode(&languages)
 		langResp.Body.Close()
 		if err != nil {
@@ -191,10 +435,14 @@ func  (utl *GithubUtil)GetRepoDetails(token, repoFullName string) (*Repo, error)
 				zap.Error(err), 
 				zap.String("repo", repoFullName))
 		} else {
-			// 将语言map转换为语言列表
+			// 将语言map转换为语言列表，按代码量从多到少排列
 			for lang := range languages {
 				repo.Languages = append(repo.Languages, lang)
 			}
+			sort.Slice(repo.Languages, func(i, j int) bool {
+				return languages[repo.Languages[i]] > languages[repo.Languages[j]]
+			})
+			repo.LanguageBytes = languages
 		}
 	}
 	
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// GitHubApp GitHub App的基本信息
+type GitHubApp struct {
+	Slug    string `json:"slug"`
+	Name    string `json:"name"`
+	HTMLURL string `json:"html_url"`
+}
+
+// InstallURL 返回安装App的页面地址
+func (a *GitHubApp) InstallURL() string {
+	return "https://github.com/apps/" + url.PathEscape(a.Slug) + "/installations/new"
+}
+
+// AppInstallation GitHub App在某个账号上的安装
+type AppInstallation struct {
+	ID      int64 `json:"id"`
+	Account struct {
+		Login string `json:"login"`
+	} `json:"account"`
+	// RepositorySelection 安装时选择的仓库范围：all 或 selected
+	RepositorySelection string `json:"repository_selection"`
+	HTMLURL             string `json:"html_url"`
+}
+
+// ParseAppPrivateKey 解析GitHub App的PEM格式私钥，支持PKCS#1和PKCS#8
+func ParseAppPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("私钥不是PEM格式")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("私钥不是RSA私钥")
+	}
+	return key, nil
+}
+
+// AppJWT 生成以App身份调用接口的JWT，有效期9分钟
+//
+// 签发时间提前60秒，避免与GitHub服务器的时钟偏差导致JWT被拒绝。
+func AppJWT(appID string, key *rsa.PrivateKey, now time.Time) (string, error) {
+	var issuer interface{} = appID
+	if id, err := strconv.ParseInt(appID, 10, 64); err == nil {
+		issuer = id
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-60 * time.Second).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": issuer,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hashed := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// GetApp 使用App的JWT获取App信息
+func (utl *GithubUtil) GetApp(jwt string) (*GitHubApp, error) {
+	utl.logger.Debug("获取GitHub App信息")
+	resp, err := utl.appRequest(jwt, "https://api.github.com/app")
+	if err != nil {
+		utl.logger.Error("获取GitHub App信息请求失败", zap.Error(err))
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		utl.logger.Error("获取GitHub App信息失败", zap.Int("status", resp.StatusCode))
+		return nil, fmt.Errorf("获取GitHub App信息失败，状态码: %d", resp.StatusCode)
+	}
+	var app GitHubApp
+	if err := json.NewDecoder(resp.Body).Decode(&app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// GetUserInstallation 使用App的JWT查询App在指定用户账号上的安装，未安装时返回 nil
+func (utl *GithubUtil) GetUserInstallation(jwt, login string) (*AppInstallation, error) {
+	utl.logger.Debug("查询GitHub App安装", zap.String("user", login))
+	resp, err := utl.appRequest(jwt, "https://api.github.com/users/"+url.PathEscape(login)+"/installation")
+	if err != nil {
+		utl.logger.Error("查询GitHub App安装请求失败", zap.Error(err), zap.String("user", login))
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		utl.logger.Error("查询GitHub App安装失败", zap.Int("status", resp.StatusCode), zap.String("user", login))
+		return nil, fmt.Errorf("查询GitHub App安装失败，状态码: %d", resp.StatusCode)
+	}
+	var installation AppInstallation
+	if err := json.NewDecoder(resp.Body).Decode(&installation); err != nil {
+		return nil, err
+	}
+	return &installation, nil
+}
+
+// appRequest 以App身份发送GET请求
+func (utl *GithubUtil) appRequest(jwt, target string) (*http.Response, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	req, _ := http.NewRequest("GET", target, nil)
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	return client.Do(req)
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"strings"
+	"time"
+)
+
+// 仓库健康状态
+const (
+	HealthActive   = "active"   // 近期仍有提交
+	HealthSlowing  = "slowing"  // 提交变少，超过 SlowingMonths 没有推送
+	HealthStale    = "stale"    // 超过 StaleMonths 没有推送
+	HealthArchived = "archived" // 已归档或被禁用
+	HealthMoved    = "moved"    // 已重命名或转移
+	HealthUnknown  = "unknown"  // 缺少推送时间，需要重新同步
+)
+
+const (
+	// DefaultSlowingMonths 默认超过6个月没有推送视为放缓
+	DefaultSlowingMonths = 6
+	// DefaultStaleMonths 默认超过12个月没有推送视为停滞
+	DefaultStaleMonths = 12
+)
+
+// HealthStatuses 所有健康状态，按严重程度排列
+var HealthStatuses = []string{HealthArchived, HealthMoved, HealthStale, HealthSlowing, HealthActive, HealthUnknown}
+
+// PushedTime 解析最近推送时间，未知时返回零值
+func (r Repo) PushedTime() time.Time {
+	t, _ := time.Parse(time.RFC3339, r.PushedAt)
+	return t
+}
+
+// RepoHealth 计算仓库的健康状态
+func RepoHealth(repo Repo, settings HealthSettings, now time.Time) string {
+	settings = settings.WithDefaults()
+	switch {
+	case repo.Archived || repo.Disabled:
+		return HealthArchived
+	case repo.MovedFrom != "":
+		return HealthMoved
+	}
+
+	pushed := repo.PushedTime()
+	switch {
+	case pushed.IsZero():
+		return HealthUnknown
+	case pushed.Before(now.AddDate(0, -settings.StaleMonths, 0)):
+		return HealthStale
+	case pushed.Before(now.AddDate(0, -settings.SlowingMonths, 0)):
+		return HealthSlowing
+	default:
+		return HealthActive
+	}
+}
+
+// ParseHealthFilter 解析逗号分隔的健康状态筛选条件，包含未知状态时返回false
+func ParseHealthFilter(value string) (map[string]bool, bool) {
+	filter := make(map[string]bool)
+	for _, status := range strings.Split(value, ",") {
+		status = strings.TrimSpace(status)
+		if status == "" {
+			continue
+		}
+		known := false
+		for _, s := range HealthStatuses {
+			if s == status {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, false
+		}
+		filter[status] = true
+	}
+	return filter, true
+}
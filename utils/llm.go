@@ -0,0 +1,242 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AI服务类型
+const (
+	ProviderOpenAI    = "openai"    // OpenAI兼容接口
+	ProviderAnthropic = "anthropic" // Anthropic Messages API
+	ProviderOllama    = "ollama"    // Ollama 原生接口
+)
+
+// CompletionRequest 发送给AI服务的请求
+type CompletionRequest struct {
+	// System 系统提示，为空时不发送
+	System string
+	// History 位于 Prompt 之前的对话，用于让AI修正上一次的回复
+	History []Message
+	// Prompt 用户提示
+	Prompt string
+	// Schema 要求以符合该JSON Schema的对象回复，为空时不限制格式
+	Schema *JSONSchema
+	// OnDelta 不为空时使用流式接口，每收到一段回复调用一次；不支持流式输出的服务在收到完整回复后调用一次
+	OnDelta func(delta string)
+}
+
+// JSONSchema 结构化输出使用的JSON Schema
+//
+// OpenAI通过 response_format 传递，Anthropic通过强制调用同名工具传递，Ollama通过 format 传递。
+type JSONSchema struct {
+	Name        string
+	Description string
+	Schema      map[string]interface{}
+}
+
+// messages 返回按顺序排列的对话消息，不包括系统提示
+func (r CompletionRequest) messages() []Message {
+	messages := make([]Message, 0, len(r.History)+1)
+	messages = append(messages, r.History...)
+	return append(messages, Message{Role: "user", Content: r.Prompt})
+}
+
+// CompletionResponse AI服务的回复
+type CompletionResponse struct {
+	Content string
+	// Usage 本次调用消耗的token数，服务未返回时为0
+	Usage TokenUsage
+}
+
+// TokenUsage 一次调用消耗的token数
+type TokenUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// LLMProvider AI服务适配器，屏蔽各家接口格式的差异
+type LLMProvider interface {
+	// Name 返回服务类型
+	Name() string
+	// Model 返回使用的模型
+	Model() string
+	// Complete 发送一次对话请求并返回回复内容，ctx 取消或超时时中止请求
+	Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error)
+}
+
+// LLMUtil 根据设置创建AI服务适配器
+type LLMUtil struct {
+	logger    *zap.Logger
+	openaiCli *OpenAIUtil
+}
+
+// NewLLMUtil 创建AI服务工具实例
+func NewLLMUtil(logger *zap.Logger, openaiCli *OpenAIUtil) *LLMUtil {
+	return &LLMUtil{
+		logger:    logger,
+		openaiCli: openaiCli,
+	}
+}
+
+// Provider 返回设置中选择的AI服务适配器，配置不完整时返回错误
+func (u *LLMUtil) Provider(settings *Settings) (LLMProvider, error) {
+	switch settings.AI.Provider {
+	case "", ProviderOpenAI:
+		if settings.OpenAI.Endpoint == "" || settings.OpenAI.Model == "" {
+			return nil, fmt.Errorf("OpenAI配置不完整，请填写API地址和模型")
+		}
+		return &openAIProvider{cli: u.openaiCli, settings: settings.OpenAI, timeout: settings.AI.RequestTimeout()}, nil
+	case ProviderAnthropic:
+		if settings.Anthropic.Key == "" || settings.Anthropic.Model == "" {
+			return nil, fmt.Errorf("Anthropic配置不完整，请填写API Key和模型")
+		}
+		return &anthropicProvider{logger: u.logger, settings: settings.Anthropic, timeout: settings.AI.RequestTimeout()}, nil
+	case ProviderOllama:
+		if settings.Ollama.Model == "" {
+			return nil, fmt.Errorf("Ollama配置不完整，请填写模型")
+		}
+		return &ollamaProvider{logger: u.logger, settings: settings.Ollama, timeout: settings.AI.RequestTimeout()}, nil
+	default:
+		return nil, fmt.Errorf("不支持的AI服务: %s", settings.AI.Provider)
+	}
+}
+
+// TestConnection 使用设置中选择的AI服务发送一次简单请求
+func (u *LLMUtil) TestConnection(ctx context.Context, settings *Settings) error {
+	provider, err := u.Provider(settings)
+	if err != nil {
+		return err
+	}
+	_, err = provider.Complete(ctx, CompletionRequest{Prompt: "你好，请简单介绍一下你自己。"})
+	return err
+}
+
+// openAIProvider OpenAI兼容接口适配器
+type openAIProvider struct {
+	cli      *OpenAIUtil
+	settings OpenAISettings
+	timeout  time.Duration
+}
+
+func (p *openAIProvider) Name() string {
+	return ProviderOpenAI
+}
+
+func (p *openAIProvider) Model() string {
+	return p.settings.Model
+}
+
+func (p *openAIProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	messages := req.messages()
+	if req.System != "" {
+		messages = append([]Message{{Role: "system", Content: req.System}}, messages...)
+	}
+	var responseFormat interface{}
+	if req.Schema != nil {
+		responseFormat = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":        req.Schema.Name,
+				"description": req.Schema.Description,
+				"schema":      req.Schema.Schema,
+				"strict":      true,
+			},
+		}
+	}
+	var content string
+	var usage TokenUsage
+	var err error
+	if req.OnDelta != nil {
+		content, usage, err = p.cli.ChatStream(ctx, p.settings, messages, responseFormat, req.OnDelta)
+	} else {
+		content, usage, err = p.cli.ChatMessages(ctx, p.settings, messages, responseFormat)
+	}
+	if err != nil {
+		return nil, llmError(ctx, p.timeout, err)
+	}
+	return &CompletionResponse{Content: content, Usage: usage}, nil
+}
+
+// llmError 在请求因超时中止时返回更明确的错误，保留原始错误以便调用方判断是否被取消
+func llmError(ctx context.Context, timeout time.Duration, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("AI服务在%s内没有完成响应: %w", timeout, err)
+	}
+	return err
+}
+
+// postLLMJSON 发送JSON请求并解析JSON响应，非200状态码时返回包含响应内容的错误
+func postLLMJSON(ctx context.Context, logger *zap.Logger, name, url string, headers map[string]string, custom []KeyValue, body, out interface{}) error {
+	resp, err := sendLLMRequest(ctx, logger, name, url, headers, custom, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析%s响应失败: %w", name, err)
+	}
+	return nil
+}
+
+// sendLLMRequest 发送JSON请求，返回状态码为200的响应，调用方负责关闭响应体
+func sendLLMRequest(ctx context.Context, logger *zap.Logger, name, url string, headers map[string]string, custom []KeyValue, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	// 自定义请求头优先
+	for _, item := range custom {
+		req.Header.Set(item.Key, item.Value)
+	}
+
+	logger.Debug("调用 "+name+" API", zap.String("url", url), zap.String("body", string(data)))
+
+	// 超时由 ctx 控制，流式响应可能持续较长时间
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+
+	logger.Debug(name+" API响应状态", zap.Int("status", resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s API返回错误状态码 %d: %s", name, resp.StatusCode, string(respBody))
+	}
+	return resp, nil
+}
+
+// joinEndpoint 拼接接口地址，地址已包含路径时不重复添加
+func joinEndpoint(endpoint, defaultEndpoint, path string) string {
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	endpoint = strings.TrimRight(endpoint, "/")
+	if strings.HasSuffix(endpoint, path) {
+		return endpoint
+	}
+	return endpoint + path
+}
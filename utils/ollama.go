@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultOllamaEndpoint Ollama默认地址
+const defaultOllamaEndpoint = "http://localhost:11434"
+
+// ollamaRequest Ollama /api/chat 请求结构
+type ollamaRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+	// Format 为JSON Schema时要求按该格式输出
+	Format interface{} `json:"format,omitempty"`
+}
+
+// ollamaResponse Ollama /api/chat 响应结构
+type ollamaResponse struct {
+	Message Message `json:"message"`
+	Error   string  `json:"error"`
+	// Done 流式输出时最后一段为true
+	Done bool `json:"done"`
+	// PromptEvalCount、EvalCount 输入和输出的token数
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+// ollamaProvider Ollama原生接口适配器
+type ollamaProvider struct {
+	logger   *zap.Logger
+	settings OllamaSettings
+	timeout  time.Duration
+}
+
+func (p *ollamaProvider) Name() string {
+	return ProviderOllama
+}
+
+func (p *ollamaProvider) Model() string {
+	return p.settings.Model
+}
+
+func (p *ollamaProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	messages := req.messages()
+	if req.System != "" {
+		messages = append([]Message{{Role: "system", Content: req.System}}, messages...)
+	}
+	body := ollamaRequest{
+		Model:    p.settings.Model,
+		Messages: messages,
+		Stream:   req.OnDelta != nil,
+	}
+	if req.Schema != nil {
+		body.Format = req.Schema.Schema
+	}
+
+	url := joinEndpoint(p.settings.Endpoint, defaultOllamaEndpoint, "/api/chat")
+	if req.OnDelta != nil {
+		resp, err := p.stream(ctx, url, body, req.OnDelta)
+		if err != nil {
+			return nil, llmError(ctx, p.timeout, err)
+		}
+		return resp, nil
+	}
+
+	var resp ollamaResponse
+	if err := postLLMJSON(ctx, p.logger, "Ollama", url, nil, p.settings.Headers, body, &resp); err != nil {
+		return nil, llmError(ctx, p.timeout, err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("Ollama API返回错误: %s", resp.Error)
+	}
+	if resp.Message.Content == "" {
+		return nil, fmt.Errorf("Ollama未返回有效结果")
+	}
+	return &CompletionResponse{
+		Content: resp.Message.Content,
+		Usage:   TokenUsage{InputTokens: resp.PromptEvalCount, OutputTokens: resp.EvalCount},
+	}, nil
+}
+
+// stream 以流式方式调用 /api/chat，响应每行是一个JSON对象，最后一行包含token数
+func (p *ollamaProvider) stream(ctx context.Context, url string, body ollamaRequest, onDelta func(string)) (*CompletionResponse, error) {
+	resp, err := sendLLMRequest(ctx, p.logger, "Ollama", url, nil, p.settings.Headers, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var usage TokenUsage
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("读取Ollama流式响应失败: %w", err)
+		}
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var chunk ollamaResponse
+			if jsonErr := json.Unmarshal(line, &chunk); jsonErr != nil {
+				return nil, fmt.Errorf("解析Ollama流式响应失败: %w", jsonErr)
+			}
+			if chunk.Error != "" {
+				return nil, fmt.Errorf("Ollama API返回错误: %s", chunk.Error)
+			}
+			if chunk.Message.Content != "" {
+				content.WriteString(chunk.Message.Content)
+				onDelta(chunk.Message.Content)
+			}
+			if chunk.Done {
+				usage = TokenUsage{InputTokens: chunk.PromptEvalCount, OutputTokens: chunk.EvalCount}
+				break
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("Ollama未返回有效结果")
+	}
+	return &CompletionResponse{Content: content.String(), Usage: usage}, nil
+}
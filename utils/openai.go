@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -19,8 +21,23 @@ type Message struct {
 
 // ChatRequest OpenAI聊天请求结构
 type ChatRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model          string      `json:"model"`
+	Messages       []Message   `json:"messages"`
+	ResponseFormat interface{} `json:"response_format,omitempty"`
+	Stream         bool        `json:"stream,omitempty"`
+	// StreamOptions 流式输出时要求在最后一段返回token用量
+	StreamOptions *ChatStreamOptions `json:"stream_options,omitempty"`
+}
+
+// ChatStreamOptions 流式输出选项
+type ChatStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatUsage OpenAI返回的token用量
+type ChatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
 }
 
 // ChatResponse OpenAI聊天响应结构
@@ -31,6 +48,19 @@ type ChatResponse struct {
 	Error struct {
 		Message string `json:"message"`
 	} `json:"error"`
+	Usage ChatUsage `json:"usage"`
+}
+
+// ChatStreamChunk 流式响应中的一段
+type ChatStreamChunk struct {
+	Choices []struct {
+		Delta Message `json:"delta"`
+	} `json:"choices"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	// Usage 只在最后一段返回
+	Usage *ChatUsage `json:"usage"`
 }
 
 type OpenAIUtil struct {
@@ -72,14 +102,45 @@ func (c *OpenAIUtil) mergeNestedField(data map[string]interface{}, key string, v
 	}
 }
 
-func (o *OpenAIUtil) CallWithPrompt(settings OpenAISettings, prompt string) (string, error) {
-	// 1. 序列化基础 body
-	baseBody := ChatRequest{
-		Model: settings.Model,
-		Messages: []Message{
-			{Role: "user", Content: prompt},
-		},
+// ChatMessages 使用完整的对话调用 Chat API，responseFormat 不为空时作为 response_format 发送，同时返回token用量
+func (o *OpenAIUtil) ChatMessages(ctx context.Context, settings OpenAISettings, messages []Message, responseFormat interface{}) (string, TokenUsage, error) {
+	var usage TokenUsage
+	resp, err := o.sendChat(ctx, settings, ChatRequest{
+		Model:          settings.Model,
+		Messages:       messages,
+		ResponseFormat: responseFormat,
+	})
+	if err != nil {
+		return "", usage, err
 	}
+	defer resp.Body.Close()
+	return o.readChatResponse(resp.Body, nil)
+}
+
+// ChatStream 以流式方式调用 Chat API，每收到一段回复调用一次 onDelta，返回完整的回复和token用量
+func (o *OpenAIUtil) ChatStream(ctx context.Context, settings OpenAISettings, messages []Message, responseFormat interface{}, onDelta func(string)) (string, TokenUsage, error) {
+	resp, err := o.sendChat(ctx, settings, ChatRequest{
+		Model:          settings.Model,
+		Messages:       messages,
+		ResponseFormat: responseFormat,
+		Stream:         true,
+		StreamOptions:  &ChatStreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return "", TokenUsage{}, err
+	}
+	defer resp.Body.Close()
+
+	// 部分兼容接口忽略 stream 参数，直接返回完整的回复
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return o.readChatResponse(resp.Body, onDelta)
+	}
+	return o.readChatStream(resp.Body, onDelta)
+}
+
+// sendChat 发送 Chat API 请求，返回状态码为200的响应，调用方负责关闭响应体
+func (o *OpenAIUtil) sendChat(ctx context.Context, settings OpenAISettings, baseBody ChatRequest) (*http.Response, error) {
+	// 1. 序列化基础 body
 	url := settings.Endpoint
 	if !strings.HasSuffix(url, "/") {
 		url += "/"
@@ -89,7 +150,7 @@ func (o *OpenAIUtil) CallWithPrompt(settings OpenAISettings, prompt string) (str
 	tmpBytes, _ := json.Marshal(baseBody)
 	json.Unmarshal(tmpBytes, &bodyMap)
 
-	// 2. 合并自定义 body 字段
+	// 2. 合并自定义 body 字段，可以覆盖 response_format
 	for _, item := range settings.Body {
 		o.mergeNestedField(bodyMap, item.Key, item.Value)
 	}
@@ -97,65 +158,124 @@ func (o *OpenAIUtil) CallWithPrompt(settings OpenAISettings, prompt string) (str
 	// 3. 序列化最终 body
 	finalBody, err := json.Marshal(bodyMap)
 	if err != nil {
-		return "", fmt.Errorf("序列化失败: %w", err)
+		return nil, fmt.Errorf("序列化失败: %w", err)
 	}
 
-	// 4. 构建请求
-	req, err := http.NewRequest("POST", url, bytes.NewReader(finalBody))
+	// 4. 构建请求，ctx 取消时中止请求
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(finalBody))
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
-	// 5. 设置 headers
+	// 5. 设置 headers，自定义请求头可以覆盖默认的 Authorization
 	req.Header.Set("Content-Type", "application/json")
+	if settings.Key != "" {
+		req.Header.Set("Authorization", "Bearer "+settings.Key)
+	}
 	for _, item := range settings.Headers {
 		req.Header.Set(item.Key, item.Value)
 	}
 
-	// 6. 打印日志
+	// 6. 打印日志，不输出认证信息
 	if o.logger != nil {
+		headers := req.Header.Clone()
+		if headers.Get("Authorization") != "" {
+			headers.Set("Authorization", "***")
+		}
 		o.logger.Debug("调用 Chat API",
 			zap.String("url", url),
-			zap.Any("headers", req.Header),
+			zap.Any("headers", headers),
 			zap.String("body", string(finalBody)),
 		)
 	}
 
-	// 7. 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// 7. 发送请求，超时由 ctx 控制
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %w", err)
+		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
-	defer resp.Body.Close()
 
 	o.logger.Debug("OpenAI API响应状态", zap.Int("status", resp.StatusCode))
 
 	// 错误状态
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("OpenAI API返回错误状态码 %d: %s", resp.StatusCode, string(body))
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("OpenAI API返回错误状态码 %d: %s", resp.StatusCode, string(body))
 	}
+	return resp, nil
+}
 
-	// 解析响应
+// readChatResponse 解析非流式的响应，onDelta 不为空时用完整的回复调用一次
+func (o *OpenAIUtil) readChatResponse(body io.Reader, onDelta func(string)) (string, TokenUsage, error) {
+	var usage TokenUsage
 	var chatResp ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", fmt.Errorf("解析OpenAI响应失败: %w", err)
+	if err := json.NewDecoder(body).Decode(&chatResp); err != nil {
+		return "", usage, fmt.Errorf("解析OpenAI响应失败: %w", err)
 	}
 
 	if chatResp.Error.Message != "" {
-		return "", fmt.Errorf("OpenAI API返回错误: %s", chatResp.Error.Message)
+		return "", usage, fmt.Errorf("OpenAI API返回错误: %s", chatResp.Error.Message)
 	}
 
 	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("OpenAI未返回有效结果")
+		return "", usage, fmt.Errorf("OpenAI未返回有效结果")
 	}
 
-	return chatResp.Choices[0].Message.Content, nil
+	usage.InputTokens = chatResp.Usage.PromptTokens
+	usage.OutputTokens = chatResp.Usage.CompletionTokens
+	content := chatResp.Choices[0].Message.Content
+	if onDelta != nil {
+		onDelta(content)
+	}
+	return content, usage, nil
 }
 
-func (o *OpenAIUtil) TestConnection(settings OpenAISettings) error {
-	prompt := "你好，请简单介绍一下你自己。"
-	_, err := o.CallWithPrompt(settings, prompt)
-	return err
+// readChatStream 解析SSE格式的流式响应，每行 data: 后是一段JSON，以 data: [DONE] 结束
+func (o *OpenAIUtil) readChatStream(body io.Reader, onDelta func(string)) (string, TokenUsage, error) {
+	var usage TokenUsage
+	var content strings.Builder
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", usage, fmt.Errorf("读取OpenAI流式响应失败: %w", err)
+		}
+		line = strings.TrimSpace(line)
+
+		// 忽略空行、注释和 event 等其他字段
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				break
+			}
+			var chunk ChatStreamChunk
+			if jsonErr := json.Unmarshal([]byte(data), &chunk); jsonErr != nil {
+				return "", usage, fmt.Errorf("解析OpenAI流式响应失败: %w", jsonErr)
+			}
+			if chunk.Error.Message != "" {
+				return "", usage, fmt.Errorf("OpenAI API返回错误: %s", chunk.Error.Message)
+			}
+			if chunk.Usage != nil {
+				usage.InputTokens = chunk.Usage.PromptTokens
+				usage.OutputTokens = chunk.Usage.CompletionTokens
+			}
+			for _, choice := range chunk.Choices {
+				if choice.Delta.Content == "" {
+					continue
+				}
+				content.WriteString(choice.Delta.Content)
+				onDelta(choice.Delta.Content)
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if content.Len() == 0 {
+		return "", usage, fmt.Errorf("OpenAI未返回有效结果")
+	}
+	return content.String(), usage, nil
 }
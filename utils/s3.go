@@ -0,0 +1,438 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultS3Region 未设置区域时使用的区域，MinIO默认也使用该区域
+	DefaultS3Region = "us-east-1"
+	// s3PartSize 分片上传时每个分片的大小，S3要求除最后一个分片外不小于5MB
+	s3PartSize = 8 << 20
+	// s3EmptyPayloadHash 空请求体的SHA-256
+	s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// ErrS3NotFound 对象不存在
+var ErrS3NotFound = errors.New("S3对象不存在")
+
+// S3Object 存储桶中的对象
+type S3Object struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	ETag         string    `json:"etag"`
+}
+
+// S3Client 使用SigV4签名的S3兼容对象存储客户端，支持AWS S3和MinIO等
+type S3Client struct {
+	settings S3Settings
+	client   *http.Client
+	// partSize 分片大小，超过该大小的对象使用分片上传
+	partSize int
+	// now 返回签名使用的当前时间
+	now func() time.Time
+}
+
+// NewS3Client 根据设置创建S3客户端
+func NewS3Client(settings S3Settings) *S3Client {
+	return &S3Client{
+		settings: settings,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		partSize: s3PartSize,
+		now:      time.Now,
+	}
+}
+
+// s3Error S3返回的错误
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// s3ListResult ListObjectsV2的响应
+type s3ListResult struct {
+	Contents []struct {
+		Key          string `xml:"Key"`
+		Size         int64  `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// s3InitiateMultipartResult CreateMultipartUpload的响应
+type s3InitiateMultipartResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+// s3CompletePart 完成分片上传时提交的分片
+type s3CompletePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// s3CompleteMultipartUpload CompleteMultipartUpload的请求体
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name         `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletePart `xml:"Part"`
+}
+
+// region 返回签名使用的区域
+func (c *S3Client) region() string {
+	if c.settings.Region == "" {
+		return DefaultS3Region
+	}
+	return c.settings.Region
+}
+
+// objectURL 返回对象的地址，key 为空时返回存储桶的地址
+//
+// 默认使用路径风格（endpoint/bucket/key），兼容MinIO；启用虚拟主机风格时使用 bucket.endpoint/key。
+func (c *S3Client) objectURL(key string, query url.Values) (*url.URL, error) {
+	endpoint := strings.TrimRight(c.settings.Endpoint, "/")
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("S3服务地址格式错误: %w", err)
+	}
+	if c.settings.VirtualHostStyle {
+		u.Host = c.settings.Bucket + "." + u.Host
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + key
+	} else {
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + c.settings.Bucket + "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = s3CanonicalQuery(query)
+	return u, nil
+}
+
+// do 发送签名后的请求，调用方负责关闭响应体
+func (c *S3Client) do(ctx context.Context, method, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	u, err := c.objectURL(key, query)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.ContentLength = int64(len(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	c.sign(req, body)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s 失败: %w", method, key, err)
+	}
+	return resp, nil
+}
+
+// sign 按AWS Signature Version 4为请求签名
+func (c *S3Client) sign(req *http.Request, body []byte) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := s3EmptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// 签名host、content-type、range和所有x-amz-请求头
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		name := strings.ToLower(k)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" || name == "content-md5" || name == "range" {
+			headers[name] = strings.Join(strings.Fields(strings.Join(v, ",")), " ")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + c.region() + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.settings.SecretAccessKey), date)
+	key = hmacSHA256(key, c.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.settings.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 计算HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape 按SigV4的要求转义，只保留字母、数字和 -._~
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for _, ch := range []byte(s) {
+		if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') ||
+			ch == '-' || ch == '.' || ch == '_' || ch == '~' || (keepSlash && ch == '/') {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// s3EscapePath 转义对象路径，保留路径分隔符
+func s3EscapePath(p string) string {
+	return s3Escape(p, true)
+}
+
+// s3CanonicalQuery 返回按参数名排序并转义的查询字符串
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3StatusError 返回包含S3错误码的错误，并关闭响应体
+func s3StatusError(method, key string, resp *http.Response) error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var e s3Error
+	if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+		return fmt.Errorf("%s %s 返回状态码 %d: %s %s", method, key, resp.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Errorf("%s %s 返回状态码 %d: %s", method, key, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// Test 列出存储桶中的一个对象，检查地址、存储桶和密钥是否正确
+func (c *S3Client) Test(ctx context.Context) error {
+	resp, err := c.do(ctx, "GET", "", url.Values{"list-type": {"2"}, "max-keys": {"1"}}, nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return s3StatusError("GET", c.settings.Bucket, resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// PutObject 上传对象，超过分片大小时使用分片上传
+func (c *S3Client) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	if len(data) > c.partSize {
+		return c.putMultipart(ctx, key, data, contentType)
+	}
+	resp, err := c.do(ctx, "PUT", key, nil, data, http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return s3StatusError("PUT", key, resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// putMultipart 分片上传对象，失败时取消上传，避免存储桶中残留未完成的分片
+func (c *S3Client) putMultipart(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := c.do(ctx, "POST", key, url.Values{"uploads": {""}}, nil, http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return s3StatusError("POST", key, resp)
+	}
+	var initiated s3InitiateMultipartResult
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil || initiated.UploadID == "" {
+		return fmt.Errorf("解析分片上传响应失败: %v", err)
+	}
+	uploadID := initiated.UploadID
+
+	parts, err := c.uploadParts(ctx, key, uploadID, data)
+	if err == nil {
+		err = c.completeMultipart(ctx, key, uploadID, parts)
+	}
+	if err != nil {
+		// 使用新的上下文取消上传，原上下文可能已经超时
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if resp, abortErr := c.do(abortCtx, "DELETE", key, url.Values{"uploadId": {uploadID}}, nil, nil); abortErr == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+// uploadParts 依次上传所有分片，返回每个分片的ETag
+func (c *S3Client) uploadParts(ctx context.Context, key, uploadID string, data []byte) ([]s3CompletePart, error) {
+	var parts []s3CompletePart
+	for offset, number := 0, 1; offset < len(data); offset, number = offset+c.partSize, number+1 {
+		end := offset + c.partSize
+		if end > len(data) {
+			end = len(data)
+		}
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := c.do(ctx, "PUT", key, query, data[offset:end], nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, s3StatusError("PUT", key, resp)
+		}
+		resp.Body.Close()
+		parts = append(parts, s3CompletePart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	}
+	return parts, nil
+}
+
+// completeMultipart 提交所有分片完成上传
+func (c *S3Client) completeMultipart(ctx context.Context, key, uploadID string, parts []s3CompletePart) error {
+	body, err := xml.Marshal(s3CompleteMultipartUpload{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, "POST", key, url.Values{"uploadId": {uploadID}}, body, http.Header{"Content-Type": {"application/xml"}})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return s3StatusError("POST", key, resp)
+	}
+	defer resp.Body.Close()
+
+	// CompleteMultipartUpload 可能在返回200后才在响应体中报告错误
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return fmt.Errorf("读取分片上传响应失败: %w", err)
+	}
+	var e s3Error
+	if xml.Unmarshal(respBody, &e) == nil && e.Code != "" {
+		return fmt.Errorf("完成分片上传失败: %s %s", e.Code, e.Message)
+	}
+	return nil
+}
+
+// GetObject 下载对象，最多读取 limit 字节；对象不存在时返回 ErrS3NotFound
+func (c *S3Client) GetObject(ctx context.Context, key string, limit int64) ([]byte, error) {
+	resp, err := c.do(ctx, "GET", key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrS3NotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3StatusError("GET", key, resp)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", key, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s 超过 %d 字节", key, limit)
+	}
+	return data, nil
+}
+
+// DeleteObject 删除对象，对象不存在时不报错
+func (c *S3Client) DeleteObject(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, "DELETE", key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound || (resp.StatusCode >= 200 && resp.StatusCode < 300) {
+		resp.Body.Close()
+		return nil
+	}
+	return s3StatusError("DELETE", key, resp)
+}
+
+// ListObjects 列出以 prefix 开头的所有对象
+func (c *S3Client) ListObjects(ctx context.Context, prefix string) ([]S3Object, error) {
+	objects := []S3Object{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(ctx, "GET", "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, s3StatusError("GET", c.settings.Bucket, resp)
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析对象列表失败: %w", err)
+		}
+		for _, o := range result.Contents {
+			modified, _ := time.Parse(time.RFC3339, o.LastModified)
+			objects = append(objects, S3Object{Key: o.Key, Size: o.Size, LastModified: modified, ETag: o.ETag})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+)
+
+// GitHub OAuth 权限
+const (
+	ScopeReadUser   = "read:user"
+	ScopePublicRepo = "public_repo"
+	ScopeRepo       = "repo"
+	ScopeReadOrg    = "read:org"
+)
+
+// impliedScopes 包含其他权限的上级权限
+var impliedScopes = map[string][]string{
+	ScopePublicRepo: {ScopeRepo},
+	ScopeReadUser:   {"user"},
+	"user:email":    {"user"},
+	ScopeReadOrg:    {"write:org", "admin:org"},
+}
+
+// GrantedScopes token实际获得的OAuth权限，来自GitHub响应的 X-OAuth-Scopes 头
+type GrantedScopes struct {
+	// Known 响应中是否包含 X-OAuth-Scopes，细粒度个人令牌和GitHub App的令牌没有该响应头
+	Known  bool
+	Scopes []string
+}
+
+// ParseGrantedScopes 解析响应头中的权限
+func ParseGrantedScopes(header http.Header) GrantedScopes {
+	values, ok := header[http.CanonicalHeaderKey("X-OAuth-Scopes")]
+	if !ok {
+		return GrantedScopes{}
+	}
+	return GrantedScopes{Known: true, Scopes: SplitScopes(strings.Join(values, ","))}
+}
+
+// Has 判断是否拥有指定权限，上级权限包含下级权限；无法确定权限时返回true，由GitHub决定是否允许
+func (g GrantedScopes) Has(scope string) bool {
+	if !g.Known {
+		return true
+	}
+	for _, s := range g.Scopes {
+		if s == scope {
+			return true
+		}
+		for _, parent := range impliedScopes[scope] {
+			if s == parent {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SplitScopes 拆分逗号或空格分隔的权限列表
+func SplitScopes(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+}
@@ -3,6 +3,9 @@ package utils
 import (
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 	"go.yaml.in/yaml/v3"
@@ -17,6 +20,201 @@ type OpenAISettings struct {
 	Body     []KeyValue `json:"body" yaml:"body"`
 }
 
+// AISettings AI服务选择和分析提示配置
+type AISettings struct {
+	// Provider 使用的AI服务：openai、anthropic、ollama，为空时使用openai
+	Provider string `json:"provider" yaml:"provider"`
+	// Prompts 自定义的分析提示模板
+	Prompts []PromptTemplate `json:"prompts" yaml:"prompts"`
+	// ActivePrompt 使用的提示模板名称，为空时使用第一个模板或内置模板
+	ActivePrompt string `json:"active_prompt" yaml:"active_prompt"`
+	// Categories AI分析可选的分类，为空时使用 DefaultCategories
+	Categories []string `json:"categories" yaml:"categories"`
+	// DisableStructuredOutput 不使用JSON Schema结构化输出，用于不支持该功能的兼容接口
+	DisableStructuredOutput bool `json:"disable_structured_output" yaml:"disable_structured_output"`
+	// RepairAttempts 回复不符合要求时让AI修正的最多次数，默认2，为负数时不修正
+	RepairAttempts int `json:"repair_attempts" yaml:"repair_attempts"`
+	// Prices 各模型的价格，用于估算费用
+	Prices []ModelPrice `json:"prices" yaml:"prices"`
+	// Currency 价格和预算的货币单位，只用于显示，默认USD
+	Currency string `json:"currency" yaml:"currency"`
+	// MonthlyBudget 每月的费用预算，本月估算费用达到预算后不再调用AI，为0时不限制
+	MonthlyBudget float64 `json:"monthly_budget" yaml:"monthly_budget"`
+	// Timeout 每次调用AI服务的超时时间，单位为秒，默认120
+	Timeout int `json:"timeout" yaml:"timeout"`
+}
+
+// ModelPrice 模型的价格，单位为每百万token
+type ModelPrice struct {
+	// Model 模型名称，为 * 时匹配所有未单独配置价格的模型
+	Model  string  `json:"model" yaml:"model"`
+	Input  float64 `json:"input" yaml:"input"`
+	Output float64 `json:"output" yaml:"output"`
+}
+
+// DefaultCurrency 默认的货币单位
+const DefaultCurrency = "USD"
+
+// CurrencyName 返回货币单位
+func (s AISettings) CurrencyName() string {
+	if s.Currency == "" {
+		return DefaultCurrency
+	}
+	return s.Currency
+}
+
+// Cost 按配置的价格估算一次调用的费用，没有配置价格的模型费用为0
+func (s AISettings) Cost(model string, usage TokenUsage) float64 {
+	var price *ModelPrice
+	for i := range s.Prices {
+		if s.Prices[i].Model == model {
+			price = &s.Prices[i]
+			break
+		}
+		if s.Prices[i].Model == "*" && price == nil {
+			price = &s.Prices[i]
+		}
+	}
+	if price == nil {
+		return 0
+	}
+	return (float64(usage.InputTokens)*price.Input + float64(usage.OutputTokens)*price.Output) / 1e6
+}
+
+// DefaultCategories 默认的分类列表
+var DefaultCategories = []string{
+	"前端",
+	"后端",
+	"移动开发",
+	"工具",
+	"数据库",
+	"运维",
+	"人工智能",
+	"安全",
+	"物联网",
+	"游戏",
+}
+
+// DefaultRepairAttempts 默认的修正次数
+const DefaultRepairAttempts = 2
+
+// CategoryList 返回AI分析可选的分类，忽略空白和重复的分类
+func (s AISettings) CategoryList() []string {
+	seen := make(map[string]bool)
+	var categories []string
+	for _, category := range s.Categories {
+		category = strings.TrimSpace(category)
+		if category != "" && !seen[category] {
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+	if len(categories) == 0 {
+		return DefaultCategories
+	}
+	return categories
+}
+
+// DefaultAITimeout 调用AI服务默认的超时时间
+const DefaultAITimeout = 120 * time.Second
+
+// RequestTimeout 返回每次调用AI服务的超时时间
+func (s AISettings) RequestTimeout() time.Duration {
+	if s.Timeout <= 0 {
+		return DefaultAITimeout
+	}
+	return time.Duration(s.Timeout) * time.Second
+}
+
+// RepairAttemptCount 返回回复不符合要求时的最多修正次数
+func (s AISettings) RepairAttemptCount() int {
+	switch {
+	case s.RepairAttempts < 0:
+		return 0
+	case s.RepairAttempts == 0:
+		return DefaultRepairAttempts
+	default:
+		return s.RepairAttempts
+	}
+}
+
+// PromptTemplate 仓库分析提示模板
+type PromptTemplate struct {
+	Name string `json:"name" yaml:"name"`
+	// Template text/template 模板，为空时使用内置模板
+	Template string `json:"template" yaml:"template"`
+	// Language 要求AI输出的语言，默认中文
+	Language string `json:"language" yaml:"language"`
+	// TagCount 要求AI提供的标签数量，默认3
+	TagCount int `json:"tag_count" yaml:"tag_count"`
+	// DescriptionLength 描述的最大字数，默认100
+	DescriptionLength int `json:"description_length" yaml:"description_length"`
+	// ReadmeBytes README最多保留的字节数，默认2000
+	ReadmeBytes int `json:"readme_bytes" yaml:"readme_bytes"`
+}
+
+const (
+	// DefaultPromptLanguage 默认输出语言
+	DefaultPromptLanguage = "中文"
+	// DefaultPromptTagCount 默认标签数量
+	DefaultPromptTagCount = 3
+	// DefaultPromptDescriptionLength 默认描述最大字数
+	DefaultPromptDescriptionLength = 100
+	// DefaultPromptReadmeBytes 默认README最多保留的字节数
+	DefaultPromptReadmeBytes = 2000
+)
+
+// WithDefaults 返回填充默认值后的模板
+func (t PromptTemplate) WithDefaults() PromptTemplate {
+	if t.Language == "" {
+		t.Language = DefaultPromptLanguage
+	}
+	if t.TagCount <= 0 {
+		t.TagCount = DefaultPromptTagCount
+	}
+	if t.DescriptionLength <= 0 {
+		t.DescriptionLength = DefaultPromptDescriptionLength
+	}
+	if t.ReadmeBytes <= 0 {
+		t.ReadmeBytes = DefaultPromptReadmeBytes
+	}
+	return t
+}
+
+// ActivePromptTemplate 返回当前使用的提示模板，已填充默认值
+func (s AISettings) ActivePromptTemplate() PromptTemplate {
+	for _, t := range s.Prompts {
+		if t.Name == s.ActivePrompt {
+			return t.WithDefaults()
+		}
+	}
+	if s.ActivePrompt == "" && len(s.Prompts) > 0 {
+		return s.Prompts[0].WithDefaults()
+	}
+	return PromptTemplate{}.WithDefaults()
+}
+
+// AnthropicSettings Anthropic配置结构
+type AnthropicSettings struct {
+	Key string `json:"key" yaml:"key"`
+	// Endpoint 为空时使用 https://api.anthropic.com
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	Model    string `json:"model" yaml:"model"`
+	// Version anthropic-version 请求头，为空时使用 2023-06-01
+	Version string `json:"version" yaml:"version"`
+	// MaxTokens 最大输出token数，为空时使用1024
+	MaxTokens int        `json:"max_tokens" yaml:"max_tokens"`
+	Headers   []KeyValue `json:"headers" yaml:"headers"`
+}
+
+// OllamaSettings Ollama配置结构
+type OllamaSettings struct {
+	// Endpoint 为空时使用 http://localhost:11434
+	Endpoint string     `json:"endpoint" yaml:"endpoint"`
+	Model    string     `json:"model" yaml:"model"`
+	Headers  []KeyValue `json:"headers" yaml:"headers"`
+}
+
 // KeyValue 键值对结构，用于自定义请求头和请求体
 type KeyValue struct {
 	Key   string `json:"key" yaml:"key"`
@@ -28,16 +226,213 @@ type WebDAVSettings struct {
 	Url      string `json:"url" yaml:"url"`
 	Username string `json:"username" yaml:"username"`
 	Password string `json:"password" yaml:"password"`
+	// Directory 保存备份的目录，相对于服务器地址，为空时使用 github-stars-manager
+	Directory string `json:"directory" yaml:"directory"`
+	// BackupEnabled 是否启用定时备份
+	BackupEnabled bool `json:"backup_enabled" yaml:"backup_enabled"`
+	// BackupIntervalHours 定时备份的间隔（小时），默认24
+	BackupIntervalHours int `json:"backup_interval_hours" yaml:"backup_interval_hours"`
+	// BackupKeep 保留的备份数量，默认7
+	BackupKeep int `json:"backup_keep" yaml:"backup_keep"`
+	// TagSync 是否启用多实例之间的标签双向同步
+	TagSync bool `json:"tag_sync" yaml:"tag_sync"`
+	// TagSyncIntervalMinutes 定时同步标签的间隔（分钟），默认10
+	TagSyncIntervalMinutes int `json:"tag_sync_interval_minutes" yaml:"tag_sync_interval_minutes"`
+	// TagSyncStrategy 标签同步的合并策略，field 按字段合并，record 整条记录以最后修改的为准，默认 field
+	TagSyncStrategy string `json:"tag_sync_strategy" yaml:"tag_sync_strategy"`
+}
+
+// WebDAV备份的默认值
+const (
+	DefaultWebDAVDirectory = "github-stars-manager"
+	DefaultBackupInterval  = 24 * time.Hour
+	DefaultBackupKeep      = 7
+	DefaultTagSyncInterval = 10 * time.Minute
+)
+
+// 标签同步的合并策略
+const (
+	TagSyncStrategyField  = "field"
+	TagSyncStrategyRecord = "record"
+)
+
+// BackupDirectory 返回保存备份的目录
+func (s WebDAVSettings) BackupDirectory() string {
+	dir := strings.Trim(s.Directory, "/")
+	if dir == "" {
+		return DefaultWebDAVDirectory
+	}
+	return dir
+}
+
+// BackupInterval 返回定时备份的间隔
+func (s WebDAVSettings) BackupInterval() time.Duration {
+	if s.BackupIntervalHours <= 0 {
+		return DefaultBackupInterval
+	}
+	return time.Duration(s.BackupIntervalHours) * time.Hour
+}
+
+// BackupKeepCount 返回保留的备份数量
+func (s WebDAVSettings) BackupKeepCount() int {
+	if s.BackupKeep <= 0 {
+		return DefaultBackupKeep
+	}
+	return s.BackupKeep
+}
+
+// TagSyncEvery 返回定时同步标签的间隔
+func (s WebDAVSettings) TagSyncEvery() time.Duration {
+	if s.TagSyncIntervalMinutes <= 0 {
+		return DefaultTagSyncInterval
+	}
+	return time.Duration(s.TagSyncIntervalMinutes) * time.Minute
+}
+
+// TagSyncMergeStrategy 返回标签同步的合并策略，未知的策略按 field 处理
+func (s WebDAVSettings) TagSyncMergeStrategy() string {
+	if s.TagSyncStrategy == TagSyncStrategyRecord {
+		return TagSyncStrategyRecord
+	}
+	return TagSyncStrategyField
+}
+
+// S3Settings S3兼容对象存储配置结构，用于备份
+type S3Settings struct {
+	// Endpoint 服务地址，例如 https://s3.amazonaws.com 或 http://localhost:9000
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// Region 区域，为空时使用 us-east-1
+	Region string `json:"region" yaml:"region"`
+	Bucket string `json:"bucket" yaml:"bucket"`
+	// Prefix 备份对象的键前缀，为空时使用 github-stars-manager
+	Prefix          string `json:"prefix" yaml:"prefix"`
+	AccessKeyID     string `json:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key" yaml:"secret_access_key"`
+	// VirtualHostStyle 使用 bucket.endpoint 形式的地址，默认使用路径风格，兼容MinIO
+	VirtualHostStyle bool `json:"virtual_host_style" yaml:"virtual_host_style"`
+	// BackupEnabled 是否启用定时备份
+	BackupEnabled bool `json:"backup_enabled" yaml:"backup_enabled"`
+	// BackupIntervalHours 定时备份的间隔（小时），默认24
+	BackupIntervalHours int `json:"backup_interval_hours" yaml:"backup_interval_hours"`
+	// BackupKeep 保留的备份数量，默认7
+	BackupKeep int `json:"backup_keep" yaml:"backup_keep"`
+}
+
+// Configured 判断是否填写了服务地址和存储桶
+func (s S3Settings) Configured() bool {
+	return s.Endpoint != "" && s.Bucket != ""
+}
+
+// BackupPrefix 返回备份对象的键前缀，以 / 结尾
+func (s S3Settings) BackupPrefix() string {
+	prefix := strings.Trim(s.Prefix, "/")
+	if prefix == "" {
+		prefix = DefaultWebDAVDirectory
+	}
+	return prefix + "/"
+}
+
+// BackupInterval 返回定时备份的间隔
+func (s S3Settings) BackupInterval() time.Duration {
+	if s.BackupIntervalHours <= 0 {
+		return DefaultBackupInterval
+	}
+	return time.Duration(s.BackupIntervalHours) * time.Hour
+}
+
+// BackupKeepCount 返回保留的备份数量
+func (s S3Settings) BackupKeepCount() int {
+	if s.BackupKeep <= 0 {
+		return DefaultBackupKeep
+	}
+	return s.BackupKeep
+}
+
+// ExportSettings 导出配置结构
+type ExportSettings struct {
+	// MarkdownTitle Markdown文档标题
+	MarkdownTitle string `json:"markdown_title" yaml:"markdown_title"`
+	// MarkdownTemplate 自定义的 text/template 模板，为空时使用内置模板
+	MarkdownTemplate string `json:"markdown_template" yaml:"markdown_template"`
+}
+
+// ReleaseSettings 发布跟踪配置结构
+type ReleaseSettings struct {
+	// Enabled 是否启用后台定时检查
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// IntervalMinutes 后台检查间隔（分钟）
+	IntervalMinutes int `json:"interval_minutes" yaml:"interval_minutes"`
+	// RepoIDs 单独跟踪的仓库
+	RepoIDs []int64 `json:"repo_ids" yaml:"repo_ids"`
+	// Categories 跟踪这些分类下的所有仓库
+	Categories []string `json:"categories" yaml:"categories"`
+}
+
+// HealthSettings 仓库健康状态配置结构
+type HealthSettings struct {
+	// SlowingMonths 超过多少个月没有推送视为放缓
+	SlowingMonths int `json:"slowing_months" yaml:"slowing_months"`
+	// StaleMonths 超过多少个月没有推送视为停滞
+	StaleMonths int `json:"stale_months" yaml:"stale_months"`
+}
+
+// WithDefaults 返回填充默认值后的配置
+func (s HealthSettings) WithDefaults() HealthSettings {
+	if s.StaleMonths <= 0 {
+		s.StaleMonths = DefaultStaleMonths
+	}
+	if s.SlowingMonths <= 0 || s.SlowingMonths > s.StaleMonths {
+		s.SlowingMonths = DefaultSlowingMonths
+		if s.SlowingMonths > s.StaleMonths {
+			s.SlowingMonths = s.StaleMonths
+		}
+	}
+	return s
 }
 
 // Settings 保存到文件的设置结构
 type Settings struct {
-	OpenAI OpenAISettings `json:"openai" yaml:"openai"`
-	WebDAV WebDAVSettings `json:"webdav" yaml:"webdav"`
+	AI        AISettings        `json:"ai" yaml:"ai"`
+	OpenAI    OpenAISettings    `json:"openai" yaml:"openai"`
+	Anthropic AnthropicSettings `json:"anthropic" yaml:"anthropic"`
+	Ollama    OllamaSettings    `json:"ollama" yaml:"ollama"`
+	WebDAV    WebDAVSettings    `json:"webdav" yaml:"webdav"`
+	S3        S3Settings        `json:"s3" yaml:"s3"`
+	Export    ExportSettings    `json:"export" yaml:"export"`
+	Releases  ReleaseSettings   `json:"releases" yaml:"releases"`
+	Health    HealthSettings    `json:"health" yaml:"health"`
+}
+
+// WithoutSecrets 返回去掉密钥、密码和自定义请求头的设置副本，用于备份
+//
+// 自定义请求头可能包含认证信息，因此一并去掉。
+func (s Settings) WithoutSecrets() Settings {
+	s.OpenAI.Key = ""
+	s.OpenAI.Headers = []KeyValue{}
+	s.Anthropic.Key = ""
+	s.Anthropic.Headers = []KeyValue{}
+	s.Ollama.Headers = []KeyValue{}
+	s.WebDAV.Password = ""
+	s.S3.SecretAccessKey = ""
+	return s
+}
+
+// WithSecretsFrom 返回使用 current 中的密钥、密码、自定义请求头、WebDAV和S3配置的设置副本，用于恢复备份
+func (s Settings) WithSecretsFrom(current Settings) Settings {
+	s.OpenAI.Key = current.OpenAI.Key
+	s.OpenAI.Headers = current.OpenAI.Headers
+	s.Anthropic.Key = current.Anthropic.Key
+	s.Anthropic.Headers = current.Anthropic.Headers
+	s.Ollama.Headers = current.Ollama.Headers
+	s.WebDAV = current.WebDAV
+	s.S3 = current.S3
+	return s
 }
 
 type SettingsUtil struct {
 	logger *zap.Logger
+	// mu 保护 settings.yaml 的读写，UpdateSettings 在读取、修改、保存期间一直持有
+	mu sync.RWMutex
 }
 
 func NewSettingsUtil(logger *zap.Logger) *SettingsUtil {
@@ -46,8 +441,38 @@ func NewSettingsUtil(logger *zap.Logger) *SettingsUtil {
 	}
 }
 
-// loadSettings 从文件加载设置
+// LoadSettings 从文件加载设置
 func (utl *SettingsUtil) LoadSettings() (*Settings, error) {
+	utl.mu.RLock()
+	defer utl.mu.RUnlock()
+	return utl.loadSettings()
+}
+
+// SaveSettings 保存设置到文件
+func (utl *SettingsUtil) SaveSettings(settings *Settings) error {
+	utl.mu.Lock()
+	defer utl.mu.Unlock()
+	return utl.saveSettings(settings)
+}
+
+// UpdateSettings 加载设置并交给 fn 修改后保存，整个过程持有锁，避免并发修改互相覆盖
+//
+// fn 返回错误时不保存。
+func (utl *SettingsUtil) UpdateSettings(fn func(settings *Settings) error) error {
+	utl.mu.Lock()
+	defer utl.mu.Unlock()
+	settings, err := utl.loadSettings()
+	if err != nil {
+		return err
+	}
+	if err := fn(settings); err != nil {
+		return err
+	}
+	return utl.saveSettings(settings)
+}
+
+// loadSettings 从文件加载设置，调用方需要持有锁
+func (utl *SettingsUtil) loadSettings() (*Settings, error) {
 	settingsPath := filepath.Join("data", "settings.yaml")
 
 	// 如果设置文件不存在，返回默认设置
@@ -66,6 +491,9 @@ func (utl *SettingsUtil) LoadSettings() (*Settings, error) {
 				Username: "",
 				Password: "",
 			},
+			AI:        AISettings{Provider: ProviderOpenAI},
+			Anthropic: AnthropicSettings{Headers: make([]KeyValue, 0)},
+			Ollama:    OllamaSettings{Headers: make([]KeyValue, 0)},
 		}, nil
 	}
 
@@ -90,11 +518,18 @@ func (utl *SettingsUtil) LoadSettings() (*Settings, error) {
 	if settings.OpenAI.Body == nil {
 		settings.OpenAI.Body = make([]KeyValue, 0)
 	}
+	if settings.Anthropic.Headers == nil {
+		settings.Anthropic.Headers = make([]KeyValue, 0)
+	}
+	if settings.Ollama.Headers == nil {
+		settings.Ollama.Headers = make([]KeyValue, 0)
+	}
 	utl.logger.Info("解析配置文件成功")
 	return &settings, nil
 }
 
-func (utl *SettingsUtil) SaveSettings(settings *Settings) error {
+// saveSettings 将设置写入文件，调用方需要持有锁
+func (utl *SettingsUtil) saveSettings(settings *Settings) error {
 	// 判断data目录是否存在，不存在，创建
 	if _, err := os.Stat("data"); os.IsNotExist(err) {
 		utl.logger.Info("创建data目录")
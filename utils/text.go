@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TruncateUTF8 将字符串截断到不超过 maxBytes 字节，不会截断多字节字符
+func TruncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	for maxBytes > 0 && !utf8.RuneStart(s[maxBytes]) {
+		maxBytes--
+	}
+	return s[:maxBytes]
+}
+
+// SplitTags 将逗号分隔的标签字符串拆分为标签列表，同时支持中文逗号
+func SplitTags(tag string) []string {
+	var tags []string
+	for _, t := range strings.FieldsFunc(tag, func(r rune) bool { return r == ',' || r == '，' }) {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
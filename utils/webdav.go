@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrWebDAVNotFound 文件不存在
+	ErrWebDAVNotFound = errors.New("WebDAV文件不存在")
+	// ErrWebDAVPreconditionFailed 文件已被其他客户端修改，ETag不匹配
+	ErrWebDAVPreconditionFailed = errors.New("WebDAV文件已被修改")
+)
+
+// WebDAVFile WebDAV目录中的文件
+type WebDAVFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	ETag    string    `json:"etag"`
+	IsDir   bool      `json:"is_dir"`
+}
+
+// WebDAVClient 简单的WebDAV客户端，路径都相对于设置中的服务器地址
+type WebDAVClient struct {
+	settings WebDAVSettings
+	client   *http.Client
+}
+
+// NewWebDAVClient 根据设置创建WebDAV客户端
+func NewWebDAVClient(settings WebDAVSettings) *WebDAVClient {
+	return &WebDAVClient{
+		settings: settings,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// webdavMultiStatus PROPFIND响应结构
+type webdavMultiStatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ContentLength string `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+				ETag          string `xml:"getetag"`
+				ResourceType  struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// propfindBody 请求的属性
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/><d:getetag/></d:prop></d:propfind>`
+
+// url 返回相对路径对应的完整地址，路径中的每一段都会被转义
+func (c *WebDAVClient) url(p string) string {
+	base := strings.TrimRight(c.settings.Url, "/")
+	var segments []string
+	for _, s := range strings.Split(p, "/") {
+		if s != "" {
+			segments = append(segments, url.PathEscape(s))
+		}
+	}
+	if len(segments) == 0 {
+		return base + "/"
+	}
+	return base + "/" + strings.Join(segments, "/")
+}
+
+// do 发送请求，调用方负责关闭响应体
+func (c *WebDAVClient) do(ctx context.Context, method, p string, body []byte, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url(p), reader)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.settings.Username != "" || c.settings.Password != "" {
+		req.SetBasicAuth(c.settings.Username, c.settings.Password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s 失败: %w", method, p, err)
+	}
+	return resp, nil
+}
+
+// webdavStatusError 返回包含状态码和部分响应内容的错误，并关闭响应体
+func webdavStatusError(method, p string, resp *http.Response) error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s %s 返回状态码 %d: %s", method, p, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// Test 对服务器地址发送PROPFIND请求，检查地址和认证信息是否正确
+func (c *WebDAVClient) Test(ctx context.Context) error {
+	resp, err := c.do(ctx, "PROPFIND", "", []byte(propfindBody), http.Header{
+		"Depth":        {"0"},
+		"Content-Type": {"application/xml"},
+	})
+	if err != nil {
+		return err
+	}
+	// WebDAV服务器通常会返回207 Multi-Status或200 OK表示成功
+	if resp.StatusCode != http.StatusMultiStatus && resp.StatusCode != http.StatusOK {
+		return webdavStatusError("PROPFIND", "/", resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// MkdirAll 逐级创建目录，目录已存在时不报错
+func (c *WebDAVClient) MkdirAll(ctx context.Context, dir string) error {
+	current := ""
+	for _, s := range strings.Split(dir, "/") {
+		if s == "" {
+			continue
+		}
+		current += "/" + s
+		resp, err := c.do(ctx, "MKCOL", current+"/", nil, nil)
+		if err != nil {
+			return err
+		}
+		switch resp.StatusCode {
+		case http.StatusCreated, http.StatusOK:
+			resp.Body.Close()
+		case http.StatusMethodNotAllowed:
+			// 目录已存在
+			resp.Body.Close()
+		default:
+			return webdavStatusError("MKCOL", current, resp)
+		}
+	}
+	return nil
+}
+
+// Put 上传文件，覆盖已存在的文件
+func (c *WebDAVClient) Put(ctx context.Context, p string, data []byte, contentType string) error {
+	_, err := c.put(ctx, p, data, http.Header{"Content-Type": {contentType}})
+	return err
+}
+
+// PutIfMatch 只在文件的ETag仍为 etag 时上传，etag 为空时只在文件不存在时上传，返回新的ETag
+//
+// 文件已被修改时返回 ErrWebDAVPreconditionFailed。服务器没有返回新的ETag时返回空字符串。
+func (c *WebDAVClient) PutIfMatch(ctx context.Context, p string, data []byte, contentType, etag string) (string, error) {
+	header := http.Header{"Content-Type": {contentType}}
+	if etag == "" {
+		header.Set("If-None-Match", "*")
+	} else {
+		header.Set("If-Match", etag)
+	}
+	return c.put(ctx, p, data, header)
+}
+
+// put 上传文件，返回响应中的ETag
+func (c *WebDAVClient) put(ctx context.Context, p string, data []byte, header http.Header) (string, error) {
+	resp, err := c.do(ctx, "PUT", p, data, header)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		resp.Body.Close()
+		return "", ErrWebDAVPreconditionFailed
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", webdavStatusError("PUT", p, resp)
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// Get 下载文件，最多读取 limit 字节，同时返回文件的ETag；文件不存在时返回 ErrWebDAVNotFound
+func (c *WebDAVClient) Get(ctx context.Context, p string, limit int64) ([]byte, string, error) {
+	resp, err := c.do(ctx, "GET", p, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, "", ErrWebDAVNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", webdavStatusError("GET", p, resp)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("读取 %s 失败: %w", p, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, "", fmt.Errorf("%s 超过 %d 字节", p, limit)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// ETag 返回文件当前的ETag，文件不存在时返回 ErrWebDAVNotFound
+func (c *WebDAVClient) ETag(ctx context.Context, p string) (string, error) {
+	resp, err := c.do(ctx, "HEAD", p, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrWebDAVNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HEAD %s 返回状态码 %d", p, resp.StatusCode)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// Delete 删除文件，文件不存在时不报错
+func (c *WebDAVClient) Delete(ctx context.Context, p string) error {
+	resp, err := c.do(ctx, "DELETE", p, nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound || (resp.StatusCode >= 200 && resp.StatusCode < 300) {
+		resp.Body.Close()
+		return nil
+	}
+	return webdavStatusError("DELETE", p, resp)
+}
+
+// List 列出目录中的文件和子目录，不包括目录本身；目录不存在时返回空列表
+func (c *WebDAVClient) List(ctx context.Context, dir string) ([]WebDAVFile, error) {
+	resp, err := c.do(ctx, "PROPFIND", dir+"/", []byte(propfindBody), http.Header{
+		"Depth":        {"1"},
+		"Content-Type": {"application/xml"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return []WebDAVFile{}, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, webdavStatusError("PROPFIND", dir, resp)
+	}
+	defer resp.Body.Close()
+
+	var ms webdavMultiStatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("解析PROPFIND响应失败: %w", err)
+	}
+
+	self := strings.TrimRight(c.url(dir), "/")
+	files := make([]WebDAVFile, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		// href 可能是绝对地址或只有路径，跳过目录本身
+		hrefPath := strings.TrimRight(href.Path, "/")
+		if selfURL, err := url.Parse(self); err == nil && hrefPath == strings.TrimRight(selfURL.Path, "/") {
+			continue
+		}
+
+		file := WebDAVFile{Name: path.Base(hrefPath)}
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			if ps.Prop.ResourceType.Collection != nil {
+				file.IsDir = true
+			}
+			if ps.Prop.ContentLength != "" {
+				file.Size, _ = strconv.ParseInt(ps.Prop.ContentLength, 10, 64)
+			}
+			if ps.Prop.LastModified != "" {
+				file.ModTime, _ = http.ParseTime(ps.Prop.LastModified)
+			}
+			if ps.Prop.ETag != "" {
+				file.ETag = ps.Prop.ETag
+			}
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}